@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
@@ -15,11 +21,18 @@ import (
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/logger"
 
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/geoip"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/handler"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/middleware"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/opengraph"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/scanner"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/bolt"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/memory"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/postgres"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/redis"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/tiered"
 )
 
 func main() {
@@ -49,29 +62,133 @@ func main() {
 	defer log.Sync()
 
 	// Initialize repositories
-	dbRepo, err := postgres.NewURLRepository(cfg.DatabaseURL())
+	dbRepo, err := openStore(cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to database", zap.Error(err))
+		log.Fatal("Failed to open storage", zap.String("driver", cfg.StorageDriver()), zap.Error(err))
 	}
 	defer dbRepo.Close()
 
-	cacheRepo, err := redis.NewCacheRepository(cfg.RedisURL())
+	cacheRepo, redisRepo, bus, err := openCache(cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to Redis", zap.Error(err))
+		log.Fatal("Failed to open cache", zap.String("driver", cfg.CacheDriver()), zap.Error(err))
 	}
 	defer cacheRepo.Close()
 
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	urlOpts := []service.Option{
+		service.WithAnalyticsRepository(dbRepo),
+		service.WithDomainRepository(dbRepo),
+		service.WithWorkspaceRepository(dbRepo),
+	}
+
+	var linkFilter *service.LinkFilter
+	if cfg.Bloom.Enabled {
+		var mirror domain.BitmapRepository
+		if redisRepo != nil && cfg.Bloom.RedisKey != "" {
+			mirror = redisRepo
+		}
+		linkFilter = service.NewLinkFilter(dbRepo, mirror, log, cfg.Bloom)
+		if bus != nil {
+			bus.Listen(linkFilter)
+		}
+	}
+	// With Redis, instances tell each other about changed keys and links
+	if bus != nil {
+		if err := bus.Start(jobsCtx); err != nil {
+			log.Fatal("Failed to subscribe to link changes", zap.Error(err))
+		}
+		urlOpts = append(urlOpts, service.WithLinkPublisher(bus))
+	}
+	if linkFilter != nil {
+		// Built after subscribing, so links created meanwhile are not missed
+		if err := linkFilter.Rebuild(jobsCtx); err != nil {
+			log.Fatal("Failed to build link filter", zap.Error(err))
+		}
+		if cfg.Bloom.SyncInterval > 0 {
+			go linkFilter.Run(jobsCtx)
+		}
+		urlOpts = append(urlOpts, service.WithLinkFilter(linkFilter))
+	}
+	if cfg.GeoIP.DatabasePath != "" {
+		geoReader, err := geoip.Open(cfg.GeoIP.DatabasePath)
+		if err != nil {
+			log.Fatal("Failed to open GeoIP database", zap.Error(err))
+		}
+		defer geoReader.Close()
+		urlOpts = append(urlOpts, service.WithGeoLocator(geoReader))
+	}
+
+	policy, err := newNetworkPolicy(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up destination policy", zap.Error(err))
+	}
+	urlScanner, err := newURLScanner(cfg, policy)
+	if err != nil {
+		log.Fatal("Failed to set up URL scanner", zap.Error(err))
+	}
+	urlOpts = append(urlOpts,
+		service.WithDestinationPolicy(policy),
+		service.WithURLScanner(urlScanner),
+		service.WithSafetyRepository(dbRepo),
+	)
+	if cfg.OpenGraph.Fetch {
+		fetcher := opengraph.NewFetcher(cfg.OpenGraph.Timeout, cfg.OpenGraph.MaxBytes).WithTransport(policy.Transport())
+		urlOpts = append(urlOpts, service.WithMetadataFetcher(fetcher))
+	}
+
 	// Initialize services
-	urlService := service.NewURLService(dbRepo, cacheRepo, log, cfg)
-	analyticsService := service.NewAnalyticsService(dbRepo, cacheRepo, log)
+	urlService := service.NewURLService(dbRepo, cacheRepo, log, cfg, urlOpts...)
+	analyticsService := service.NewAnalyticsService(urlService, dbRepo, cacheRepo, log)
+	qrService := service.NewQRService(urlService, cacheRepo, log, cfg.Cache.QRTTL)
+	domainService := service.NewDomainService(dbRepo, cacheRepo, log, cfg)
+	workspaceService := service.NewWorkspaceService(dbRepo, cacheRepo, log, cfg.Workspaces)
+
+	if cfg.Scanner.RescanInterval > 0 && cfg.Scanner.RescanBatch > 0 {
+		go urlService.RunSafetyRescans(jobsCtx, cfg.Scanner.RescanInterval, cfg.Scanner.RescanBatch)
+	}
+	if cfg.Health.Interval > 0 && cfg.Health.Batch > 0 {
+		healthChecker := service.NewHealthChecker(dbRepo, cacheRepo, log, cfg.Health, policy.Transport())
+		if bus != nil {
+			healthChecker.WithLinkPublisher(bus)
+		}
+		go healthChecker.Run(jobsCtx)
+	}
+
+	// Popular links are cached before the server starts taking requests
+	warmer := service.NewCacheWarmer(dbRepo, cacheRepo, log, cfg.Warmup, cfg.Cache.URLTTL)
+	if cfg.Warmup.Links > 0 {
+		warmCtx, cancel := jobsCtx, context.CancelFunc(func() {})
+		if cfg.Warmup.Timeout > 0 {
+			warmCtx, cancel = context.WithTimeout(jobsCtx, cfg.Warmup.Timeout)
+		}
+		warmed, err := warmer.Warm(warmCtx)
+		cancel()
+		if err != nil {
+			log.Warn("Cache warmup failed", zap.Int("cached", warmed), zap.Error(err))
+		} else {
+			log.Info("Cache warmed", zap.Int("links", warmed))
+		}
+	}
+	if cfg.Warmup.RefreshInterval > 0 && cfg.Warmup.Trending > 0 {
+		go warmer.Run(jobsCtx)
+	}
 
 	// Initialize handlers
 	urlHandler := handler.NewURLHandler(urlService, log)
 	analyticsHandler := handler.NewAnalyticsHandler(analyticsService, log)
+	qrHandler := handler.NewQRHandler(qrService, log)
+	domainHandler := handler.NewDomainHandler(domainService, log)
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceService, log)
 	healthHandler := handler.NewHealthHandler(dbRepo, cacheRepo)
+	if linkFilter != nil {
+		healthHandler.WithLinkFilter(linkFilter)
+	}
 
 	// Setup routes
-	router := setupRoutes(cfg, urlHandler, analyticsHandler, healthHandler, log)
+	router := setupRoutes(cfg, urlHandler, analyticsHandler, qrHandler, domainHandler, workspaceHandler, workspaceService, healthHandler, log)
 
 	// Start server
 	srv := &http.Server{
@@ -93,6 +210,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Info("Shutting down server...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -103,7 +221,160 @@ func main() {
 	log.Info("Server exited")
 }
 
-func setupRoutes(cfg *config.Config, urlHandler *handler.URLHandler, analyticsHandler *handler.AnalyticsHandler, healthHandler *handler.HealthHandler, log *zap.Logger) *gin.Engine {
+// store is what a storage driver provides: every repository the services use
+type store interface {
+	domain.URLRepository
+	domain.AnalyticsRepository
+	domain.SafetyRepository
+	domain.LinkHealthRepository
+	domain.DomainRepository
+	domain.WorkspaceRepository
+	domain.LinkKeyRepository
+	domain.PopularURLRepository
+	Close() error
+}
+
+type cache interface {
+	domain.CacheRepository
+	Close() error
+}
+
+// openStore opens the configured storage driver
+func openStore(cfg *config.Config) (store, error) {
+	switch cfg.StorageDriver() {
+	case config.StorageMemory:
+		return memory.NewStore(nil), nil
+	case config.StorageBolt:
+		return bolt.Open(cfg.Storage.Path)
+	default:
+		return postgres.NewURLRepository(cfg.DatabaseURL())
+	}
+}
+
+// openCache opens the configured cache driver, and returns the Redis
+// client too when the driver is Redis, with the bus instances tell each
+// other about changed keys and links on. Redis is fronted by an in-process
+// tier when cache.local_size is set.
+func openCache(cfg *config.Config) (cache, *redis.CacheRepository, *tiered.Bus, error) {
+	if cfg.CacheDriver() == config.CacheMemory {
+		return memory.NewCache(), nil, nil, nil
+	}
+	opts, err := redisOptions(cfg.Redis)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	remote, err := redis.NewCacheRepositoryWithOptions(opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bus, err := tiered.NewBus(remote)
+	if err != nil {
+		remote.Close()
+		return nil, nil, nil, err
+	}
+	if cfg.Cache.LocalSize == 0 {
+		return remote, remote, bus, nil
+	}
+	local, err := tiered.NewCache(remote, tiered.Options{
+		Size: cfg.Cache.LocalSize,
+		TTL:  cfg.Cache.LocalTTL,
+		Bus:  bus,
+	})
+	if err != nil {
+		remote.Close()
+		return nil, nil, nil, err
+	}
+	return local, remote, bus, nil
+}
+
+// redisOptions describes the Redis server, Sentinel group or Cluster of cfg
+func redisOptions(cfg config.RedisConfig) (redis.Options, error) {
+	opts := redis.Options{
+		Addr:             net.JoinHostPort(cfg.Host, cfg.Port),
+		MasterName:       cfg.MasterName,
+		SentinelAddrs:    cfg.SentinelAddrs,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		ClusterAddrs:     cfg.ClusterAddrs,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		DB:               cfg.DB,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		PoolTimeout:      cfg.PoolTimeout,
+	}
+	if !cfg.TLS {
+		return opts, nil
+	}
+
+	opts.TLS = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSSkipVerify,
+	}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return opts, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		opts.TLS.RootCAs = x509.NewCertPool()
+		if !opts.TLS.RootCAs.AppendCertsFromPEM(pem) {
+			return opts, fmt.Errorf("no certificates found in Redis CA file %s", cfg.TLSCAFile)
+		}
+	}
+	if cfg.TLSClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSClientCertFile, cfg.TLSClientKeyFile)
+		if err != nil {
+			return opts, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		opts.TLS.Certificates = []tls.Certificate{cert}
+	}
+	return opts, nil
+}
+
+// newNetworkPolicy refuses internal destinations outside the allowed
+// networks, other shorteners and links back to this service
+func newNetworkPolicy(cfg *config.Config) (*scanner.NetworkPolicy, error) {
+	shorteners := cfg.Validation.ShortenerDomains
+	if len(shorteners) == 0 {
+		shorteners = scanner.DefaultShortenerDomains
+	}
+	if base, err := url.Parse(cfg.BaseURL()); err == nil && base.Hostname() != "" {
+		shorteners = append(slices.Clone(shorteners), base.Hostname())
+	}
+	return scanner.NewNetworkPolicy(cfg.Validation.AllowedNetworks, shorteners, nil)
+}
+
+// newURLScanner combines the configured destination scanners: the
+// blocklist (built-in malicious domains plus the optional file), heuristics,
+// and Safe Browsing when an API key is set. Redirects are followed through
+// the network policy so scans cannot reach internal services.
+func newURLScanner(cfg *config.Config, policy *scanner.NetworkPolicy) (*scanner.Multi, error) {
+	blocklist := scanner.NewBlocklist()
+	if cfg.Scanner.BlocklistPath != "" {
+		var err error
+		if blocklist, err = scanner.LoadBlocklist(cfg.Scanner.BlocklistPath); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range cfg.Validation.MaliciousDomains {
+		blocklist.Add(entry)
+	}
+
+	scanners := []domain.URLScanner{
+		blocklist,
+		scanner.NewHeuristics(cfg.Scanner.MaxRedirects, cfg.Scanner.Timeout).WithTransport(policy.Transport()),
+	}
+	if cfg.Scanner.SafeBrowsingAPIKey != "" {
+		scanners = append(scanners, scanner.NewSafeBrowsing(cfg.Scanner.SafeBrowsingEndpoint, cfg.Scanner.SafeBrowsingAPIKey, cfg.Scanner.Timeout))
+	}
+	return scanner.NewMulti(scanners...), nil
+}
+
+func setupRoutes(cfg *config.Config, urlHandler *handler.URLHandler, analyticsHandler *handler.AnalyticsHandler, qrHandler *handler.QRHandler, domainHandler *handler.DomainHandler, workspaceHandler *handler.WorkspaceHandler, workspaces middleware.WorkspaceResolver, healthHandler *handler.HealthHandler, log *zap.Logger) *gin.Engine {
 	if cfg.Environment() == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -119,16 +390,35 @@ func setupRoutes(cfg *config.Config, urlHandler *handler.URLHandler, analyticsHa
 	// Rate limiting
 	router.Use(middleware.RateLimiter(cfg.RateLimit))
 
-	// API v1 routes
+	// API v1 routes, scoped to the caller's workspace
 	v1 := router.Group("/api/v1")
+	v1.Use(middleware.Workspace(workspaces, cfg.JWTSecret()))
 
 	{
-		v1.POST("/shorten", urlHandler.ShortenURL)
-		v1.GET("/analytics/:shortCode", middleware.JWTAuth(cfg.JWTSecret()), analyticsHandler.GetAnalytics)
+		v1.POST("/shorten", middleware.RequireRole(domain.RoleEditor), urlHandler.ShortenURL)
+		v1.GET("/analytics/:shortCode", middleware.JWTAuth(cfg.JWTSecret()), middleware.RequireRole(domain.RoleViewer), analyticsHandler.GetAnalytics)
+		v1.GET("/urls/:shortCode", middleware.RequireRole(domain.RoleViewer), urlHandler.GetURL)
+		v1.GET("/urls/:shortCode/qr", middleware.RequireRole(domain.RoleViewer), qrHandler.GenerateQRCode)
+		v1.POST("/domains", middleware.JWTAuth(cfg.JWTSecret()), middleware.RequireRole(domain.RoleOwner), domainHandler.AddDomain)
+		v1.GET("/domains", middleware.JWTAuth(cfg.JWTSecret()), middleware.RequireRole(domain.RoleViewer), domainHandler.ListDomains)
+
+		// Workspaces are managed by users; the path names the workspace
+		ws := v1.Group("/workspaces", middleware.JWTAuth(cfg.JWTSecret()))
+		ws.POST("", workspaceHandler.CreateWorkspace)
+		ws.GET("", workspaceHandler.ListWorkspaces)
+		ws.GET("/:id/members", workspaceHandler.ListMembers)
+		ws.PUT("/:id/members/:userID", workspaceHandler.SaveMember)
+		ws.DELETE("/:id/members/:userID", workspaceHandler.RemoveMember)
+		ws.POST("/:id/api-keys", workspaceHandler.CreateAPIKey)
+		ws.GET("/:id/api-keys", workspaceHandler.ListAPIKeys)
+		ws.DELETE("/:id/api-keys/:keyID", workspaceHandler.RevokeAPIKey)
 	}
 
-	// Redirect route (no rate limiting for better UX)
+	// Redirect routes (no rate limiting for better UX); the catch-all variant
+	// still resolves the short code first and hands the rest to path passthrough.
+	// Requests arriving on a branded domain resolve that domain's short codes.
 	router.GET("/:shortCode", urlHandler.RedirectURL)
+	router.GET("/:shortCode/*path", urlHandler.RedirectURL)
 
 	return router
 }
@@ -0,0 +1,138 @@
+// Package bloom is a Bloom filter: a compact set that answers "certainly
+// absent" or "maybe present". Its bits hash the same way on every instance,
+// so filters of the same size can be merged through a shared bitmap.
+package bloom
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// Filter is safe for concurrent use. Keys cannot be removed.
+type Filter struct {
+	words []atomic.Uint64
+	m     uint64 // Bits
+	k     uint64 // Hash functions
+	added atomic.Int64
+}
+
+// New sizes a filter to hold capacity keys with the given false positive
+// rate, which must be between 0 and 1
+func New(capacity int, falsePositiveRate float64) *Filter {
+	n := math.Max(float64(capacity), 1)
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(math.Round(m/n*math.Ln2), 1)
+
+	words := (uint64(m) + 63) / 64
+	return &Filter{
+		words: make([]atomic.Uint64, words),
+		m:     words * 64,
+		k:     uint64(k),
+	}
+}
+
+// Bits is the size of the filter in bits
+func (f *Filter) Bits() uint64 { return f.m }
+
+// Hashes is the number of bits set per key
+func (f *Filter) Hashes() uint64 { return f.k }
+
+// Added is how many keys were added, counting repeats
+func (f *Filter) Added() int64 { return f.added.Load() }
+
+// Locations returns the bits of a key, by double hashing two FNV variants
+func (f *Filter) Locations(key string) []uint64 {
+	h1, h2 := fnv.New64a(), fnv.New64()
+	h1.Write([]byte(key))
+	h2.Write([]byte(key))
+	a, b := h1.Sum64(), h2.Sum64()|1
+
+	locations := make([]uint64, f.k)
+	for i := range locations {
+		locations[i] = (a + uint64(i)*b) % f.m
+	}
+	return locations
+}
+
+// Add puts a key in the filter and returns its bits
+func (f *Filter) Add(key string) []uint64 {
+	locations := f.Locations(key)
+	for _, bit := range locations {
+		f.words[bit/64].Or(1 << (bit % 64))
+	}
+	f.added.Add(1)
+	return locations
+}
+
+// MayContain is false only for keys that were never added
+func (f *Filter) MayContain(key string) bool {
+	for _, bit := range f.Locations(key) {
+		if f.words[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FalsePositiveRate estimates the chance a key never added is reported as
+// maybe present, from how full the filter is
+func (f *Filter) FalsePositiveRate() float64 {
+	var set int
+	for i := range f.words {
+		set += bits.OnesCount64(f.words[i].Load())
+	}
+	return math.Pow(float64(set)/float64(f.m), float64(f.k))
+}
+
+// Bitmap returns the filter's bits in Redis bitmap order, where offset 0 is
+// the most significant bit of the first byte
+func (f *Filter) Bitmap() []byte {
+	bitmap := make([]byte, f.m/8)
+	for i := range f.words {
+		word := f.words[i].Load()
+		for j := 0; j < 8; j++ {
+			bitmap[i*8+j] = bits.Reverse8(byte(word >> (8 * j)))
+		}
+	}
+	return bitmap
+}
+
+// Merge adds the bits of a bitmap in Bitmap's order; bits past the end of
+// the filter are ignored
+func (f *Filter) Merge(bitmap []byte) {
+	for i := range f.words {
+		var word uint64
+		for j := 0; j < 8 && i*8+j < len(bitmap); j++ {
+			word |= uint64(bits.Reverse8(bitmap[i*8+j])) << (8 * j)
+		}
+		if word != 0 {
+			f.words[i].Or(word)
+		}
+	}
+}
+
+// Missing returns the offsets, in Bitmap's order, of bits set in the filter
+// but not in bitmap. It gives up and returns false once there are more than
+// max of them.
+func (f *Filter) Missing(bitmap []byte, max int) ([]uint64, bool) {
+	var offsets []uint64
+	for i := range f.words {
+		word := f.words[i].Load()
+		for j := 0; j < 8; j++ {
+			n := i*8 + j
+			missing := bits.Reverse8(byte(word >> (8 * j)))
+			if n < len(bitmap) {
+				missing &^= bitmap[n]
+			}
+			for ; missing != 0; missing &= missing - 1 {
+				if len(offsets) == max {
+					return nil, false
+				}
+				offsets = append(offsets, uint64(n)*8+uint64(bits.LeadingZeros8(missing)))
+			}
+		}
+	}
+	return offsets, true
+}
@@ -0,0 +1,75 @@
+package bloom
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_NoFalseNegatives(t *testing.T) {
+	f := New(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add(fmt.Sprintf("code%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		assert.True(t, f.MayContain(fmt.Sprintf("code%d", i)))
+	}
+	assert.Equal(t, int64(1000), f.Added())
+}
+
+func TestFilter_FalsePositiveRate(t *testing.T) {
+	f := New(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		f.Add(fmt.Sprintf("code%d", i))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.MayContain(fmt.Sprintf("other%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 200, "about 1%% of absent keys pass")
+	assert.InDelta(t, 0.01, f.FalsePositiveRate(), 0.005)
+}
+
+func TestFilter_BitmapMerge(t *testing.T) {
+	a, b := New(100, 0.01), New(100, 0.01)
+	bits := a.Add("abc")
+	b.Add("xyz")
+
+	bitmap := a.Bitmap()
+	for _, bit := range bits {
+		assert.NotZero(t, bitmap[bit/8]&(0x80>>(bit%8)), "offset %d is set the way Redis numbers bits", bit)
+	}
+
+	b.Merge(bitmap)
+	assert.True(t, b.MayContain("abc"))
+	assert.True(t, b.MayContain("xyz"))
+	assert.Equal(t, a.Bits(), b.Bits())
+}
+
+func TestFilter_Missing(t *testing.T) {
+	a, b := New(100, 0.01), New(100, 0.01)
+	shared := a.Add("abc")
+	b.Add("abc")
+	var want []uint64
+	for _, bit := range a.Add("xyz") {
+		if !slices.Contains(shared, bit) && !slices.Contains(want, bit) {
+			want = append(want, bit)
+		}
+	}
+
+	missing, ok := a.Missing(b.Bitmap(), 100)
+	assert.True(t, ok)
+	assert.ElementsMatch(t, want, missing, "only the bits b lacks")
+
+	missing, ok = a.Missing(a.Bitmap(), 100)
+	assert.True(t, ok)
+	assert.Empty(t, missing)
+
+	_, ok = a.Missing(nil, 1)
+	assert.False(t, ok, "too many to list")
+}
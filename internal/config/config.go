@@ -2,23 +2,36 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
 )
 
 type Config struct {
-	Server     ServerConfig     `yaml:"server"`
-	Logging    LoggingConfig    `yaml:"logging"`
-	JWT        JWTConfig        `yaml:"jwt"`
-	Database   DatabaseConfig   `yaml:"database"`
-	Redis      RedisConfig      `yaml:"redis"`
-	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
-	Snowflake  SnowflakeConfig  `yaml:"snowflake"`
-	Cache      CacheConfig      `yaml:"cache"`
-	Validation ValidationConfig `yaml:"validation"`
+	Server     ServerConfig      `yaml:"server"`
+	Logging    LoggingConfig     `yaml:"logging"`
+	JWT        JWTConfig         `yaml:"jwt"`
+	Database   DatabaseConfig    `yaml:"database"`
+	Redis      RedisConfig       `yaml:"redis"`
+	RateLimit  RateLimitConfig   `yaml:"rate_limit"`
+	Snowflake  SnowflakeConfig   `yaml:"snowflake"`
+	Cache      CacheConfig       `yaml:"cache"`
+	Validation ValidationConfig  `yaml:"validation"`
+	Redirect   RedirectConfig    `yaml:"redirect"`
+	GeoIP      GeoIPConfig       `yaml:"geoip"`
+	Scanner    ScannerConfig     `yaml:"scanner"`
+	Health     HealthCheckConfig `yaml:"health_check"`
+	OpenGraph  OpenGraphConfig   `yaml:"open_graph"`
+	Workspaces WorkspaceConfig   `yaml:"workspaces"`
+	Storage    StorageConfig     `yaml:"storage"`
+	Bloom      BloomFilterConfig `yaml:"bloom_filter"`
+	Warmup     WarmupConfig      `yaml:"cache_warmup"`
 }
 
 type ServerConfig struct {
@@ -47,11 +60,34 @@ type DatabaseConfig struct {
 	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
 }
 
+// RedisConfig reaches a single server at Host and Port, a Sentinel group
+// when MasterName is set, or a Cluster when ClusterAddrs is
 type RedisConfig struct {
 	Host     string `yaml:"host"`
 	Port     string `yaml:"port"`
+	Username string `yaml:"username"` // ACL user; empty is the default user
 	Password string `yaml:"password"`
 	DB       int    `yaml:"db"`
+
+	MasterName       string   `yaml:"master_name"`    // Sentinel master
+	SentinelAddrs    []string `yaml:"sentinel_addrs"` // host:port of the Sentinels
+	SentinelUsername string   `yaml:"sentinel_username"`
+	SentinelPassword string   `yaml:"sentinel_password"`
+	ClusterAddrs     []string `yaml:"cluster_addrs"` // host:port of Cluster seed nodes
+
+	TLS               bool   `yaml:"tls"`
+	TLSCAFile         string `yaml:"tls_ca_file"`     // PEM certificates to trust instead of the system's
+	TLSServerName     string `yaml:"tls_server_name"` // Name to verify certificates against; empty is the host dialed
+	TLSSkipVerify     bool   `yaml:"tls_skip_verify"` // Accept any certificate; for testing only
+	TLSClientCertFile string `yaml:"tls_client_cert_file"`
+	TLSClientKeyFile  string `yaml:"tls_client_key_file"`
+
+	PoolSize     int           `yaml:"pool_size"` // Connections per server; 0 is 10 per CPU
+	MinIdleConns int           `yaml:"min_idle_conns"`
+	DialTimeout  time.Duration `yaml:"dial_timeout"`  // 0 is 5s
+	ReadTimeout  time.Duration `yaml:"read_timeout"`  // 0 is 3s
+	WriteTimeout time.Duration `yaml:"write_timeout"` // 0 is the read timeout
+	PoolTimeout  time.Duration `yaml:"pool_timeout"`  // Wait for a free connection; 0 is the read timeout plus 1s
 }
 
 type RateLimitConfig struct {
@@ -66,10 +102,130 @@ type SnowflakeConfig struct {
 type CacheConfig struct {
 	URLTTL       time.Duration `yaml:"url_ttl"`
 	AnalyticsTTL time.Duration `yaml:"analytics_ttl"`
+	QRTTL        time.Duration `yaml:"qr_ttl"`       // Rendered QR codes; 0 disables caching
+	LocalSize    int           `yaml:"local_size"`   // Links kept in process in front of Redis; 0 disables the local tier
+	LocalTTL     time.Duration `yaml:"local_ttl"`    // Longest a link is served from process
+	NegativeTTL  time.Duration `yaml:"negative_ttl"` // How long a missing short code is remembered; 0 disables
 }
 
 type ValidationConfig struct {
 	MaliciousDomains []string `yaml:"malicious_domains"`
+	AllowedNetworks  []string `yaml:"allowed_networks"`  // CIDRs exempt from the private-address check
+	ShortenerDomains []string `yaml:"shortener_domains"` // Other shorteners; empty uses a built-in list
+	DeepLinkSchemes  []string `yaml:"deep_link_schemes"` // App schemes device rules may open, such as myapp; empty allows none
+}
+
+type RedirectConfig struct {
+	DefaultStatus   int           `yaml:"default_status"`    // 301, 302, 307 or 308
+	PermanentMaxAge time.Duration `yaml:"permanent_max_age"` // Cache-Control max-age for 301/308
+}
+
+type GeoIPConfig struct {
+	DatabasePath string `yaml:"database_path"` // MaxMind-format .mmdb file; empty disables geo lookups
+}
+
+type ScannerConfig struct {
+	BlocklistPath        string        `yaml:"blocklist_path"`         // One domain per line, "*.example.com" covers subdomains
+	SafeBrowsingAPIKey   string        `yaml:"safe_browsing_api_key"`  // Empty disables Safe Browsing lookups
+	SafeBrowsingEndpoint string        `yaml:"safe_browsing_endpoint"` // Empty uses Google's endpoint
+	MaxRedirects         int           `yaml:"max_redirects"`          // Longer redirect chains are flagged; 0 disables
+	Timeout              time.Duration `yaml:"timeout"`                // Per network check
+	RescanInterval       time.Duration `yaml:"rescan_interval"`        // How often links are re-checked; 0 disables
+	RescanBatch          int           `yaml:"rescan_batch"`           // Links re-checked per run
+}
+
+type HealthCheckConfig struct {
+	Interval     time.Duration `yaml:"interval"`      // How often destinations are checked; 0 disables
+	Batch        int           `yaml:"batch"`         // Links checked per run
+	Concurrency  int           `yaml:"concurrency"`   // Checks in flight at once
+	HostDelay    time.Duration `yaml:"host_delay"`    // Minimum gap between requests to the same host
+	Timeout      time.Duration `yaml:"timeout"`       // Per check, including redirects
+	DisableAfter int           `yaml:"disable_after"` // Consecutive dead checks before a link is disabled; 0 never disables
+}
+
+type OpenGraphConfig struct {
+	Fetch    bool          `yaml:"fetch"`     // Read title, description and image from destinations on create
+	Timeout  time.Duration `yaml:"timeout"`   // Per fetch
+	MaxBytes int64         `yaml:"max_bytes"` // Largest part of a page read; 0 uses 1 MiB
+}
+
+// WorkspaceConfig holds the quotas given to new workspaces; 0 is unlimited
+type WorkspaceConfig struct {
+	MaxLinks    int64 `yaml:"max_links"`     // Links a workspace may create
+	MaxAPICalls int64 `yaml:"max_api_calls"` // API requests per workspace per day
+
+	// Access to the shared workspace of links created without one
+	Admins     []string `yaml:"admins"`      // JWT user IDs that own it, such as to register its domains
+	PublicRole string   `yaml:"public_role"` // Role of callers without credentials: viewer or editor; empty is viewer
+}
+
+// DefaultBloomSyncInterval is how often link filters catch up unless
+// configured otherwise
+const DefaultBloomSyncInterval = time.Minute
+
+// BloomFilterConfig sizes the filter that answers 404 for short codes that
+// certainly do not exist without asking the cache or the database
+type BloomFilterConfig struct {
+	Enabled           bool    `yaml:"enabled"`
+	Capacity          int     `yaml:"capacity"`            // Links the filter is sized for
+	FalsePositiveRate float64 `yaml:"false_positive_rate"` // Share of missing codes let through at capacity, such as 0.01
+	RedisKey          string  `yaml:"redis_key"`           // Mirrors the filter to this Redis bitmap; empty keeps it in process
+
+	// How often the filter catches up on link announcements that were lost,
+	// from its mirror or else from the database; 0 disables
+	SyncInterval time.Duration `yaml:"sync_interval"`
+}
+
+// WarmupConfig keeps popular links in the cache, ranked by recorded clicks
+type WarmupConfig struct {
+	Links           int           `yaml:"links"`            // Links preloaded before the server starts; 0 disables
+	Order           string        `yaml:"order"`            // clicks or recent; empty is clicks
+	Window          time.Duration `yaml:"window"`           // Clicks this recent count towards the preload; 0 counts every click
+	Timeout         time.Duration `yaml:"timeout"`          // Longest the preload may delay startup; 0 waits for it
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // How often trending links get a fresh cache TTL; 0 disables
+	Trending        int           `yaml:"trending"`         // Links renewed per run, the most clicked since the last one
+}
+
+// Storage drivers
+const (
+	StoragePostgres = "postgres" // PostgreSQL, with Redis as the cache
+	StorageMemory   = "memory"   // Nothing persists across restarts
+	StorageBolt     = "bolt"     // A single bbolt database file
+)
+
+// Cache drivers
+const (
+	CacheRedis  = "redis"
+	CacheMemory = "memory"
+)
+
+// StorageConfig selects where links and cached values are kept. The memory
+// and bolt drivers need no external services.
+type StorageConfig struct {
+	Driver string `yaml:"driver"` // postgres, memory or bolt; empty is postgres
+	Path   string `yaml:"path"`   // Database file of the bolt driver
+	Cache  string `yaml:"cache"`  // redis or memory; empty is redis for postgres, memory otherwise
+}
+
+// StorageDriver returns the configured storage driver
+func (c *Config) StorageDriver() string {
+	if c.Storage.Driver == "" {
+		return StoragePostgres
+	}
+	return c.Storage.Driver
+}
+
+// CacheDriver returns the configured cache driver, which defaults to Redis
+// only alongside PostgreSQL
+func (c *Config) CacheDriver() string {
+	switch {
+	case c.Storage.Cache != "":
+		return c.Storage.Cache
+	case c.StorageDriver() == StoragePostgres:
+		return CacheRedis
+	default:
+		return CacheMemory
+	}
 }
 
 // Legacy fields for backward compatibility
@@ -94,8 +250,9 @@ func (c *Config) DatabaseURL() string {
 
 // RedisURL builds the Redis connection string
 func (c *Config) RedisURL() string {
-	if c.Redis.Password != "" {
-		return fmt.Sprintf("redis://:%s@%s:%s/%d",
+	if c.Redis.Username != "" || c.Redis.Password != "" {
+		return fmt.Sprintf("redis://%s:%s@%s:%s/%d",
+			c.Redis.Username,
 			c.Redis.Password,
 			c.Redis.Host,
 			c.Redis.Port,
@@ -122,8 +279,8 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Parse YAML
-	var cfg Config
+	// Parse YAML over the defaults of settings where 0 means off
+	cfg := Config{Bloom: BloomFilterConfig{SyncInterval: DefaultBloomSyncInterval}}
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
@@ -166,6 +323,27 @@ func LoadFromEnv() *Config {
 			Port:     getEnv("REDIS_PORT", "6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
+			Username: getEnv("REDIS_USERNAME", ""),
+
+			MasterName:       getEnv("REDIS_MASTER_NAME", ""),
+			SentinelAddrs:    getEnvAsSlice("REDIS_SENTINEL_ADDRS", nil),
+			SentinelUsername: getEnv("REDIS_SENTINEL_USERNAME", ""),
+			SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+			ClusterAddrs:     getEnvAsSlice("REDIS_CLUSTER_ADDRS", nil),
+
+			TLS:               getEnv("REDIS_TLS", "false") == "true",
+			TLSCAFile:         getEnv("REDIS_TLS_CA_FILE", ""),
+			TLSServerName:     getEnv("REDIS_TLS_SERVER_NAME", ""),
+			TLSSkipVerify:     getEnv("REDIS_TLS_SKIP_VERIFY", "false") == "true",
+			TLSClientCertFile: getEnv("REDIS_TLS_CLIENT_CERT_FILE", ""),
+			TLSClientKeyFile:  getEnv("REDIS_TLS_CLIENT_KEY_FILE", ""),
+
+			PoolSize:     getEnvAsInt("REDIS_POOL_SIZE", 0),
+			MinIdleConns: getEnvAsInt("REDIS_MIN_IDLE_CONNS", 0),
+			DialTimeout:  time.Duration(getEnvAsInt("REDIS_DIAL_TIMEOUT_MS", 0)) * time.Millisecond,
+			ReadTimeout:  time.Duration(getEnvAsInt("REDIS_READ_TIMEOUT_MS", 0)) * time.Millisecond,
+			WriteTimeout: time.Duration(getEnvAsInt("REDIS_WRITE_TIMEOUT_MS", 0)) * time.Millisecond,
+			PoolTimeout:  time.Duration(getEnvAsInt("REDIS_POOL_TIMEOUT_MS", 0)) * time.Millisecond,
 		},
 		RateLimit: RateLimitConfig{
 			Requests: getEnvAsInt("RATE_LIMIT_REQUESTS", 100),
@@ -177,12 +355,74 @@ func LoadFromEnv() *Config {
 		Cache: CacheConfig{
 			URLTTL:       1 * time.Hour,
 			AnalyticsTTL: 15 * time.Minute,
+			QRTTL:        time.Duration(getEnvAsInt("CACHE_QR_TTL", 86400)) * time.Second,
+			LocalSize:    getEnvAsInt("CACHE_LOCAL_SIZE", 10000),
+			LocalTTL:     time.Duration(getEnvAsInt("CACHE_LOCAL_TTL", 30)) * time.Second,
+			NegativeTTL:  time.Duration(getEnvAsInt("CACHE_NEGATIVE_TTL", 30)) * time.Second,
 		},
 		Validation: ValidationConfig{
 			MaliciousDomains: []string{
 				"malware.example.com",
 				"phishing.example.com",
 			},
+			AllowedNetworks:  getEnvAsSlice("ALLOWED_NETWORKS", nil),
+			ShortenerDomains: getEnvAsSlice("SHORTENER_DOMAINS", nil),
+			DeepLinkSchemes:  getEnvAsSlice("DEEP_LINK_SCHEMES", nil),
+		},
+		Redirect: RedirectConfig{
+			DefaultStatus:   getEnvAsInt("REDIRECT_STATUS", http.StatusMovedPermanently),
+			PermanentMaxAge: time.Duration(getEnvAsInt("REDIRECT_PERMANENT_MAX_AGE", 3600)) * time.Second,
+		},
+		GeoIP: GeoIPConfig{
+			DatabasePath: getEnv("GEOIP_DATABASE_PATH", ""),
+		},
+		Scanner: ScannerConfig{
+			BlocklistPath:        getEnv("SCANNER_BLOCKLIST_PATH", ""),
+			SafeBrowsingAPIKey:   getEnv("SAFE_BROWSING_API_KEY", ""),
+			SafeBrowsingEndpoint: getEnv("SAFE_BROWSING_ENDPOINT", ""),
+			MaxRedirects:         getEnvAsInt("SCANNER_MAX_REDIRECTS", 5),
+			Timeout:              time.Duration(getEnvAsInt("SCANNER_TIMEOUT", 5)) * time.Second,
+			RescanInterval:       time.Duration(getEnvAsInt("SCANNER_RESCAN_INTERVAL", 86400)) * time.Second,
+			RescanBatch:          getEnvAsInt("SCANNER_RESCAN_BATCH", 100),
+		},
+		Health: HealthCheckConfig{
+			Interval:     time.Duration(getEnvAsInt("HEALTH_CHECK_INTERVAL", 3600)) * time.Second,
+			Batch:        getEnvAsInt("HEALTH_CHECK_BATCH", 200),
+			Concurrency:  getEnvAsInt("HEALTH_CHECK_CONCURRENCY", 10),
+			HostDelay:    time.Duration(getEnvAsInt("HEALTH_CHECK_HOST_DELAY_MS", 1000)) * time.Millisecond,
+			Timeout:      time.Duration(getEnvAsInt("HEALTH_CHECK_TIMEOUT", 10)) * time.Second,
+			DisableAfter: getEnvAsInt("HEALTH_CHECK_DISABLE_AFTER", 0),
+		},
+		OpenGraph: OpenGraphConfig{
+			Fetch:    getEnv("OPEN_GRAPH_FETCH", "true") == "true",
+			Timeout:  time.Duration(getEnvAsInt("OPEN_GRAPH_TIMEOUT", 5)) * time.Second,
+			MaxBytes: int64(getEnvAsInt("OPEN_GRAPH_MAX_BYTES", 1<<20)),
+		},
+		Workspaces: WorkspaceConfig{
+			MaxLinks:    int64(getEnvAsInt("WORKSPACE_MAX_LINKS", 0)),
+			MaxAPICalls: int64(getEnvAsInt("WORKSPACE_MAX_API_CALLS", 0)),
+			Admins:      getEnvAsSlice("WORKSPACE_ADMINS", nil),
+			PublicRole:  getEnv("WORKSPACE_PUBLIC_ROLE", ""),
+		},
+		Storage: StorageConfig{
+			Driver: getEnv("STORAGE_DRIVER", StoragePostgres),
+			Path:   getEnv("STORAGE_PATH", "shortener.db"),
+			Cache:  getEnv("STORAGE_CACHE", ""),
+		},
+		Warmup: WarmupConfig{
+			Links:           getEnvAsInt("CACHE_WARMUP_LINKS", 1000),
+			Order:           getEnv("CACHE_WARMUP_ORDER", domain.PopularByClicks),
+			Window:          time.Duration(getEnvAsInt("CACHE_WARMUP_WINDOW", 7*86400)) * time.Second,
+			Timeout:         time.Duration(getEnvAsInt("CACHE_WARMUP_TIMEOUT", 30)) * time.Second,
+			RefreshInterval: time.Duration(getEnvAsInt("CACHE_WARMUP_REFRESH_INTERVAL", 300)) * time.Second,
+			Trending:        getEnvAsInt("CACHE_WARMUP_TRENDING", 100),
+		},
+		Bloom: BloomFilterConfig{
+			Enabled:           getEnv("BLOOM_FILTER_ENABLED", "false") == "true",
+			Capacity:          getEnvAsInt("BLOOM_FILTER_CAPACITY", 1000000),
+			FalsePositiveRate: getEnvAsFloat("BLOOM_FILTER_FALSE_POSITIVE_RATE", 0.01),
+			RedisKey:          getEnv("BLOOM_FILTER_REDIS_KEY", "bloom:links"),
+			SyncInterval:      time.Duration(getEnvAsInt("BLOOM_FILTER_SYNC_INTERVAL", int(DefaultBloomSyncInterval/time.Second))) * time.Second,
 		},
 	}
 }
@@ -191,14 +431,49 @@ func (c *Config) validate() error {
 	if c.Server.Port == "" {
 		return fmt.Errorf("server port is required")
 	}
-	if c.Database.Host == "" {
-		return fmt.Errorf("database host is required")
+	switch c.StorageDriver() {
+	case StoragePostgres:
+		if c.Database.Host == "" {
+			return fmt.Errorf("database host is required")
+		}
+		if c.Database.User == "" {
+			return fmt.Errorf("database user is required")
+		}
+		if c.Database.Name == "" {
+			return fmt.Errorf("database name is required")
+		}
+	case StorageMemory:
+	case StorageBolt:
+		if c.Storage.Path == "" {
+			return fmt.Errorf("storage path is required for the bolt driver")
+		}
+	default:
+		return fmt.Errorf("storage driver must be one of postgres, memory or bolt")
+	}
+	if cache := c.CacheDriver(); cache != CacheRedis && cache != CacheMemory {
+		return fmt.Errorf("storage cache must be redis or memory")
+	}
+	r := c.Redis
+	if r.MasterName != "" && len(r.ClusterAddrs) > 0 {
+		return fmt.Errorf("redis master_name and cluster_addrs cannot be used together")
+	}
+	if r.MasterName != "" && len(r.SentinelAddrs) == 0 {
+		return fmt.Errorf("redis sentinel_addrs are required with master_name")
+	}
+	if len(r.ClusterAddrs) > 0 && r.DB != 0 {
+		return fmt.Errorf("redis cluster only has db 0")
 	}
-	if c.Database.User == "" {
-		return fmt.Errorf("database user is required")
+	if (r.TLSClientCertFile == "") != (r.TLSClientKeyFile == "") {
+		return fmt.Errorf("redis tls_client_cert_file and tls_client_key_file go together")
 	}
-	if c.Database.Name == "" {
-		return fmt.Errorf("database name is required")
+	if r.PoolSize < 0 || r.MinIdleConns < 0 || r.DialTimeout < 0 || r.ReadTimeout < 0 || r.WriteTimeout < 0 || r.PoolTimeout < 0 {
+		return fmt.Errorf("redis pool and timeout settings must not be negative")
+	}
+	if c.Cache.LocalSize < 0 || c.Cache.LocalTTL < 0 || c.Cache.NegativeTTL < 0 {
+		return fmt.Errorf("cache settings must not be negative")
+	}
+	if c.Cache.LocalSize > 0 && c.Cache.LocalTTL == 0 {
+		return fmt.Errorf("cache local_ttl is required with local_size")
 	}
 	if c.Snowflake.MachineID < 0 || c.Snowflake.MachineID > 1023 {
 		return fmt.Errorf("snowflake machine_id must be between 0 and 1023")
@@ -209,6 +484,43 @@ func (c *Config) validate() error {
 	if c.RateLimit.Window <= 0 {
 		return fmt.Errorf("rate_limit window must be positive")
 	}
+	if c.Redirect.DefaultStatus != 0 && !domain.IsValidRedirectStatus(c.Redirect.DefaultStatus) {
+		return fmt.Errorf("redirect default_status must be one of 301, 302, 307 or 308")
+	}
+	if c.Scanner.MaxRedirects < 0 || c.Scanner.RescanBatch < 0 || c.Scanner.Timeout < 0 || c.Scanner.RescanInterval < 0 {
+		return fmt.Errorf("scanner settings must not be negative")
+	}
+	h := c.Health
+	if h.Interval < 0 || h.Batch < 0 || h.Concurrency < 0 || h.HostDelay < 0 || h.Timeout < 0 || h.DisableAfter < 0 {
+		return fmt.Errorf("health_check settings must not be negative")
+	}
+	if c.OpenGraph.Timeout < 0 || c.OpenGraph.MaxBytes < 0 {
+		return fmt.Errorf("open_graph settings must not be negative")
+	}
+	if c.Workspaces.MaxLinks < 0 || c.Workspaces.MaxAPICalls < 0 {
+		return fmt.Errorf("workspaces quotas must not be negative")
+	}
+	if r := domain.Role(c.Workspaces.PublicRole); r != "" && r != domain.RoleViewer && r != domain.RoleEditor {
+		return fmt.Errorf("workspaces public_role must be viewer or editor")
+	}
+	w := c.Warmup
+	if w.Links < 0 || w.Window < 0 || w.Timeout < 0 || w.RefreshInterval < 0 || w.Trending < 0 {
+		return fmt.Errorf("cache_warmup settings must not be negative")
+	}
+	if w.Order != "" && w.Order != domain.PopularByClicks && w.Order != domain.PopularByRecent {
+		return fmt.Errorf("cache_warmup order must be clicks or recent")
+	}
+	if b := c.Bloom; b.Enabled && (b.Capacity <= 0 || b.FalsePositiveRate <= 0 || b.FalsePositiveRate >= 1) {
+		return fmt.Errorf("bloom_filter needs a positive capacity and a false_positive_rate between 0 and 1")
+	}
+	if c.Bloom.SyncInterval < 0 {
+		return fmt.Errorf("bloom_filter sync_interval must not be negative")
+	}
+	// Instances sharing a database hear of each other's links through Redis;
+	// without it a filter would turn away links created elsewhere
+	if c.Bloom.Enabled && c.StorageDriver() == StoragePostgres && c.CacheDriver() != CacheRedis {
+		return fmt.Errorf("bloom_filter needs the redis cache with postgres storage")
+	}
 	return nil
 }
 
@@ -219,6 +531,29 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+func getEnvAsSlice(key string, defaultVal []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		if floatVal, err := strconv.ParseFloat(val, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultVal
+}
+
 func getEnvAsInt(key string, defaultVal int) int {
 	if val := os.Getenv(key); val != "" {
 		if intVal, err := strconv.Atoi(val); err == nil {
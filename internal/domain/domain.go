@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// Domain is a branded host name with its own namespace of short codes
+type Domain struct {
+	ID          int64     `json:"id" db:"id"`
+	WorkspaceID int64     `json:"workspace_id,omitempty" db:"workspace_id"`
+	Name        string    `json:"name" db:"name"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// NormalizeHost lower-cases a Host header or domain name and strips any
+// port and trailing dot, so "Go.Brand-A.com:443" becomes "go.brand-a.com"
+func NormalizeHost(host string) string {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// LinkKey identifies a short code across domains: the bare code on the
+// default domain and "host/code" on a branded one. Cache keys and click
+// counters are built from it.
+func LinkKey(host, shortCode string) string {
+	if host == "" {
+		return shortCode
+	}
+	return host + "/" + shortCode
+}
+
+// Key returns the link's LinkKey
+func (u *URL) Key() string {
+	return LinkKey(u.Domain, u.ShortCode)
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "errors"
+
+// Repositories wrap these errors so callers can tell a missing record from a
+// failing backend with errors.Is, whatever the storage driver
+var (
+	ErrNotFound    = errors.New("not found")           // No record matches, e.g. "URL not found"
+	ErrConflict    = errors.New("already exists")      // A unique key such as a short code is taken
+	ErrUnavailable = errors.New("storage unavailable") // The database or cache cannot be reached
+)
@@ -0,0 +1,48 @@
+package domain
+
+import "context"
+
+// LinkChange announces that a link was created, edited or deleted, so every
+// instance can drop what it holds about it
+type LinkChange struct {
+	WorkspaceID int64  `json:"workspace_id"`
+	Domain      string `json:"domain,omitempty"` // Branded domain; empty is the default domain
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original_url"`
+}
+
+// NewLinkChange describes a change to url
+func NewLinkChange(url *URL) *LinkChange {
+	return &LinkChange{
+		WorkspaceID: url.WorkspaceID,
+		Domain:      url.Domain,
+		ShortCode:   url.ShortCode,
+		OriginalURL: url.OriginalURL,
+	}
+}
+
+// LinkPublisher announces link changes to every instance, this one included
+type LinkPublisher interface {
+	PublishLinkChange(ctx context.Context, change *LinkChange) error
+}
+
+// LinkListener keeps what one instance holds about links up to date
+type LinkListener interface {
+	LinkChanged(change *LinkChange) // A link changed on some instance
+	LinkChangesMissed()             // Changes may have been lost, such as while disconnected
+}
+
+type cacheFillKey struct{}
+
+// AsCacheFill marks cache writes made with ctx as copies of what storage
+// already holds, such as read-through fills and warm-up, so they are not
+// announced to other instances as changes
+func AsCacheFill(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheFillKey{}, true)
+}
+
+// IsCacheFill reports whether ctx was marked by AsCacheFill
+func IsCacheFill(ctx context.Context) bool {
+	fill, _ := ctx.Value(cacheFillKey{}).(bool)
+	return fill
+}
@@ -1,18 +1,82 @@
 package domain
 
 import (
+	"net/http"
+	"net/url"
 	"time"
 )
 
 // URL represents a shortened URL
 type URL struct {
 	ID          int64      `json:"id" db:"id"`
+	WorkspaceID int64      `json:"workspace_id,omitempty" db:"workspace_id"` // Owning workspace; 0 for links created without one
+	Domain      string     `json:"domain,omitempty" db:"domain"`             // Branded domain; empty for the default one
 	ShortCode   string     `json:"short_code" db:"short_code"`
 	OriginalURL string     `json:"original_url" db:"original_url"`
 	ClickCount  int64      `json:"click_count" db:"click_count"`
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
 	LastAccess  *time.Time `json:"last_access,omitempty" db:"last_access"`
+
+	// ActivatesAt is the not-before time; the link does not resolve until then
+	ActivatesAt *time.Time `json:"activates_at,omitempty" db:"activates_at"`
+	// ComingSoonURL is where visitors are sent before ActivatesAt, if set
+	ComingSoonURL string `json:"coming_soon_url,omitempty" db:"coming_soon_url"`
+	// RedirectStatus is the HTTP status used to redirect; 0 means the configured default
+	RedirectStatus int `json:"redirect_status,omitempty" db:"redirect_status"`
+	// ForwardQuery appends the visitor's query parameters to the destination
+	ForwardQuery bool `json:"forward_query,omitempty" db:"forward_query"`
+	// ForwardPath appends path segments after the short code to the destination
+	ForwardPath bool `json:"forward_path,omitempty" db:"forward_path"`
+	// GeoRules picks a destination by the visitor's country before OriginalURL
+	GeoRules GeoRules `json:"geo_rules,omitempty" db:"geo_rules"`
+	// DeviceRules picks a destination by the visitor's client; they win over GeoRules
+	DeviceRules DeviceRules `json:"device_rules,omitempty" db:"device_rules"`
+	// Variants splits traffic between weighted destinations picked per VariantMode
+	Variants    Variants `json:"variants,omitempty" db:"variants"`
+	VariantMode string   `json:"variant_mode,omitempty" db:"variant_mode"`
+	// Interstitial shows a "You are leaving" page before redirecting
+	Interstitial bool `json:"interstitial,omitempty" db:"interstitial"`
+	// SafetyStatus is the latest scan verdict; empty until a scan succeeds
+	SafetyStatus    string     `json:"safety_status,omitempty" db:"safety_status"`
+	SafetyCheckedAt *time.Time `json:"safety_checked_at,omitempty" db:"safety_checked_at"`
+	// Health* record the latest destination health check
+	HealthStatus    int        `json:"health_status,omitempty" db:"health_status"`
+	HealthLatencyMs int64      `json:"health_latency_ms,omitempty" db:"health_latency_ms"`
+	HealthFinalURL  string     `json:"health_final_url,omitempty" db:"health_final_url"`
+	HealthError     string     `json:"health_error,omitempty" db:"health_error"`
+	HealthCheckedAt *time.Time `json:"health_checked_at,omitempty" db:"health_checked_at"`
+	HealthFailures  int        `json:"health_failures,omitempty" db:"health_failures"`
+	// Disabled links no longer redirect, e.g. after repeated failed health checks
+	Disabled bool `json:"disabled,omitempty" db:"disabled"`
+	// OG* are the social preview served to link-unfurling crawlers
+	OGTitle       string `json:"og_title,omitempty" db:"og_title"`
+	OGDescription string `json:"og_description,omitempty" db:"og_description"`
+	OGImage       string `json:"og_image,omitempty" db:"og_image"`
+}
+
+// Health returns the latest health check, or nil if the link was never checked
+func (u *URL) Health() *LinkHealth {
+	if u.HealthCheckedAt == nil {
+		return nil
+	}
+	return &LinkHealth{
+		Status:              u.HealthStatus,
+		LatencyMs:           u.HealthLatencyMs,
+		FinalURL:            u.HealthFinalURL,
+		Error:               u.HealthError,
+		CheckedAt:           *u.HealthCheckedAt,
+		ConsecutiveFailures: u.HealthFailures,
+	}
+}
+
+// HasLinkOptions reports whether u does more than redirect to OriginalURL,
+// so it cannot be handed out for another request for the same destination
+func (u *URL) HasLinkOptions() bool {
+	return u.ActivatesAt != nil || u.ComingSoonURL != "" || u.RedirectStatus != 0 ||
+		u.ForwardQuery || u.ForwardPath || len(u.GeoRules) > 0 || len(u.DeviceRules) > 0 ||
+		len(u.Variants) > 0 || u.Interstitial ||
+		u.OGTitle != "" || u.OGDescription != "" || u.OGImage != ""
 }
 
 // ShortenRequest represents a request to shorten a URL
@@ -20,25 +84,169 @@ type ShortenRequest struct {
 	URL         string     `json:"url" binding:"required,url"`
 	CustomAlias string     `json:"custom_alias,omitempty"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Domain      string     `json:"domain,omitempty"` // Registered branded domain; empty uses the default
+
+	ActivatesAt    *time.Time  `json:"activates_at,omitempty"`
+	ComingSoonURL  string      `json:"coming_soon_url,omitempty"`
+	RedirectStatus int         `json:"redirect_status,omitempty"`
+	ForwardQuery   bool        `json:"forward_query,omitempty"`
+	ForwardPath    bool        `json:"forward_path,omitempty"`
+	GeoRules       GeoRules    `json:"geo_rules,omitempty"`
+	DeviceRules    DeviceRules `json:"device_rules,omitempty"`
+	Variants       Variants    `json:"variants,omitempty"`
+	VariantMode    string      `json:"variant_mode,omitempty"`
+	Interstitial   bool        `json:"interstitial,omitempty"`
+	// OpenGraph overrides the metadata fetched from the destination, field by field
+	OpenGraph *OpenGraph `json:"open_graph,omitempty"`
+
+	// UTM and QueryParams are merged into URL before it is stored
+	UTM         *UTMParams        `json:"utm,omitempty"`
+	QueryParams map[string]string `json:"query_params,omitempty"`
+}
+
+// HasLinkOptions reports whether r asks for more than a redirect to its
+// URL. Such requests always get a new link, since an existing one for the
+// same destination would not honour them.
+func (r *ShortenRequest) HasLinkOptions() bool {
+	return r.ActivatesAt != nil || r.ComingSoonURL != "" || r.RedirectStatus != 0 ||
+		r.ForwardQuery || r.ForwardPath || len(r.GeoRules) > 0 || len(r.DeviceRules) > 0 ||
+		len(r.Variants) > 0 || r.Interstitial || r.OpenGraph != nil
+}
+
+// UTMParams holds the campaign tracking parameters for a destination
+type UTMParams struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// Values returns the non-empty parameters keyed by their utm_* names
+func (p *UTMParams) Values() url.Values {
+	values := url.Values{}
+	if p == nil {
+		return values
+	}
+	for key, value := range map[string]string{
+		"utm_source":   p.Source,
+		"utm_medium":   p.Medium,
+		"utm_campaign": p.Campaign,
+		"utm_term":     p.Term,
+		"utm_content":  p.Content,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
 }
 
 // ShortenResponse represents a response containing the shortened URL
 type ShortenResponse struct {
 	ShortURL    string     `json:"short_url"`
 	ShortCode   string     `json:"short_code"`
+	Domain      string     `json:"domain,omitempty"`
 	OriginalURL string     `json:"original_url"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
+
+	ActivatesAt    *time.Time  `json:"activates_at,omitempty"`
+	ComingSoonURL  string      `json:"coming_soon_url,omitempty"`
+	RedirectStatus int         `json:"redirect_status,omitempty"`
+	ForwardQuery   bool        `json:"forward_query,omitempty"`
+	ForwardPath    bool        `json:"forward_path,omitempty"`
+	GeoRules       GeoRules    `json:"geo_rules,omitempty"`
+	DeviceRules    DeviceRules `json:"device_rules,omitempty"`
+	Variants       Variants    `json:"variants,omitempty"`
+	VariantMode    string      `json:"variant_mode,omitempty"`
+	Interstitial   bool        `json:"interstitial,omitempty"`
+	Disabled       bool        `json:"disabled,omitempty"`
+	Health         *LinkHealth `json:"health,omitempty"`
+	OpenGraph      *OpenGraph  `json:"open_graph,omitempty"`
+}
+
+// LinkHealth is the outcome of a destination health check
+type LinkHealth struct {
+	Status              int       `json:"status"` // final HTTP status; 0 when the destination was unreachable
+	LatencyMs           int64     `json:"latency_ms"`
+	FinalURL            string    `json:"final_url,omitempty"` // where redirects ended up
+	Error               string    `json:"error,omitempty"`
+	CheckedAt           time.Time `json:"checked_at"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+}
+
+// Dead reports whether the destination is gone or broken. Auth walls and
+// rate limits (401, 403, 429) mean the page exists, so they do not count.
+func (h *LinkHealth) Dead() bool {
+	return h.Status == 0 || h.Status == http.StatusNotFound || h.Status == http.StatusGone || h.Status >= 500
+}
+
+// Preview describes a short link without following it
+type Preview struct {
+	ShortURL     string     `json:"short_url"`
+	ShortCode    string     `json:"short_code"`
+	OriginalURL  string     `json:"original_url"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	ActivatesAt  *time.Time `json:"activates_at,omitempty"`
+	Safety       string     `json:"safety"`
+	Interstitial bool       `json:"interstitial,omitempty"`
+	ForwardQuery bool       `json:"forward_query,omitempty"` // The visitor's query parameters are passed on
+}
+
+// Visit carries the parts of an incoming request that shape its redirect
+type Visit struct {
+	Domain    string // branded domain the request arrived on; empty for the default one
+	Path      string // path after the short code, e.g. "/extra/path"
+	RawQuery  string // encoded query string without the leading '?'
+	IPAddress string
+	UserAgent string
+	Referer   string
+	VisitorID string // sticky-variant visitor cookie, if the client sent one
+	Confirmed bool   // the visitor continued past the interstitial page
+	Variant   string // signed A/B variant the interstitial page showed, kept when continuing
+}
+
+// Redirect describes where a visitor is sent and how the response may be cached
+type Redirect struct {
+	Location   string
+	StatusCode int
+	MaxAge     time.Duration // client cache lifetime for permanent redirects
+	Variant    string        // name of the A/B variant served, if any
+	VisitorID  string        // newly derived sticky-variant visitor ID to persist
+	// VariantToken signs Variant for the interstitial's continue link, so
+	// visitors cannot pick another variant by editing it
+	VariantToken string
+	// Interstitial asks the caller to show the "You are leaving" page instead
+	// of redirecting; the visit is not counted until it is confirmed
+	Interstitial bool
+}
+
+// Permanent reports whether the redirect may be cached by clients
+func (r *Redirect) Permanent() bool {
+	return r.StatusCode == http.StatusMovedPermanently || r.StatusCode == http.StatusPermanentRedirect
+}
+
+// IsValidRedirectStatus reports whether code is a status a link may redirect with
+func IsValidRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
 }
 
 // AnalyticsResponse represents the analytics data for a shortened URL
 type AnalyticsResponse struct {
-	ShortCode    string      `json:"short_code"`
-	OriginalURL  string      `json:"original_url"`
-	ClickCount   int64       `json:"click_count"`
-	CreatedAt    time.Time   `json:"created_at"`
-	LastAccessed *time.Time  `json:"last_accessed,omitempty"`
-	DailyStats   []DailyStat `json:"daily_stats,omitempty"`
+	ShortCode    string        `json:"short_code"`
+	Domain       string        `json:"domain,omitempty"`
+	OriginalURL  string        `json:"original_url"`
+	ClickCount   int64         `json:"click_count"`
+	CreatedAt    time.Time     `json:"created_at"`
+	LastAccessed *time.Time    `json:"last_accessed,omitempty"`
+	DailyStats   []DailyStat   `json:"daily_stats,omitempty"`
+	VariantStats []VariantStat `json:"variant_stats,omitempty"`
 }
 
 // DailyStat represents the daily statistics for a shortened URL
@@ -47,6 +255,12 @@ type DailyStat struct {
 	Clicks int64  `json:"clicks"`
 }
 
+// VariantStat represents the clicks served by one A/B variant
+type VariantStat struct {
+	Variant string `json:"variant" db:"variant"`
+	Clicks  int64  `json:"clicks" db:"clicks"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
@@ -55,10 +269,12 @@ type ErrorResponse struct {
 }
 type URLAnalytics struct {
 	ID        uint64    `json:"id" db:"id"`
+	Domain    string    `json:"domain,omitempty" db:"domain"`
 	ShortCode string    `json:"short_code" db:"short_code"`
 	ClickedAt time.Time `json:"clicked_at" db:"clicked_at"`
 	UserAgent string    `json:"user_agent" db:"user_agent"`
 	IPAddress string    `json:"ip_address" db:"ip_address"`
 	Referer   string    `json:"referer" db:"referer"`
 	Country   string    `json:"country" db:"country"`
+	Variant   string    `json:"variant,omitempty" db:"variant"`
 }
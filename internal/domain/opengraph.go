@@ -0,0 +1,63 @@
+package domain
+
+import "context"
+
+// Limits on social preview text, whether fetched or set on the link
+const (
+	MaxOGTitleLength       = 300
+	MaxOGDescriptionLength = 1000
+)
+
+// OpenGraph is the title, description and image chat apps and social
+// networks show when a link is shared
+type OpenGraph struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// Empty reports whether no field is set
+func (o *OpenGraph) Empty() bool {
+	return o == nil || (o.Title == "" && o.Description == "" && o.Image == "")
+}
+
+// Merge returns o with empty fields filled in from fallback
+func (o *OpenGraph) Merge(fallback *OpenGraph) *OpenGraph {
+	merged := OpenGraph{}
+	if o != nil {
+		merged = *o
+	}
+	if fallback != nil {
+		if merged.Title == "" {
+			merged.Title = fallback.Title
+		}
+		if merged.Description == "" {
+			merged.Description = fallback.Description
+		}
+		if merged.Image == "" {
+			merged.Image = fallback.Image
+		}
+	}
+	return &merged
+}
+
+// OpenGraph returns the link's social preview, or nil if it has none
+func (u *URL) OpenGraph() *OpenGraph {
+	og := &OpenGraph{Title: u.OGTitle, Description: u.OGDescription, Image: u.OGImage}
+	if og.Empty() {
+		return nil
+	}
+	return og
+}
+
+// SocialCard is the page served to link-unfurling crawlers instead of a redirect
+type SocialCard struct {
+	ShortURL    string
+	Destination string
+	OpenGraph
+}
+
+// MetadataFetcher reads the Open Graph metadata of a web page
+type MetadataFetcher interface {
+	FetchOpenGraph(ctx context.Context, rawURL string) (*OpenGraph, error)
+}
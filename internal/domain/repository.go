@@ -5,15 +5,34 @@ import (
 	"time"
 )
 
+// URLRepository looks links up by domain and short code; host is the
+// branded domain name, or empty for the default domain
 type URLRepository interface {
-	CreateURL(ctx context.Context, url *URL) error                                            // Create a new URL
-	GetURLByShortCode(ctx context.Context, shortCode string) (*URL, error)                    // Get a URL by its short code
-	GetURLByOriginalURL(ctx context.Context, originalURL string) (*URL, error)                // Get a URL by its original URL
-	UpdateClickCount(ctx context.Context, shortCode string) error                             // Update the click count for a URL
-	GetAnalytics(ctx context.Context, shortCode string, days int) (*AnalyticsResponse, error) // Get analytics for a URL
-	DeleteExpiredURLs(ctx context.Context) error                                              // Delete expired URLs
-	HealthCheck(ctx context.Context) error                                                    // Check the health of the database
-	IsShortCodeExists(ctx context.Context, shortCode string) (bool, error)                    // Check if a short code exists
+	CreateURL(ctx context.Context, url *URL) error                                                  // Create a new URL
+	GetURLByShortCode(ctx context.Context, host, shortCode string) (*URL, error)                    // Get a URL by its short code
+	GetURLByOriginalURL(ctx context.Context, host, originalURL string) (*URL, error)                // Get a URL by its original URL
+	UpdateClickCount(ctx context.Context, host, shortCode string) error                             // Update the click count for a URL
+	GetAnalytics(ctx context.Context, host, shortCode string, days int) (*AnalyticsResponse, error) // Get analytics for a URL
+	DeleteExpiredURLs(ctx context.Context) error                                                    // Delete expired URLs
+	HealthCheck(ctx context.Context) error                                                          // Check the health of the database
+	IsShortCodeExists(ctx context.Context, host, shortCode string) (bool, error)                    // Check if a short code exists
+}
+
+// LinkKeyRepository lists every stored link, in every workspace, by LinkKey
+type LinkKeyRepository interface {
+	ListLinkKeys(ctx context.Context, fn func(key string) error) error // Calls fn per link; an error from fn stops the listing
+}
+
+// Orders of popular links
+const (
+	PopularByClicks = "clicks" // Most clicks first
+	PopularByRecent = "recent" // Most recently clicked first
+)
+
+// PopularURLRepository finds the links worth keeping in the cache, from
+// their recorded clicks
+type PopularURLRepository interface {
+	ListPopularURLs(ctx context.Context, since time.Time, order string, limit int) ([]*URL, error) // Enabled, unexpired links clicked since the cutoff
 }
 
 type CacheRepository interface {
@@ -21,14 +40,54 @@ type CacheRepository interface {
 	Get(ctx context.Context, key string, dest interface{}) error                     // Get a value from the cache
 	Delete(ctx context.Context, key string) error                                    // Delete a value from the cache
 	Increment(ctx context.Context, key string, value int64) error                    // Increment a value in the cache
+	Expire(ctx context.Context, key string, ttl time.Duration) error                 // Set the time to live of a key
 	HealthCheck(ctx context.Context) error                                           // Check the health of the cache
 	Cleanup(ctx context.Context) error                                               // Cleanup expired cache entries
 	GetCounter(ctx context.Context, key string) (int64, error)
 }
 
+// CacheStats counts the work of an in-process cache tier
+type CacheStats struct {
+	Entries       int   `json:"entries"`       // Entries held now
+	Hits          int64 `json:"hits"`          // Reads served in process
+	Misses        int64 `json:"misses"`        // Reads passed to the shared cache
+	Evictions     int64 `json:"evictions"`     // Entries dropped for the size limit
+	Invalidations int64 `json:"invalidations"` // Invalidations received from other instances
+}
+
+// LinkFilterStats describes a Bloom filter of existing links
+type LinkFilterStats struct {
+	Bits              uint64  `json:"bits"`                // Size of the filter
+	Hashes            uint64  `json:"hashes"`              // Bits set per link
+	Added             int64   `json:"added"`               // Links added, counting repeats
+	FalsePositiveRate float64 `json:"false_positive_rate"` // Estimated from how full the filter is
+	Checks            int64   `json:"checks"`              // Lookups that asked the filter
+	Rejected          int64   `json:"rejected"`            // Lookups answered by the filter alone
+}
+
+// BitmapRepository keeps bitmaps shared by every instance, numbering bits
+// from the most significant bit of the first byte
+type BitmapRepository interface {
+	SetBits(ctx context.Context, key string, offsets []uint64) error         // Set the given bits
+	HasBits(ctx context.Context, key string, offsets []uint64) (bool, error) // Report whether all the given bits are set
+	MergeBitmap(ctx context.Context, key string, bitmap []byte) error        // OR a whole bitmap into key
+	GetBitmap(ctx context.Context, key string) ([]byte, error)               // Get a bitmap; empty if it does not exist
+}
+
 type AnalyticsRepository interface {
 	RecordClick(ctx context.Context, analytics *URLAnalytics) error
-	GetClickCount(ctx context.Context, shortCode string) (int64, error)
-	GetDailyStats(ctx context.Context, shortCode string, days int) ([]DailyStat, error)
-	GetLastAccessed(ctx context.Context, shortCode string) (*time.Time, error)
+	GetClickCount(ctx context.Context, host, shortCode string) (int64, error)
+	GetDailyStats(ctx context.Context, host, shortCode string, days int) ([]DailyStat, error)
+	GetLastAccessed(ctx context.Context, host, shortCode string) (*time.Time, error)
+}
+
+type LinkHealthRepository interface {
+	ListURLsForHealthCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]*URL, error)       // Enabled, unexpired web links not checked since the cutoff
+	UpdateLinkHealth(ctx context.Context, host, shortCode string, health *LinkHealth, disable bool) error // Record a check, disabling the link if asked
+}
+
+type DomainRepository interface {
+	CreateDomain(ctx context.Context, d *Domain) error           // Register a branded domain
+	GetDomain(ctx context.Context, name string) (*Domain, error) // Get a registered domain by name
+	ListDomains(ctx context.Context) ([]*Domain, error)          // List registered domains
 }
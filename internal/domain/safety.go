@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Safety statuses of a link's destinations
+const (
+	SafetySafe      = "safe"
+	SafetyUnsafe    = "unsafe"
+	SafetyUnchecked = "unchecked" // reported by previews before the first successful scan
+)
+
+// Threat categories reported by scanners
+const (
+	ThreatMalware            = "malware"
+	ThreatPhishing           = "phishing"
+	ThreatUnwanted           = "unwanted_software"
+	ThreatBlocklisted        = "blocklisted"
+	ThreatIPLiteral          = "ip_literal"
+	ThreatHomograph          = "homograph"
+	ThreatExcessiveRedirects = "excessive_redirects"
+)
+
+// Threat is one problem a scanner found with a URL
+type Threat struct {
+	Provider string `json:"provider"`
+	Category string `json:"category"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// ScanResult collects the threats found for a URL; no threats means safe
+type ScanResult struct {
+	URL     string   `json:"url"`
+	Threats []Threat `json:"threats,omitempty"`
+}
+
+// Safe reports whether no scanner flagged the URL
+func (r *ScanResult) Safe() bool {
+	return r == nil || len(r.Threats) == 0
+}
+
+// String lists the threat categories, for logs and error messages
+func (r *ScanResult) String() string {
+	categories := make([]string, 0, len(r.Threats))
+	for _, threat := range r.Threats {
+		categories = append(categories, threat.Provider+":"+threat.Category)
+	}
+	return r.URL + " [" + strings.Join(categories, ", ") + "]"
+}
+
+// URLScanner checks a destination URL against a source of threat data.
+// An error means the scan could not be completed; threats found before the
+// error may still be reported in the result.
+type URLScanner interface {
+	Scan(ctx context.Context, rawURL string) (*ScanResult, error)
+}
+
+// DestinationPolicy decides whether a link may point at a destination at
+// all, such as refusing internal addresses or other short links
+type DestinationPolicy interface {
+	Allow(ctx context.Context, rawURL string) error
+}
+
+// SafetyRepository stores scan verdicts so links can be re-checked over time
+type SafetyRepository interface {
+	ListURLsForRescan(ctx context.Context, checkedBefore time.Time, limit int) ([]*URL, error)         // Links never scanned or scanned before the cutoff
+	UpdateSafetyStatus(ctx context.Context, host, shortCode, status string, checkedAt time.Time) error // Record a scan verdict
+}
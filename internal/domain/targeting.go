@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GeoRule sends visitors from Country (ISO 3166-1 alpha-2) to URL
+type GeoRule struct {
+	Country string `json:"country"`
+	URL     string `json:"url"`
+}
+
+// GeoRules is an ordered list of geo rules; the first matching rule wins
+type GeoRules []GeoRule
+
+// Match returns the destination of the first rule for country, if any
+func (r GeoRules) Match(country string) (string, bool) {
+	if country == "" {
+		return "", false
+	}
+	for _, rule := range r {
+		if strings.EqualFold(rule.Country, country) {
+			return rule.URL, true
+		}
+	}
+	return "", false
+}
+
+// Value stores the rules as a JSON array
+func (r GeoRules) Value() (driver.Value, error) { return jsonValue(r) }
+
+// Scan reads the rules from a JSON array
+func (r *GeoRules) Scan(src interface{}) error { return scanJSON(src, r) }
+
+// DeviceRule sends visitors whose client matches every non-empty field to
+// URL, which may be an app deep link such as myapp://product/42
+type DeviceRule struct {
+	OS      string `json:"os,omitempty"`      // ios, android, windows, macos, linux, chromeos, other
+	Device  string `json:"device,omitempty"`  // mobile, tablet, desktop
+	Browser string `json:"browser,omitempty"` // chrome, safari, firefox, edge, opera, samsung, other
+	URL     string `json:"url"`
+}
+
+// DeviceRules is an ordered list of device rules; the first matching rule wins
+type DeviceRules []DeviceRule
+
+// Match returns the destination of the first rule matching the client, if any
+func (r DeviceRules) Match(os, device, browser string) (string, bool) {
+	for _, rule := range r {
+		if matchField(rule.OS, os) && matchField(rule.Device, device) && matchField(rule.Browser, browser) {
+			return rule.URL, true
+		}
+	}
+	return "", false
+}
+
+// Value stores the rules as a JSON array
+func (r DeviceRules) Value() (driver.Value, error) { return jsonValue(r) }
+
+// Scan reads the rules from a JSON array
+func (r *DeviceRules) Scan(src interface{}) error { return scanJSON(src, r) }
+
+// matchField treats an empty rule field as a wildcard
+func matchField(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
+
+// Variant selection modes
+const (
+	VariantModeRandom = "random" // a fresh weighted pick on every visit
+	VariantModeSticky = "sticky" // the same visitor always gets the same variant
+)
+
+// Limits on variant weights, so their total always fits Pick's arithmetic
+const (
+	MaxVariantWeight      = 10000
+	MaxVariantTotalWeight = 1000000
+)
+
+// Variant is one weighted destination of an A/B split link
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// Variants are the destinations a split link rotates between
+type Variants []Variant
+
+// Pick returns the variant owning slot n of the cumulative weights, taken
+// modulo the total weight. It returns false when there are no variants.
+func (v Variants) Pick(n uint64) (Variant, bool) {
+	total := 0
+	for _, variant := range v {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return Variant{}, false
+	}
+
+	slot := int(n % uint64(total))
+	for _, variant := range v {
+		if slot < variant.Weight {
+			return variant, true
+		}
+		slot -= variant.Weight
+	}
+	return Variant{}, false
+}
+
+// Value stores the variants as a JSON array
+func (v Variants) Value() (driver.Value, error) { return jsonValue(v) }
+
+// Scan reads the variants from a JSON array
+func (v *Variants) Scan(src interface{}) error { return scanJSON(src, v) }
+
+// GeoLocator maps an IP address to an ISO 3166-1 alpha-2 country code
+type GeoLocator interface {
+	Country(ipAddress string) (string, error)
+}
+
+// jsonValue encodes v for a JSONB column, storing empty lists as []
+func jsonValue(v interface{}) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == "null" {
+		return []byte("[]"), nil
+	}
+	return data, nil
+}
+
+// scanJSON decodes a JSONB column into dest
+func scanJSON(src interface{}, dest interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+}
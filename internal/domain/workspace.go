@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Role is a member's or API key's permission level within a workspace
+type Role string
+
+const (
+	RoleOwner  Role = "owner"  // Manages members, API keys and domains
+	RoleEditor Role = "editor" // Creates links
+	RoleViewer Role = "viewer" // Reads links and analytics
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows reports whether r grants at least the permissions of required
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// Workspace owns links, domains, API keys and members. Quotas of zero are
+// unlimited.
+type Workspace struct {
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	MaxLinks    int64     `json:"max_links" db:"max_links"`         // Links the workspace may create
+	MaxAPICalls int64     `json:"max_api_calls" db:"max_api_calls"` // API requests per day
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Member is a user's role in a workspace; users are the user_id of their JWT
+type Member struct {
+	WorkspaceID int64     `json:"workspace_id" db:"workspace_id"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	Role        Role      `json:"role" db:"role"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// APIKey authenticates programs against one workspace. Only a hash of the
+// key is stored; Key is filled in once, when the key is created.
+type APIKey struct {
+	ID          int64     `json:"id" db:"id"`
+	WorkspaceID int64     `json:"workspace_id" db:"workspace_id"`
+	Name        string    `json:"name" db:"name"`
+	Prefix      string    `json:"prefix" db:"prefix"` // Start of the key, to tell keys apart
+	KeyHash     string    `json:"-" db:"key_hash"`
+	Role        Role      `json:"role" db:"role"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	Key         string    `json:"key,omitempty" db:"-"`
+}
+
+type WorkspaceRepository interface {
+	CreateWorkspace(ctx context.Context, ws *Workspace, ownerID string) error         // Create a workspace with its first owner
+	GetWorkspace(ctx context.Context, id int64) (*Workspace, error)                   // Get a workspace by ID
+	ListWorkspaces(ctx context.Context, userID string) ([]*Workspace, error)          // Workspaces the user is a member of
+	CountURLs(ctx context.Context, workspaceID int64) (int64, error)                  // Links owned by the workspace
+	SaveMember(ctx context.Context, m *Member) error                                  // Add a member or change their role
+	GetMember(ctx context.Context, workspaceID int64, userID string) (*Member, error) // Get a user's membership
+	ListMembers(ctx context.Context, workspaceID int64) ([]*Member, error)            // List a workspace's members
+	RemoveMember(ctx context.Context, workspaceID int64, userID string) error         // Remove a member
+	CreateAPIKey(ctx context.Context, key *APIKey) error                              // Store a new API key
+	GetAPIKey(ctx context.Context, keyHash string) (*APIKey, error)                   // Get an API key by its hash
+	ListAPIKeys(ctx context.Context, workspaceID int64) ([]*APIKey, error)            // List a workspace's API keys
+	DeleteAPIKey(ctx context.Context, workspaceID, id int64) error                    // Revoke an API key
+}
+
+type workspaceKey struct{}
+
+type workspaceScope struct {
+	id     int64
+	scoped bool
+}
+
+// WithWorkspace scopes repository queries and cache keys to a workspace.
+// Workspace 0 holds the links created without one.
+func WithWorkspace(ctx context.Context, workspaceID int64) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspaceScope{id: workspaceID, scoped: true})
+}
+
+// WithoutWorkspace lifts any workspace scope, for lookups that must see
+// every workspace such as public redirects
+func WithoutWorkspace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspaceScope{})
+}
+
+// WorkspaceFromContext returns the workspace a context is scoped to, and
+// false when it is not scoped
+func WorkspaceFromContext(ctx context.Context) (int64, bool) {
+	scope, _ := ctx.Value(workspaceKey{}).(workspaceScope)
+	return scope.id, scope.scoped
+}
@@ -0,0 +1,49 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// countryRecord is the subset of a GeoIP2/GeoLite2 record we read
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Reader resolves IP addresses to countries using a MaxMind-format database file
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+// Open loads the MaxMind database at path (GeoLite2-Country, GeoIP2-City, ...)
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &Reader{db: db}, nil
+}
+
+// Country returns the upper-case ISO 3166-1 alpha-2 code for ipAddress, or an
+// empty string when the address is not in the database.
+func (r *Reader) Country(ipAddress string) (string, error) {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address %q", ipAddress)
+	}
+
+	var record countryRecord
+	if err := r.db.Lookup(ip, &record); err != nil {
+		return "", fmt.Errorf("failed to look up IP address: %w", err)
+	}
+	return strings.ToUpper(record.Country.ISOCode), nil
+}
+
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
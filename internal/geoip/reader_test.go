@@ -0,0 +1,104 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestDatabase writes a minimal IPv4 MaxMind database that maps a single
+// network to a country, following the MaxMind DB format specification.
+func writeTestDatabase(t *testing.T, network string, isoCode string) string {
+	t.Helper()
+
+	_, ipNet, err := net.ParseCIDR(network)
+	require.NoError(t, err)
+	ones, _ := ipNet.Mask.Size()
+	prefix := binary.BigEndian.Uint32(ipNet.IP.To4())
+
+	// Search tree: one node per prefix bit, 24-bit records. A record equal to
+	// the node count means "no data"; larger values point into the data section.
+	nodeCount := uint32(ones)
+	var tree []byte
+	for i := uint32(0); i < nodeCount; i++ {
+		records := [2]uint32{nodeCount, nodeCount}
+		next := i + 1
+		if next == nodeCount {
+			next = nodeCount + 16 // offset 0 of the data section
+		}
+		records[(prefix>>(31-i))&1] = next
+		for _, r := range records {
+			tree = append(tree, byte(r>>16), byte(r>>8), byte(r))
+		}
+	}
+
+	str := func(s string) []byte { return append([]byte{0x40 | byte(len(s))}, s...) }
+	uint16Field := func(v uint16) []byte { return []byte{0xA0 | 2, byte(v >> 8), byte(v)} }
+
+	var data []byte
+	data = append(data, 0xE0|1)
+	data = append(data, str("country")...)
+	data = append(data, 0xE0|1)
+	data = append(data, str("iso_code")...)
+	data = append(data, str(isoCode)...)
+
+	var meta []byte
+	meta = append(meta, 0xE0|9)
+	meta = append(meta, str("binary_format_major_version")...)
+	meta = append(meta, uint16Field(2)...)
+	meta = append(meta, str("binary_format_minor_version")...)
+	meta = append(meta, uint16Field(0)...)
+	meta = append(meta, str("build_epoch")...)
+	meta = append(meta, 0x00|1, 0x02, 0x01) // uint64 (extended type 9)
+	meta = append(meta, str("database_type")...)
+	meta = append(meta, str("Test-Country")...)
+	meta = append(meta, str("description")...)
+	meta = append(meta, 0xE0|0)
+	meta = append(meta, str("ip_version")...)
+	meta = append(meta, uint16Field(4)...)
+	meta = append(meta, str("languages")...)
+	meta = append(meta, 0x00|0, 0x04) // empty array (extended type 11)
+	meta = append(meta, str("node_count")...)
+	meta = append(meta, 0xC0|4, byte(nodeCount>>24), byte(nodeCount>>16), byte(nodeCount>>8), byte(nodeCount))
+	meta = append(meta, str("record_size")...)
+	meta = append(meta, uint16Field(24)...)
+
+	var file []byte
+	file = append(file, tree...)
+	file = append(file, make([]byte, 16)...)
+	file = append(file, data...)
+	file = append(file, "\xAB\xCD\xEFMaxMind.com"...)
+	file = append(file, meta...)
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	require.NoError(t, os.WriteFile(path, file, 0644))
+	return path
+}
+
+func TestReader_Country(t *testing.T) {
+	reader, err := Open(writeTestDatabase(t, "81.2.69.0/24", "gb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	country, err := reader.Country("81.2.69.160")
+	require.NoError(t, err)
+	assert.Equal(t, "GB", country)
+
+	// Addresses outside the database resolve to no country
+	country, err = reader.Country("8.8.8.8")
+	require.NoError(t, err)
+	assert.Empty(t, country)
+
+	_, err = reader.Country("not-an-ip")
+	assert.Error(t, err)
+}
+
+func TestOpen_MissingFile(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
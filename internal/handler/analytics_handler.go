@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -34,14 +35,25 @@ func (h *AnalyticsHandler) GetAnalytics(c *gin.Context) {
 		}
 	}
 
-	analytics, err := h.analyticsService.GetAnalytics(c.Request.Context(), shortCode, days) // Get analytics data
+	analytics, err := h.analyticsService.GetAnalytics(c.Request.Context(), c.Query("domain"), shortCode, days) // Get analytics data
 	if err != nil {
-		h.logger.Error("Failed to get analytics", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
-			Error:   "Internal server error",
-			Message: "Failed to retrieve analytics",
-			Code:    http.StatusInternalServerError,
-		})
+		switch {
+		case errors.Is(err, domain.ErrNotFound): // The short URL does not exist
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error:   "URL not found",
+				Message: "The short URL does not exist",
+				Code:    http.StatusNotFound,
+			})
+		case errors.Is(err, domain.ErrUnavailable): // The database or cache cannot be reached
+			writeUnavailable(c, h.logger, err)
+		default:
+			h.logger.Error("Failed to get analytics", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+				Error:   "Internal server error",
+				Message: "Failed to retrieve analytics",
+				Code:    http.StatusInternalServerError,
+			})
+		}
 		return
 	}
 
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
+)
+
+type DomainHandler struct {
+	domainService *service.DomainService
+	logger        *zap.Logger
+}
+
+func NewDomainHandler(domainService *service.DomainService, logger *zap.Logger) *DomainHandler {
+	return &DomainHandler{
+		domainService: domainService,
+		logger:        logger,
+	}
+}
+
+type addDomainRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddDomain registers a branded domain for short links
+func (h *DomainHandler) AddDomain(c *gin.Context) {
+	var req addDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	d, err := h.domainService.AddDomain(c.Request.Context(), req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidDomain): // Not a host name, or the service's own
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Invalid domain",
+				Message: "The domain must be a valid host name other than the default one",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrDomainExists): // Registered before
+			c.JSON(http.StatusConflict, domain.ErrorResponse{
+				Error:   "Domain exists",
+				Message: "The domain is already registered",
+				Code:    http.StatusConflict,
+			})
+		case errors.Is(err, domain.ErrUnavailable): // The database or cache cannot be reached
+			writeUnavailable(c, h.logger, err)
+		default:
+			h.logger.Error("Failed to add domain", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+				Error:   "Internal server error",
+				Message: "Failed to add domain",
+				Code:    http.StatusInternalServerError,
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, d)
+}
+
+// ListDomains returns the registered branded domains
+func (h *DomainHandler) ListDomains(c *gin.Context) {
+	domains, err := h.domainService.ListDomains(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list domains", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error:   "Internal server error",
+			Message: "Failed to list domains",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, domains)
+}
@@ -9,9 +9,20 @@ import (
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
 )
 
+// cacheStatser is a cache with an in-process tier
+type cacheStatser interface {
+	Stats() domain.CacheStats
+}
+
+// linkFilterStatser is a Bloom filter of existing links
+type linkFilterStatser interface {
+	Stats() domain.LinkFilterStats
+}
+
 type HealthHandler struct {
-	urlRepo   domain.URLRepository
-	cacheRepo domain.CacheRepository
+	urlRepo    domain.URLRepository
+	cacheRepo  domain.CacheRepository
+	linkFilter linkFilterStatser // optional
 }
 
 // NewHealthHandler creates a new HealthHandler
@@ -22,6 +33,12 @@ func NewHealthHandler(urlRepo domain.URLRepository, cacheRepo domain.CacheReposi
 	}
 }
 
+// WithLinkFilter reports the filter's size and hit rate
+func (h *HealthHandler) WithLinkFilter(filter linkFilterStatser) *HealthHandler {
+	h.linkFilter = filter
+	return h
+}
+
 // HealthCheck handles the health check requests
 func (h *HealthHandler) HealthCheck(c *gin.Context) {
 	status := "healthy"
@@ -45,10 +62,17 @@ func (h *HealthHandler) HealthCheck(c *gin.Context) {
 		}
 	}
 
-	c.JSON(code, gin.H{
+	body := gin.H{
 		"status":    status,
 		"timestamp": time.Now().UTC(),
 		"database":  dbStatus,
 		"cache":     cacheStatus,
-	}) // Respond with the health check status
+	}
+	if local, ok := h.cacheRepo.(cacheStatser); ok { // Report the in-process tier, if any
+		body["local_cache"] = local.Stats()
+	}
+	if h.linkFilter != nil {
+		body["link_filter"] = h.linkFilter.Stats()
+	}
+	c.JSON(code, body) // Respond with the health check status
 }
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
+)
+
+const (
+	previewSuffix         = "+"               // "/abc123+" previews instead of redirecting
+	previewParam          = "preview"         // "/abc123?preview=1" does the same, except on links forwarding their query
+	confirmParam          = "confirm"         // set by the interstitial's continue link
+	variantParam          = "confirm_variant" // the A/B variant the interstitial showed
+	interstitialCountdown = 5                 // seconds before the interstitial moves on
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var (
+	previewTemplate      = template.Must(template.ParseFS(templateFS, "templates/preview.html"))
+	interstitialTemplate = template.Must(template.ParseFS(templateFS, "templates/interstitial.html"))
+	socialTemplate       = template.Must(template.ParseFS(templateFS, "templates/social.html"))
+)
+
+// interstitialPage is the data behind the "You are leaving" page
+type interstitialPage struct {
+	Host        string
+	Destination string
+	ContinueURL string
+	Countdown   int
+}
+
+// previewURL shows where a short link leads as HTML for browsers or JSON
+// for API clients. Previews never count as clicks. A preview asked for with
+// the query parameter is only shown for links that do not forward their
+// query, since the destination may expect that parameter; otherwise, and
+// when the link cannot be previewed, nothing is written and it reports false
+// so the visit is handled as a redirect.
+func (h *URLHandler) previewURL(c *gin.Context, host, shortCode string, byQuery bool) bool {
+	preview, err := h.urlService.PreviewURL(c.Request.Context(), host, strings.TrimSuffix(shortCode, previewSuffix))
+	if byQuery && (err != nil || preview.ForwardQuery) {
+		return false
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrURLNotFound): // The short URL does not exist
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error:   "URL not found",
+				Message: "The short URL does not exist",
+				Code:    http.StatusNotFound,
+			})
+		case errors.Is(err, service.ErrURLNotActive): // The short URL has not been launched yet
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error:   "URL not active",
+				Message: "The short URL is not active yet",
+				Code:    http.StatusNotFound,
+			})
+		case errors.Is(err, service.ErrURLExpired): // The short URL has expired
+			c.JSON(http.StatusGone, domain.ErrorResponse{
+				Error:   "URL expired",
+				Message: "The short URL has expired",
+				Code:    http.StatusGone,
+			})
+		case errors.Is(err, service.ErrUnsafeURL): // The destination was flagged after the link was created
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error:   "URL blocked",
+				Message: "The destination was flagged as malicious or deceptive",
+				Code:    http.StatusForbidden,
+			})
+		case errors.Is(err, service.ErrURLDisabled): // The destination stopped responding
+			c.JSON(http.StatusGone, domain.ErrorResponse{
+				Error:   "URL disabled",
+				Message: "The short URL was disabled because its destination no longer responds",
+				Code:    http.StatusGone,
+			})
+		case errors.Is(err, domain.ErrUnavailable): // The database or cache cannot be reached
+			writeUnavailable(c, h.logger, err)
+		default:
+			h.logger.Error("Failed to preview URL", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+				Error:   "Internal server error",
+				Message: "Failed to process request",
+				Code:    http.StatusInternalServerError,
+			})
+		}
+		return true
+	}
+
+	c.Header("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	// JSON is offered first so clients without a preference (curl, SDKs) get
+	// data while browsers, which ask for text/html, get the page
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		h.renderHTML(c, previewTemplate, preview)
+		return true
+	}
+	c.JSON(http.StatusOK, preview)
+	return true
+}
+
+// writeInterstitial shows the "You are leaving" page, whose continue link
+// repeats the request with the confirm parameter set. On split links it also
+// names the variant shown, signed so that continuing goes where the page said
+// and nowhere a visitor picks.
+func (h *URLHandler) writeInterstitial(c *gin.Context, redirect *domain.Redirect) {
+	query := c.Request.URL.Query()
+	query.Set(confirmParam, "1")
+	if redirect.VariantToken != "" {
+		query.Set(variantParam, redirect.VariantToken)
+	}
+	continueURL := (&url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}).String()
+
+	if redirect.VisitorID != "" {
+		setVisitorCookie(c, redirect.VisitorID)
+	}
+	c.Header("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	c.Header("Refresh", fmt.Sprintf("%d; url=%s", interstitialCountdown, continueURL))
+	h.renderHTML(c, interstitialTemplate, interstitialPage{
+		Host:        c.Request.Host,
+		Destination: redirect.Location,
+		ContinueURL: continueURL,
+		Countdown:   interstitialCountdown,
+	})
+}
+
+// renderHTML executes a page template into a 200 response
+func (h *URLHandler) renderHTML(c *gin.Context, tmpl *template.Template, data any) {
+	var page strings.Builder
+	if err := tmpl.Execute(&page, data); err != nil {
+		h.logger.Error("Failed to render page", zap.String("template", tmpl.Name()), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error:   "Internal server error",
+			Message: "Failed to process request",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page.String()))
+}
+
+// visitQuery strips the interstitial's confirm and variant parameters from
+// the query so they never reach the destination, reporting whether the visit
+// was confirmed and for which variant
+func visitQuery(rawQuery string) (string, bool, string) {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || !query.Has(confirmParam) {
+		return rawQuery, false, ""
+	}
+	confirmed := query.Get(confirmParam) == "1"
+	variant := query.Get(variantParam)
+	query.Del(confirmParam)
+	query.Del(variantParam)
+	return query.Encode(), confirmed, variant
+}
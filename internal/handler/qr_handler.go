@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/qr"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
+)
+
+type QRHandler struct {
+	qrService *service.QRService
+	logger    *zap.Logger
+}
+
+func NewQRHandler(qrService *service.QRService, logger *zap.Logger) *QRHandler {
+	return &QRHandler{
+		qrService: qrService,
+		logger:    logger,
+	}
+}
+
+// GenerateQRCode serves the QR code of a short link. Query parameters:
+// format (png or svg), size (pixels), margin (modules), level (L, M, Q, H),
+// fg and bg (hex colors), and domain for links on a branded domain.
+func (h *QRHandler) GenerateQRCode(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	opts, err := parseQROptions(c)
+	if err == nil {
+		err = opts.Validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:   "Invalid QR code options",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	image, err := h.qrService.GenerateQRCode(c.Request.Context(), c.Query("domain"), shortCode, opts) // Render or fetch the cached image
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrURLNotFound): // The short URL does not exist
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error:   "URL not found",
+				Message: "The short URL does not exist",
+				Code:    http.StatusNotFound,
+			})
+		case errors.Is(err, service.ErrURLExpired): // The short URL has expired
+			c.JSON(http.StatusGone, domain.ErrorResponse{
+				Error:   "URL expired",
+				Message: "The short URL has expired",
+				Code:    http.StatusGone,
+			})
+		case errors.Is(err, service.ErrURLNotActive): // The short URL has not been launched yet
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error:   "URL not active",
+				Message: "The short URL is not active yet",
+				Code:    http.StatusNotFound,
+			})
+		case errors.Is(err, service.ErrUnsafeURL): // The destination was flagged after the link was created
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error:   "URL blocked",
+				Message: "The destination was flagged as malicious or deceptive",
+				Code:    http.StatusForbidden,
+			})
+		case errors.Is(err, service.ErrURLDisabled): // The destination stopped responding
+			c.JSON(http.StatusGone, domain.ErrorResponse{
+				Error:   "URL disabled",
+				Message: "The short URL was disabled because its destination no longer responds",
+				Code:    http.StatusGone,
+			})
+		case errors.Is(err, domain.ErrUnavailable): // The database or cache cannot be reached
+			writeUnavailable(c, h.logger, err)
+		default:
+			h.logger.Error("Failed to generate QR code", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+				Error:   "Internal server error",
+				Message: "Failed to generate QR code",
+				Code:    http.StatusInternalServerError,
+			})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, opts.ContentType(), image)
+}
+
+// parseQROptions reads the rendering options, keeping defaults for anything unset
+func parseQROptions(c *gin.Context) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+	var err error
+
+	if format := c.Query("format"); format != "" {
+		opts.Format = format
+	}
+	if level := c.Query("level"); level != "" {
+		opts.Level = strings.ToUpper(level)
+	}
+	if size := c.Query("size"); size != "" {
+		if opts.Size, err = strconv.Atoi(size); err != nil {
+			return opts, qr.ErrInvalidSize
+		}
+	}
+	if margin := c.Query("margin"); margin != "" {
+		if opts.Margin, err = strconv.Atoi(margin); err != nil {
+			return opts, qr.ErrInvalidMargin
+		}
+	}
+	if fg := c.Query("fg"); fg != "" {
+		if opts.Foreground, err = qr.ParseColor(fg); err != nil {
+			return opts, err
+		}
+	}
+	if bg := c.Query("bg"); bg != "" {
+		if opts.Background, err = qr.ParseColor(bg); err != nil {
+			return opts, err
+		}
+	}
+	if opts.Foreground == opts.Background {
+		return opts, errors.New("foreground and background colors must differ")
+	}
+	return opts, nil
+}
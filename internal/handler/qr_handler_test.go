@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
+)
+
+func TestQRHandler_GenerateQRCode(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+
+	urlService := service.NewURLService(mockRepo, mockCache, logger, &config.Config{
+		Server: config.ServerConfig{BaseURL: "http://sho.rt"},
+	})
+	qrHandler := NewQRHandler(service.NewQRService(urlService, mockCache, logger, 0), logger)
+
+	router := setupGin()
+	router.GET("/api/v1/urls/:shortCode/qr", qrHandler.GenerateQRCode)
+
+	mockCache.On("Get", mock.Anything, "url:poster", mock.Anything).
+		Run(func(args mock.Arguments) {
+			arg := args.Get(2).(*domain.URL)
+			*arg = domain.URL{ShortCode: "poster", OriginalURL: "https://example.com", CreatedAt: time.Now()}
+		}).Return(nil)
+
+	t.Run("DefaultPNG", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/urls/poster/qr", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "\x89PNG"))
+	})
+
+	t.Run("StyledSVG", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/urls/poster/qr?format=svg&size=512&margin=2&level=h&fg=%23336699&bg=ffffff", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `width="512"`)
+		assert.Contains(t, w.Body.String(), `fill="#336699"`)
+	})
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		for _, query := range []string{"size=abc", "size=10", "level=Z", "fg=red", "format=gif", "fg=ffffff"} {
+			req := httptest.NewRequest("GET", "/api/v1/urls/poster/qr?"+query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("URLNotFound", func(t *testing.T) {
+		mockCache.On("Get", mock.Anything, "url:missing", mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "missing").Return(nil, domain.ErrNotFound)
+
+		req := httptest.NewRequest("GET", "/api/v1/urls/missing/qr", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// crawlerAgents are User-Agent fragments of the bots chat apps and social
+// networks send to unfurl shared links, lower-cased
+var crawlerAgents = []string{
+	"facebookexternalhit",
+	"facebot",
+	"twitterbot",
+	"slackbot",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"pinterest",
+	"redditbot",
+	"embedly",
+	"vkshare",
+	"iframely",
+	"mastodon",
+	"bluesky",
+	"google-pagerenderer", // Google Chat and Hangouts previews
+	"microsoftpreview",    // Teams and Outlook
+}
+
+// isCrawler reports whether the request comes from a link-unfurling bot
+func isCrawler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, agent := range crawlerAgents {
+		if strings.Contains(ua, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// socialCard answers a crawler with the link's Open Graph and Twitter card
+// tags instead of a redirect it may not be allowed to follow. It reports
+// false when the link has no card to show, leaving the request to the
+// normal redirect handling and its error responses.
+func (h *URLHandler) socialCard(c *gin.Context, host, shortCode string) bool {
+	card, err := h.urlService.SocialCard(c.Request.Context(), host, shortCode)
+	if err != nil {
+		return false
+	}
+	// Shared caches must not hand the crawler page to browsers
+	c.Header("Vary", "User-Agent")
+	c.Header("Cache-Control", "private, max-age=300")
+	h.renderHTML(c, socialTemplate, card)
+	return true
+}
@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -10,6 +13,11 @@ import (
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
 )
 
+const (
+	visitorCookie       = "_vid"             // Identifies visitors for sticky A/B splits
+	visitorCookieMaxAge = 365 * 24 * 60 * 60 // One year, in seconds
+)
+
 type URLHandler struct {
 	urlService *service.URLService
 	logger     *zap.Logger
@@ -37,19 +45,81 @@ func (h *URLHandler) ShortenURL(c *gin.Context) {
 
 	response, err := h.urlService.ShortenURL(c.Request.Context(), &req) // Call the URL shortening service
 	if err != nil {
-		switch err {
-		case service.ErrInvalidURL: // The provided URL is not valid or is blacklisted
+		switch {
+		case errors.Is(err, service.ErrInvalidURL): // The provided URL is not valid or is blacklisted
 			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
 				Error:   "Invalid URL",
 				Message: "The provided URL is not valid or is blacklisted",
 				Code:    http.StatusBadRequest,
 			})
-		case service.ErrCustomAliasTaken: // The custom alias is already in use
+		case errors.Is(err, service.ErrInvalidRedirectStatus): // The requested redirect status is not supported
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Invalid redirect status",
+				Message: "The redirect status must be one of 301, 302, 307 or 308",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrInvalidGeoRule): // A geo rule is missing its country or has a bad destination
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Invalid geo rule",
+				Message: "Geo rules need a two-letter country code and a valid URL",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrInvalidDeviceRule): // A device rule matches nothing or has a bad destination
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Invalid device rule",
+				Message: "Device rules need a known os, device or browser and a valid URL",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrDestinationNotAllowed): // An internal address or another short link
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Destination not allowed",
+				Message: "Destinations must be public addresses and not other short links",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrUnsafeURL): // A scanner flagged one of the destinations
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Unsafe URL",
+				Message: "The destination was flagged as malicious or deceptive",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrInvalidVariants): // The A/B split is malformed
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Invalid variants",
+				Message: "Variants need unique names, valid URLs, weights from 1 to 10000 and a random or sticky mode",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrInvalidSchedule): // The link would expire before it activates
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Invalid schedule",
+				Message: "The activation time must be before the expiration time",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrUnknownDomain): // The link was requested on a domain nobody registered
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error:   "Unknown domain",
+				Message: "The domain has not been registered",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, service.ErrLinkQuotaExceeded): // The workspace owns as many links as it may
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error:   "Link quota exceeded",
+				Message: "The workspace has reached its link quota",
+				Code:    http.StatusForbidden,
+			})
+		case errors.Is(err, service.ErrCustomAliasTaken), errors.Is(err, domain.ErrConflict): // The custom alias is already in use
 			c.JSON(http.StatusConflict, domain.ErrorResponse{
 				Error:   "Custom alias taken",
 				Message: "The custom alias is already in use",
 				Code:    http.StatusConflict,
 			})
+		case errors.Is(err, service.ErrCodesExhausted): // Every generated short code was taken
+			c.JSON(http.StatusServiceUnavailable, domain.ErrorResponse{
+				Error:   "Service unavailable",
+				Message: "No free short code could be generated, please try again later",
+				Code:    http.StatusServiceUnavailable,
+			})
+		case errors.Is(err, domain.ErrUnavailable): // The database or cache cannot be reached
+			writeUnavailable(c, h.logger, err)
 		default: // The provided URL is not valid or is blacklisted
 			h.logger.Error("Failed to shorten URL", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
@@ -65,23 +135,76 @@ func (h *URLHandler) ShortenURL(c *gin.Context) {
 }
 
 func (h *URLHandler) RedirectURL(c *gin.Context) {
-	shortCode := c.Param("shortCode") // Get the short code from the URL
+	shortCode := c.Param("shortCode")                                       // Get the short code from the URL
+	host := h.urlService.DomainForHost(c.Request.Context(), c.Request.Host) // Branded domains have their own short codes
+
+	// Inspect the link instead of following it
+	if strings.HasSuffix(shortCode, previewSuffix) {
+		h.previewURL(c, host, shortCode, false)
+		return
+	}
+	if c.Query(previewParam) == "1" && h.previewURL(c, host, shortCode, true) {
+		return
+	}
+	// Chat apps and social networks get the link's preview card; no click is counted
+	if isCrawler(c.Request.UserAgent()) && c.Param("path") == "" && h.socialCard(c, host, shortCode) {
+		return
+	}
+
+	rawQuery, confirmed, variant := visitQuery(c.Request.URL.RawQuery)
+	visit := &domain.Visit{
+		Path:      c.Param("path"), // Trailing segments when matched by the catch-all route
+		RawQuery:  rawQuery,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Referer:   c.Request.Referer(),
+		Confirmed: confirmed,
+		Variant:   variant,
+		Domain:    host,
+	}
+	if visitorID, err := c.Cookie(visitorCookie); err == nil { // Keeps sticky A/B assignments stable
+		visit.VisitorID = visitorID
+	}
 
-	originalURL, err := h.urlService.GetOriginalURL(c.Request.Context(), shortCode) // Get the original URL from the service
+	redirect, err := h.urlService.ResolveURL(c.Request.Context(), shortCode, visit) // Resolve the redirect from the service
 	if err != nil {
-		switch err {
-		case service.ErrURLNotFound: // The short URL does not exist
+		switch {
+		case errors.Is(err, service.ErrURLNotFound): // The short URL does not exist
 			c.JSON(http.StatusNotFound, domain.ErrorResponse{
 				Error:   "URL not found",
 				Message: "The short URL does not exist",
 				Code:    http.StatusNotFound,
 			})
-		case service.ErrURLExpired: // The short URL has expired
+		case errors.Is(err, service.ErrURLNotActive): // The short URL has not been launched yet
+			if redirect != nil { // Send visitors to the coming-soon page
+				writeRedirect(c, redirect)
+				return
+			}
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error:   "URL not active",
+				Message: "The short URL is not active yet",
+				Code:    http.StatusNotFound,
+			})
+		case errors.Is(err, service.ErrURLExpired): // The short URL has expired
 			c.JSON(http.StatusGone, domain.ErrorResponse{
 				Error:   "URL expired",
 				Message: "The short URL has expired",
 				Code:    http.StatusGone,
 			})
+		case errors.Is(err, service.ErrUnsafeURL): // The destination was flagged after the link was created
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error:   "URL blocked",
+				Message: "The destination was flagged as malicious or deceptive",
+				Code:    http.StatusForbidden,
+			})
+		case errors.Is(err, service.ErrURLDisabled): // The destination stopped responding
+			c.JSON(http.StatusGone, domain.ErrorResponse{
+				Error:   "URL disabled",
+				Message: "The short URL was disabled because its destination no longer responds",
+				Code:    http.StatusGone,
+			})
+		case errors.Is(err, domain.ErrUnavailable): // The database or cache cannot be reached
+			writeUnavailable(c, h.logger, err)
 		default: // The short URL is invalid
 			h.logger.Error("Failed to get original URL", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
@@ -93,5 +216,74 @@ func (h *URLHandler) RedirectURL(c *gin.Context) {
 		return
 	}
 
-	c.Redirect(http.StatusMovedPermanently, originalURL) // Redirect to the original URL
+	if redirect.Interstitial { // Warn the visitor before they leave
+		h.writeInterstitial(c, redirect)
+		return
+	}
+	writeRedirect(c, redirect) // Redirect to the original URL
+}
+
+// GetURL returns a link's settings and the latest check of its destination;
+// links on a branded domain are selected with the domain query parameter
+func (h *URLHandler) GetURL(c *gin.Context) {
+	response, err := h.urlService.GetLink(c.Request.Context(), c.Query("domain"), c.Param("shortCode"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrURLNotFound): // The short URL does not exist
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error:   "URL not found",
+				Message: "The short URL does not exist",
+				Code:    http.StatusNotFound,
+			})
+		case errors.Is(err, domain.ErrUnavailable): // The database or cache cannot be reached
+			writeUnavailable(c, h.logger, err)
+		default:
+			h.logger.Error("Failed to get URL", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+				Error:   "Internal server error",
+				Message: "Failed to process request",
+				Code:    http.StatusInternalServerError,
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// writeUnavailable answers a request that failed because the database or
+// cache is down, which clients may retry, unlike a missing link
+func writeUnavailable(c *gin.Context, logger *zap.Logger, err error) {
+	logger.Warn("Storage unavailable", zap.Error(err))
+	c.JSON(http.StatusServiceUnavailable, domain.ErrorResponse{
+		Error:   "Service unavailable",
+		Message: "The service is temporarily unavailable, please try again later",
+		Code:    http.StatusServiceUnavailable,
+	})
+}
+
+// writeRedirect sends the redirect with cache headers matching its status:
+// permanent redirects may be cached for their max age, temporary ones never.
+func writeRedirect(c *gin.Context, redirect *domain.Redirect) {
+	if redirect.VisitorID != "" {
+		setVisitorCookie(c, redirect.VisitorID)
+	}
+	if redirect.Permanent() && redirect.MaxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(redirect.MaxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	}
+	c.Redirect(redirect.StatusCode, redirect.Location)
+}
+
+// setVisitorCookie remembers the visitor so sticky splits survive IP changes
+func setVisitorCookie(c *gin.Context, visitorID string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     visitorCookie,
+		Value:    visitorID,
+		Path:     "/",
+		MaxAge:   visitorCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
 }
@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
@@ -46,7 +49,7 @@ func TestURLHandler_ShortenURL(t *testing.T) {
 
 	t.Run("SuccessfulShorten", func(t *testing.T) {
 		mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("not found"))
-		mockRepo.On("GetURLByOriginalURL", mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", mock.Anything).Return(nil, domain.ErrNotFound)
 		mockRepo.On("CreateURL", mock.Anything, mock.Anything).Return(nil)
 		mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
@@ -93,6 +96,29 @@ func TestURLHandler_ShortenURL(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+
+	t.Run("CodesExhausted", func(t *testing.T) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		urlService := service.NewURLService(mockRepo, mockCache, logger, &config.Config{
+			Snowflake: config.SnowflakeConfig{MachineID: 1},
+		})
+		router := setupGin()
+		router.POST("/shorten", NewURLHandler(urlService, logger).ShortenURL)
+
+		mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", mock.Anything).Return(nil, domain.ErrNotFound)
+		mockRepo.On("CreateURL", mock.Anything, mock.Anything).Return(fmt.Errorf("failed to insert URL: %w", domain.ErrConflict))
+
+		body, _ := json.Marshal(domain.ShortenRequest{URL: "https://example.com"})
+		req := httptest.NewRequest("POST", "/shorten", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "colliding generated codes are not a taken alias")
+	})
 }
 
 func TestURLHandler_RedirectURL(t *testing.T) {
@@ -105,6 +131,7 @@ func TestURLHandler_RedirectURL(t *testing.T) {
 
 	router := setupGin()
 	router.GET("/:shortCode", urlHandler.RedirectURL)
+	router.GET("/:shortCode/*path", urlHandler.RedirectURL)
 
 	t.Run("SuccessfulRedirect", func(t *testing.T) {
 		url := &domain.URL{
@@ -129,9 +156,33 @@ func TestURLHandler_RedirectURL(t *testing.T) {
 		assert.Equal(t, "https://example.com", w.Header().Get("Location"))
 	})
 
+	t.Run("TemporaryRedirectNotCached", func(t *testing.T) {
+		url := &domain.URL{
+			ShortCode:      "temp307",
+			OriginalURL:    "https://example.com/temporary",
+			CreatedAt:      time.Now(),
+			RedirectStatus: http.StatusTemporaryRedirect,
+		}
+
+		mockCache.On("Get", mock.Anything, "url:temp307", mock.Anything).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *url
+			}).Return(nil)
+
+		req := httptest.NewRequest("GET", "/temp307", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, "https://example.com/temporary", w.Header().Get("Location"))
+		assert.Contains(t, w.Header().Get("Cache-Control"), "no-store")
+	})
+
 	t.Run("URLNotFound", func(t *testing.T) {
 		mockCache.On("Get", mock.Anything, "url:notfound", mock.Anything).Return(errors.New("not found"))
-		mockRepo.On("GetURLByShortCode", mock.Anything, "notfound").Return(nil, errors.New("not found"))
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "notfound").Return(nil, domain.ErrNotFound)
 
 		req := httptest.NewRequest("GET", "/notfound", nil)
 		w := httptest.NewRecorder()
@@ -140,6 +191,305 @@ func TestURLHandler_RedirectURL(t *testing.T) {
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
+
+	t.Run("StorageUnavailable", func(t *testing.T) {
+		mockCache.On("Get", mock.Anything, "url:down", mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "down").Return(nil, fmt.Errorf("failed to get URL: %w", domain.ErrUnavailable))
+
+		req := httptest.NewRequest("GET", "/down", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "an outage is not a missing link")
+	})
+
+	t.Run("FlaggedURLBlocked", func(t *testing.T) {
+		url := &domain.URL{
+			ShortCode:    "flagged",
+			OriginalURL:  "https://example.com",
+			CreatedAt:    time.Now(),
+			SafetyStatus: domain.SafetyUnsafe,
+		}
+
+		mockCache.On("Get", mock.Anything, "url:flagged", mock.Anything).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *url
+			}).Return(nil)
+
+		req := httptest.NewRequest("GET", "/flagged", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+	})
+
+	t.Run("CrawlerGetsSocialCard", func(t *testing.T) {
+		url := &domain.URL{
+			ShortCode:   "shared",
+			OriginalURL: "https://example.com/launch",
+			CreatedAt:   time.Now(),
+			OGTitle:     "Launch <day>",
+			OGImage:     "https://example.com/cover.png",
+		}
+
+		mockCache.On("Get", mock.Anything, "url:shared", mock.Anything).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *url
+			}).Return(nil)
+
+		req := httptest.NewRequest("GET", "/shared", nil)
+		req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Equal(t, "User-Agent", w.Header().Get("Vary"))
+		assert.Contains(t, w.Body.String(), `<meta property="og:title" content="Launch &lt;day&gt;">`)
+		assert.Contains(t, w.Body.String(), `<meta property="og:image" content="https://example.com/cover.png">`)
+		assert.Contains(t, w.Body.String(), `<meta name="twitter:card" content="summary_large_image">`)
+
+		// Browsers following the same link are redirected
+		req = httptest.NewRequest("GET", "/shared", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Safari/605.1.15")
+		w = httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "https://example.com/launch", w.Header().Get("Location"))
+	})
+
+	t.Run("DisabledURLGone", func(t *testing.T) {
+		url := &domain.URL{
+			ShortCode:   "deadlink",
+			OriginalURL: "https://example.com/removed",
+			CreatedAt:   time.Now(),
+			Disabled:    true,
+		}
+
+		mockCache.On("Get", mock.Anything, "url:deadlink", mock.Anything).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *url
+			}).Return(nil)
+
+		req := httptest.NewRequest("GET", "/deadlink", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+	})
+
+	t.Run("QueryAndPathPassthrough", func(t *testing.T) {
+		url := &domain.URL{
+			ShortCode:    "docs",
+			OriginalURL:  "https://example.com/docs",
+			CreatedAt:    time.Now(),
+			ForwardQuery: true,
+			ForwardPath:  true,
+		}
+
+		mockCache.On("Get", mock.Anything, "url:docs", mock.Anything).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *url
+			}).Return(nil)
+
+		req := httptest.NewRequest("GET", "/docs/guide/intro?utm_source=x", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "https://example.com/docs/guide/intro?utm_source=x", w.Header().Get("Location"))
+	})
+
+	t.Run("StickyVariantSetsVisitorCookie", func(t *testing.T) {
+		url := &domain.URL{
+			ShortCode:   "split",
+			OriginalURL: "https://example.com",
+			CreatedAt:   time.Now(),
+			Variants: domain.Variants{
+				{Name: "a", URL: "https://a.example.com", Weight: 1},
+				{Name: "b", URL: "https://b.example.com", Weight: 1},
+			},
+			VariantMode: domain.VariantModeSticky,
+		}
+
+		mockCache.On("Get", mock.Anything, "url:split", mock.Anything).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *url
+			}).Return(nil)
+
+		req := httptest.NewRequest("GET", "/split", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "private, no-cache, no-store, must-revalidate", w.Header().Get("Cache-Control"))
+		cookies := w.Result().Cookies()
+		if assert.Len(t, cookies, 1) {
+			assert.Equal(t, "_vid", cookies[0].Name)
+
+			// Returning with the cookie lands on the same variant without a new one
+			again := httptest.NewRequest("GET", "/split", nil)
+			again.AddCookie(cookies[0])
+			w2 := httptest.NewRecorder()
+
+			router.ServeHTTP(w2, again)
+
+			assert.Equal(t, w.Header().Get("Location"), w2.Header().Get("Location"))
+			assert.Empty(t, w2.Result().Cookies())
+		}
+	})
+
+	t.Run("ComingSoonRedirect", func(t *testing.T) {
+		activatesAt := time.Now().Add(time.Hour)
+		url := &domain.URL{
+			ShortCode:     "launch",
+			OriginalURL:   "https://example.com/campaign",
+			CreatedAt:     time.Now(),
+			ActivatesAt:   &activatesAt,
+			ComingSoonURL: "https://example.com/coming-soon",
+		}
+
+		mockCache.On("Get", mock.Anything, "url:launch", mock.Anything).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *url
+			}).Return(nil)
+
+		req := httptest.NewRequest("GET", "/launch", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://example.com/coming-soon", w.Header().Get("Location"))
+		assert.Contains(t, w.Header().Get("Cache-Control"), "no-store")
+	})
+}
+
+func TestURLHandler_GetURL(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+
+	urlService := service.NewURLService(mockRepo, mockCache, logger, &config.Config{
+		Server: config.ServerConfig{BaseURL: "http://localhost:8080"},
+	})
+	urlHandler := NewURLHandler(urlService, logger)
+
+	router := setupGin()
+	router.GET("/api/v1/urls/:shortCode", urlHandler.GetURL)
+
+	t.Run("IncludesHealth", func(t *testing.T) {
+		checkedAt := time.Now().UTC().Truncate(time.Second)
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "abc123").Return(&domain.URL{
+			ShortCode:       "abc123",
+			OriginalURL:     "https://example.com",
+			CreatedAt:       time.Now(),
+			HealthStatus:    http.StatusOK,
+			HealthLatencyMs: 87,
+			HealthFinalURL:  "https://www.example.com/",
+			HealthCheckedAt: &checkedAt,
+		}, nil)
+
+		req := httptest.NewRequest("GET", "/api/v1/urls/abc123", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response domain.ShortenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "http://localhost:8080/abc123", response.ShortURL)
+		require.NotNil(t, response.Health)
+		assert.Equal(t, http.StatusOK, response.Health.Status)
+		assert.Equal(t, int64(87), response.Health.LatencyMs)
+		assert.Equal(t, "https://www.example.com/", response.Health.FinalURL)
+		assert.True(t, checkedAt.Equal(response.Health.CheckedAt))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "missing").Return(nil, domain.ErrNotFound)
+
+		req := httptest.NewRequest("GET", "/api/v1/urls/missing", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestURLHandler_BrandedDomain(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	domainRepo := new(mocks.MockDomainRepository)
+	logger := zaptest.NewLogger(t)
+
+	urlService := service.NewURLService(mockRepo, mockCache, logger, &config.Config{
+		Server: config.ServerConfig{BaseURL: "https://sho.rt"},
+	}, service.WithDomainRepository(domainRepo))
+	urlHandler := NewURLHandler(urlService, logger)
+
+	router := setupGin()
+	router.GET("/:shortCode", urlHandler.RedirectURL)
+	router.GET("/api/v1/urls/:shortCode", urlHandler.GetURL)
+
+	mockCache.On("Get", mock.Anything, "domain:go.brand.com", mock.Anything).Return(errors.New("not found"))
+	mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockCache.On("Increment", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	domainRepo.On("GetDomain", mock.Anything, "go.brand.com").Return(&domain.Domain{Name: "go.brand.com"}, nil)
+	for _, link := range []*domain.URL{
+		{ShortCode: "sale", OriginalURL: "https://example.com/default", CreatedAt: time.Now()},
+		{Domain: "go.brand.com", ShortCode: "sale", OriginalURL: "https://example.com/brand", CreatedAt: time.Now()},
+	} {
+		mockCache.On("Get", mock.Anything, "url:"+link.Key(), mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetURLByShortCode", mock.Anything, link.Domain, "sale").Return(link, nil)
+	}
+
+	t.Run("RedirectUsesHost", func(t *testing.T) {
+		for host, location := range map[string]string{
+			"sho.rt":          "https://example.com/default",
+			"go.brand.com":    "https://example.com/brand",
+			"GO.BRAND.COM:80": "https://example.com/brand",
+		} {
+			req := httptest.NewRequest("GET", "/sale", nil)
+			req.Host = host
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusMovedPermanently, w.Code, host)
+			assert.Equal(t, location, w.Header().Get("Location"), host)
+		}
+	})
+
+	t.Run("GetURLByDomain", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/urls/sale?domain=go.brand.com", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response domain.ShortenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "https://go.brand.com/sale", response.ShortURL)
+		assert.Equal(t, "go.brand.com", response.Domain)
+	})
 }
 
 func TestHealthHandler_HealthCheck(t *testing.T) {
@@ -211,7 +561,8 @@ func TestAnalyticsHandler_GetAnalytics(t *testing.T) {
 	mockCache := new(mocks.MockCacheRepository)
 	logger := zaptest.NewLogger(t)
 
-	analyticsService := service.NewAnalyticsService(mockRepo, mockCache, logger)
+	urlService := service.NewURLService(mockRepo, mockCache, logger, nil)
+	analyticsService := service.NewAnalyticsService(urlService, mockRepo, mockCache, logger)
 	analyticsHandler := NewAnalyticsHandler(analyticsService, logger)
 
 	router := setupGin()
@@ -248,14 +599,26 @@ func TestAnalyticsHandler_GetAnalytics(t *testing.T) {
 
 	t.Run("AnalyticsNotFound", func(t *testing.T) {
 		mockCache.On("Get", mock.Anything, "analytics:notfound:30", mock.Anything).Return(errors.New("not found"))
-		mockRepo.On("GetAnalytics", mock.Anything, "notfound", 30).Return(nil, errors.New("not found"))
+		mockRepo.On("GetAnalytics", mock.Anything, "", "notfound", 30).Return(nil, domain.ErrNotFound)
 
 		req := httptest.NewRequest("GET", "/analytics/notfound", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("StorageUnavailable", func(t *testing.T) {
+		mockCache.On("Get", mock.Anything, "analytics:down:30", mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetAnalytics", mock.Anything, "", "down", 30).Return(nil, fmt.Errorf("failed to get URL: %w", domain.ErrUnavailable))
+
+		req := httptest.NewRequest("GET", "/analytics/down", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 	})
 
 	t.Run("CustomDaysParameter", func(t *testing.T) {
@@ -281,3 +644,179 @@ func TestAnalyticsHandler_GetAnalytics(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 }
+
+func TestURLHandler_PreviewURL(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+
+	urlService := service.NewURLService(mockRepo, mockCache, logger, &config.Config{
+		Server: config.ServerConfig{BaseURL: "http://sho.rt"},
+		JWT:    config.JWTConfig{Secret: "test-secret"}, // Signs the interstitial's variant
+	})
+	urlHandler := NewURLHandler(urlService, logger)
+
+	router := setupGin()
+	router.GET("/:shortCode", urlHandler.RedirectURL)
+
+	url := &domain.URL{
+		ShortCode:    "peek",
+		OriginalURL:  "https://example.com/<landing>",
+		CreatedAt:    time.Date(2025, 8, 27, 10, 30, 0, 0, time.UTC),
+		Interstitial: true,
+		SafetyStatus: domain.SafetySafe,
+	}
+	mockCache.On("Get", mock.Anything, "url:peek", mock.Anything).
+		Run(func(args mock.Arguments) {
+			arg := args.Get(2).(*domain.URL)
+			*arg = *url
+		}).Return(nil)
+
+	t.Run("PlusSuffixJSON", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/peek+", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var preview domain.Preview
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+		assert.Equal(t, "http://sho.rt/peek", preview.ShortURL)
+		assert.Equal(t, "https://example.com/<landing>", preview.OriginalURL)
+		assert.Equal(t, domain.SafetySafe, preview.Safety)
+	})
+
+	t.Run("QueryParamHTML", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/peek?preview=1", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), "https://example.com/&lt;landing&gt;") // Escaped by html/template
+		assert.Contains(t, w.Body.String(), "27 Aug 2025")
+	})
+
+	t.Run("InterstitialThenConfirm", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/peek?ref=mail", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "You are leaving")
+		assert.Equal(t, "5; url=/peek?confirm=1&ref=mail", w.Header().Get("Refresh"))
+
+		mockCache.On("Increment", mock.Anything, "clicks:peek", int64(1)).Return(nil).Once()
+		req = httptest.NewRequest("GET", "/peek?confirm=1&ref=mail", nil)
+		w = httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "https://example.com/<landing>", w.Header().Get("Location"))
+
+		// Only the confirmed visit is counted
+		time.Sleep(100 * time.Millisecond)
+		mockCache.AssertNumberOfCalls(t, "Increment", 1)
+	})
+
+	t.Run("QueryParamForwarded", func(t *testing.T) {
+		forwarding := *url
+		forwarding.ShortCode, forwarding.Interstitial, forwarding.ForwardQuery = "fwd", false, true
+		mockCache.On("Get", mock.Anything, "url:fwd", mock.Anything).
+			Run(func(args mock.Arguments) { *args.Get(2).(*domain.URL) = forwarding }).
+			Return(nil)
+		mockCache.On("Increment", mock.Anything, "clicks:fwd", int64(1)).Return(nil).Once()
+
+		req := httptest.NewRequest("GET", "/fwd?preview=1", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code, "the parameter belongs to the destination")
+		assert.Equal(t, "https://example.com/%3Clanding%3E?preview=1", w.Header().Get("Location"))
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	t.Run("PreviewNotActive", func(t *testing.T) {
+		launch := time.Now().Add(time.Hour)
+		scheduled := *url
+		scheduled.ShortCode, scheduled.ActivatesAt = "soon", &launch
+		mockCache.On("Get", mock.Anything, "url:soon", mock.Anything).
+			Run(func(args mock.Arguments) { *args.Get(2).(*domain.URL) = scheduled }).
+			Return(nil)
+
+		req := httptest.NewRequest("GET", "/soon+", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NotContains(t, w.Body.String(), "example.com", "the destination is not revealed before launch")
+	})
+
+	t.Run("InterstitialKeepsVariant", func(t *testing.T) {
+		split := *url
+		split.ShortCode = "split"
+		split.Variants = domain.Variants{
+			{Name: "a", URL: "https://a.example.com", Weight: 1},
+			{Name: "b", URL: "https://b.example.com", Weight: 1},
+		}
+		mockCache.On("Get", mock.Anything, "url:split", mock.Anything).
+			Run(func(args mock.Arguments) { *args.Get(2).(*domain.URL) = split }).
+			Return(nil)
+		mockCache.On("Increment", mock.Anything, "clicks:split", int64(1)).Return(nil)
+
+		for i := 0; i < 10; i++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/split", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			variant := "a"
+			if strings.Contains(w.Body.String(), "https://b.example.com") {
+				variant = "b"
+			}
+			refresh := w.Header().Get("Refresh")
+			assert.Contains(t, refresh, "confirm_variant="+variant+".")
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", strings.TrimPrefix(refresh, "5; url="), nil))
+			assert.Equal(t, "https://"+variant+".example.com", w.Header().Get("Location"), "continuing goes where the page said")
+		}
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	t.Run("InterstitialIgnoresForgedVariant", func(t *testing.T) {
+		split := *url
+		split.ShortCode = "rigged"
+		split.Variants = domain.Variants{
+			{Name: "a", URL: "https://a.example.com", Weight: 1},
+			{Name: "b", URL: "https://b.example.com", Weight: 0}, // Never picked
+		}
+		mockCache.On("Get", mock.Anything, "url:rigged", mock.Anything).
+			Run(func(args mock.Arguments) { *args.Get(2).(*domain.URL) = split }).
+			Return(nil)
+		mockCache.On("Increment", mock.Anything, "clicks:rigged", int64(1)).Return(nil)
+
+		for _, variant := range []string{"b", "b.forged", "b.AAAAAAAAAAAAAAAAAAAAAA"} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/rigged?confirm=1&confirm_variant="+variant, nil))
+			assert.Equal(t, "https://a.example.com", w.Header().Get("Location"), variant)
+		}
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	t.Run("PreviewNotFound", func(t *testing.T) {
+		mockCache.On("Get", mock.Anything, "url:missing", mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "missing").Return(nil, domain.ErrNotFound)
+
+		req := httptest.NewRequest("GET", "/missing+", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
+)
+
+type WorkspaceHandler struct {
+	workspaceService *service.WorkspaceService
+	logger           *zap.Logger
+}
+
+func NewWorkspaceHandler(workspaceService *service.WorkspaceService, logger *zap.Logger) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+		logger:           logger,
+	}
+}
+
+type createWorkspaceRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type saveMemberRequest struct {
+	Role domain.Role `json:"role" binding:"required"`
+}
+
+type createAPIKeyRequest struct {
+	Name string      `json:"name"`
+	Role domain.Role `json:"role" binding:"required"`
+}
+
+// CreateWorkspace creates a workspace owned by the caller
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	var req createWorkspaceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ws, err := h.workspaceService.CreateWorkspace(c.Request.Context(), userID, req.Name)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, ws)
+}
+
+// ListWorkspaces returns the caller's workspaces
+func (h *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	workspaces, err := h.workspaceService.ListWorkspaces(c.Request.Context(), userID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, workspaces)
+}
+
+// ListMembers returns the members of a workspace
+func (h *WorkspaceHandler) ListMembers(c *gin.Context) {
+	userID, workspaceID, ok := h.target(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.workspaceService.ListMembers(c.Request.Context(), userID, workspaceID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// SaveMember adds a user to a workspace or changes their role
+func (h *WorkspaceHandler) SaveMember(c *gin.Context) {
+	userID, workspaceID, ok := h.target(c)
+	if !ok {
+		return
+	}
+	var req saveMemberRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	member, err := h.workspaceService.SaveMember(c.Request.Context(), userID, workspaceID, c.Param("userID"), req.Role)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember takes a user out of a workspace
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	userID, workspaceID, ok := h.target(c)
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(c.Request.Context(), userID, workspaceID, c.Param("userID")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateAPIKey issues an API key; the response is the only time the key is shown
+func (h *WorkspaceHandler) CreateAPIKey(c *gin.Context) {
+	userID, workspaceID, ok := h.target(c)
+	if !ok {
+		return
+	}
+	var req createAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	key, err := h.workspaceService.CreateAPIKey(c.Request.Context(), userID, workspaceID, req.Name, req.Role)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys returns a workspace's API keys without their secrets
+func (h *WorkspaceHandler) ListAPIKeys(c *gin.Context) {
+	userID, workspaceID, ok := h.target(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.workspaceService.ListAPIKeys(c.Request.Context(), userID, workspaceID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey deletes an API key
+func (h *WorkspaceHandler) RevokeAPIKey(c *gin.Context) {
+	userID, workspaceID, ok := h.target(c)
+	if !ok {
+		return
+	}
+	keyID, err := strconv.ParseInt(c.Param("keyID"), 10, 64)
+	if err != nil {
+		h.writeError(c, service.ErrAPIKeyNotFound)
+		return
+	}
+
+	if err := h.workspaceService.RevokeAPIKey(c.Request.Context(), userID, workspaceID, keyID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// target returns the calling user and the workspace named in the path
+func (h *WorkspaceHandler) target(c *gin.Context) (string, int64, bool) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return "", 0, false
+	}
+	workspaceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.writeError(c, service.ErrWorkspaceNotFound)
+		return "", 0, false
+	}
+	return userID, workspaceID, true
+}
+
+// requireUser returns the user_id of the caller's JWT. Workspaces are
+// managed by users, so API keys are refused here.
+func requireUser(c *gin.Context) (string, bool) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error:   "User token required",
+			Message: "Workspaces are managed with a user's token, not an API key",
+			Code:    http.StatusUnauthorized,
+		})
+		return "", false
+	}
+	return userID, true
+}
+
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return false
+	}
+	return true
+}
+
+func (h *WorkspaceHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrWorkspaceNotFound): // Missing, or the caller is not a member
+		c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error:   "Workspace not found",
+			Message: "The workspace does not exist or you are not a member",
+			Code:    http.StatusNotFound,
+		})
+	case errors.Is(err, service.ErrInsufficientRole):
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{
+			Error:   "Insufficient role",
+			Message: "Your role in the workspace does not allow this",
+			Code:    http.StatusForbidden,
+		})
+	case errors.Is(err, service.ErrInvalidWorkspace), errors.Is(err, service.ErrInvalidMember):
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+	case errors.Is(err, service.ErrLastOwner):
+		c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error:   "Last owner",
+			Message: "A workspace must keep at least one owner",
+			Code:    http.StatusConflict,
+		})
+	case errors.Is(err, service.ErrMemberNotFound), errors.Is(err, service.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+			Code:    http.StatusNotFound,
+		})
+	case errors.Is(err, domain.ErrUnavailable): // The database or cache cannot be reached
+		writeUnavailable(c, h.logger, err)
+	default:
+		h.logger.Error("Workspace request failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error:   "Internal server error",
+			Message: "Failed to process request",
+			Code:    http.StatusInternalServerError,
+		})
+	}
+}
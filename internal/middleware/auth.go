@@ -12,6 +12,11 @@ import (
 
 func JWTAuth(secret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if _, ok := c.Get(apiKeyContextKey); ok { // Already authenticated by the Workspace middleware
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
@@ -38,14 +43,8 @@ func JWTAuth(secret string) gin.HandlerFunc {
 		tokenString := parts[1]
 
 		// Parse and validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(secret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, ok := parseToken(tokenString, secret)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
 				Error:   "Invalid token",
 				Message: "The provided token is invalid or expired",
@@ -56,10 +55,26 @@ func JWTAuth(secret string) gin.HandlerFunc {
 		}
 
 		// Store claims in context if needed
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("user_claims", claims)
+		c.Set("user_claims", claims)
+		if userID, ok := claims["user_id"].(string); ok {
+			c.Set("user_id", userID)
 		}
 
 		c.Next()
 	}
 }
+
+// parseToken validates an HMAC-signed JWT and returns its claims
+func parseToken(tokenString, secret string) (jwt.MapClaims, bool) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(secret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
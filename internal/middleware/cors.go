@@ -15,7 +15,7 @@ func CORS() gin.HandlerFunc {
 
 		// Tell the browser what request headers it’s allowed to send
 		c.Header("Access-Control-Allow-Headers",
-			"Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+			"Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, X-Workspace-ID, accept, origin, Cache-Control, X-Requested-With")
 
 		// Tell the browser what HTTP methods are allowed
 		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
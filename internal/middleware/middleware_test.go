@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -8,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -98,3 +102,136 @@ func TestLoggerMiddleware(t *testing.T) {
 	require.Contains(t, entry.ContextMap()["client_ip"].(string), "127.0.0.1")
 	require.GreaterOrEqual(t, entry.ContextMap()["latency"].(time.Duration).Milliseconds(), int64(10))
 }
+
+type fakeResolver struct {
+	exhausted map[int64]bool
+}
+
+func (f *fakeResolver) AuthenticateAPIKey(ctx context.Context, key string) (*domain.APIKey, error) {
+	if key == "usk_outage" {
+		return nil, fmt.Errorf("failed to get API key: %w", domain.ErrUnavailable)
+	}
+	if key != "usk_viewer" {
+		return nil, errors.New("invalid API key")
+	}
+	return &domain.APIKey{WorkspaceID: 7, Role: domain.RoleViewer}, nil
+}
+
+func (f *fakeResolver) Membership(ctx context.Context, workspaceID int64, userID string) (*domain.Member, error) {
+	if userID != "alice" {
+		return nil, errors.New("workspace not found")
+	}
+	return &domain.Member{WorkspaceID: workspaceID, UserID: userID, Role: domain.RoleEditor}, nil
+}
+
+func (f *fakeResolver) SharedRole(userID string) domain.Role {
+	switch userID {
+	case "admin":
+		return domain.RoleOwner
+	case "":
+		return domain.RoleViewer
+	default:
+		return domain.RoleEditor
+	}
+}
+
+func (f *fakeResolver) CountAPICall(ctx context.Context, workspaceID int64) error {
+	if f.exhausted[workspaceID] {
+		return errors.New("daily API quota exceeded")
+	}
+	return nil
+}
+
+func TestWorkspace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := "test-secret"
+
+	router := gin.New()
+	router.Use(Workspace(&fakeResolver{exhausted: map[int64]bool{9: true}}, secret))
+	router.GET("/links", RequireRole(domain.RoleViewer), func(c *gin.Context) {
+		id, scoped := domain.WorkspaceFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"workspace": id, "scoped": scoped})
+	})
+	router.POST("/links", RequireRole(domain.RoleEditor), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	router.DELETE("/links", RequireRole(domain.RoleOwner), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	serve := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/links", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	alice, err := utils.GenerateJWT(secret, "alice", time.Hour)
+	require.NoError(t, err)
+	bob, err := utils.GenerateJWT(secret, "bob", time.Hour)
+	require.NoError(t, err)
+
+	t.Run("Anonymous", func(t *testing.T) {
+		w := serve(http.MethodGet, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"workspace":0,"scoped":true}`, w.Body.String())
+
+		w = serve(http.MethodPost, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code, "anyone is a viewer of the shared workspace")
+	})
+
+	t.Run("SharedWorkspace", func(t *testing.T) {
+		admin, err := utils.GenerateJWT(secret, "admin", time.Hour)
+		require.NoError(t, err)
+
+		w := serve(http.MethodPost, map[string]string{"Authorization": "Bearer " + bob})
+		assert.Equal(t, http.StatusCreated, w.Code, "signed-in users create links")
+		w = serve(http.MethodDelete, map[string]string{"Authorization": "Bearer " + bob})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = serve(http.MethodDelete, map[string]string{"Authorization": "Bearer " + admin})
+		assert.Equal(t, http.StatusNoContent, w.Code, "configured admins own it")
+
+		w = serve(http.MethodPost, map[string]string{"Authorization": "Bearer invalid"})
+		assert.Equal(t, http.StatusForbidden, w.Code, "an invalid token counts as none")
+	})
+
+	t.Run("APIKey", func(t *testing.T) {
+		w := serve(http.MethodGet, map[string]string{APIKeyHeader: "usk_viewer"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"workspace":7,"scoped":true}`, w.Body.String())
+
+		// Viewers cannot create links
+		w = serve(http.MethodPost, map[string]string{APIKeyHeader: "usk_viewer"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = serve(http.MethodGet, map[string]string{APIKeyHeader: "usk_revoked"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Member", func(t *testing.T) {
+		w := serve(http.MethodPost, map[string]string{"Authorization": "Bearer " + alice, WorkspaceHeader: "3"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = serve(http.MethodGet, map[string]string{"Authorization": "Bearer " + bob, WorkspaceHeader: "3"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = serve(http.MethodGet, map[string]string{WorkspaceHeader: "3"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = serve(http.MethodGet, map[string]string{"Authorization": "Bearer " + alice, WorkspaceHeader: "abc"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("QuotaExceeded", func(t *testing.T) {
+		w := serve(http.MethodGet, map[string]string{"Authorization": "Bearer " + alice, WorkspaceHeader: "9"})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("StorageUnavailable", func(t *testing.T) {
+		w := serve(http.MethodGet, map[string]string{APIKeyHeader: "usk_outage"})
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "an outage does not revoke keys")
+	})
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+const (
+	APIKeyHeader    = "X-API-Key"      // Authenticates a program against one workspace
+	WorkspaceHeader = "X-Workspace-ID" // Selects the workspace of a JWT-authenticated user
+
+	apiKeyContextKey = "api_key"
+	roleContextKey   = "workspace_role"
+)
+
+// WorkspaceResolver authenticates callers and meters their API usage
+type WorkspaceResolver interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*domain.APIKey, error)
+	Membership(ctx context.Context, workspaceID int64, userID string) (*domain.Member, error)
+	CountAPICall(ctx context.Context, workspaceID int64) error
+	SharedRole(userID string) domain.Role // Role in workspace 0 of a user, or of anyone for ""
+}
+
+// Workspace scopes the request to a workspace, taken from an API key or from
+// the X-Workspace-ID header of a user's JWT, and counts the call against the
+// workspace's daily quota. Requests with neither act in workspace 0, which
+// holds the links created without a workspace, with the role the resolver
+// gives their JWT's user or, without a valid JWT, anyone.
+func Workspace(resolver WorkspaceResolver, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var workspaceID int64
+		var role domain.Role
+
+		if raw := c.GetHeader(APIKeyHeader); raw != "" {
+			key, err := resolver.AuthenticateAPIKey(ctx, raw)
+			if unavailable(c, err) {
+				return
+			}
+			if err != nil {
+				abort(c, http.StatusUnauthorized, "Invalid API key", "The API key is invalid or has been revoked")
+				return
+			}
+			workspaceID, role = key.WorkspaceID, key.Role
+			c.Set(apiKeyContextKey, key)
+		} else if header := c.GetHeader(WorkspaceHeader); header != "" {
+			id, err := strconv.ParseInt(header, 10, 64)
+			if err != nil || id <= 0 {
+				abort(c, http.StatusBadRequest, "Invalid workspace", "The X-Workspace-ID header must be a workspace ID")
+				return
+			}
+			claims, ok := parseToken(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "), secret)
+			if !ok {
+				abort(c, http.StatusUnauthorized, "Authorization required", "A valid token is required to use a workspace")
+				return
+			}
+			userID, _ := claims["user_id"].(string)
+			member, err := resolver.Membership(ctx, id, userID)
+			if unavailable(c, err) {
+				return
+			}
+			if err != nil {
+				abort(c, http.StatusForbidden, "Workspace access denied", "You are not a member of this workspace")
+				return
+			}
+			workspaceID, role = id, member.Role
+		} else {
+			var userID string
+			if claims, ok := parseToken(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "), secret); ok {
+				userID, _ = claims["user_id"].(string)
+			}
+			role = resolver.SharedRole(userID)
+		}
+
+		if workspaceID != 0 {
+			if err := resolver.CountAPICall(ctx, workspaceID); unavailable(c, err) {
+				return
+			} else if err != nil {
+				abort(c, http.StatusTooManyRequests, "API quota exceeded", "The workspace has used up its daily API quota")
+				return
+			}
+		}
+
+		c.Set(roleContextKey, role)
+		c.Request = c.Request.WithContext(domain.WithWorkspace(ctx, workspaceID))
+		c.Next()
+	}
+}
+
+// RequireRole rejects callers whose workspace role is below required
+func RequireRole(required domain.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get(roleContextKey)
+		if r, ok := role.(domain.Role); !ok || !r.Allows(required) {
+			abort(c, http.StatusForbidden, "Insufficient role", "Your role in the workspace does not allow this")
+			return
+		}
+		c.Next()
+	}
+}
+
+// unavailable aborts with 503 when err means storage could not be reached,
+// so callers are not told their credentials are wrong during an outage
+func unavailable(c *gin.Context, err error) bool {
+	if !errors.Is(err, domain.ErrUnavailable) {
+		return false
+	}
+	abort(c, http.StatusServiceUnavailable, "Service unavailable", "Please try again later")
+	return true
+}
+
+func abort(c *gin.Context, status int, title, message string) {
+	c.JSON(status, domain.ErrorResponse{
+		Error:   title,
+		Message: message,
+		Code:    status,
+	})
+	c.Abort()
+}
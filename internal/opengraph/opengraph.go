@@ -0,0 +1,174 @@
+// Package opengraph reads the social preview metadata of web pages
+package opengraph
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+const (
+	userAgent       = "Mozilla/5.0 (compatible; URLShortener-Preview/1.0)"
+	defaultTimeout  = 5 * time.Second
+	defaultMaxBytes = 1 << 20 // Metadata lives in <head>; larger pages are cut off
+)
+
+// Fetcher downloads a page and extracts its Open Graph metadata, falling
+// back to Twitter card tags and then to the plain <title> and description
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewFetcher creates a fetcher that gives up on pages after timeout and
+// reads at most maxBytes of each; zero values use defaults
+func NewFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	return &Fetcher{
+		client:   &http.Client{Timeout: timeout},
+		maxBytes: maxBytes,
+	}
+}
+
+// WithTransport sets the transport used for requests, such as a
+// NetworkPolicy's, so fetches cannot be pointed at internal services
+func (f *Fetcher) WithTransport(transport http.RoundTripper) *Fetcher {
+	f.client.Transport = transport
+	return f
+}
+
+// FetchOpenGraph returns the metadata of the page at rawURL. Pages that are
+// not HTML have no metadata and return an empty result.
+func (f *Fetcher) FetchOpenGraph(ctx context.Context, rawURL string) (*domain.OpenGraph, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return &domain.OpenGraph{}, nil
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+	return Parse(body, resp.Request.URL)
+}
+
+// Parse extracts the metadata from an HTML document, resolving a relative
+// image against base
+func Parse(r io.Reader, base *url.URL) (*domain.OpenGraph, error) {
+	tags := make(map[string]string)
+	var title string
+
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return nil, fmt.Errorf("failed to parse page: %w", z.Err())
+			}
+			return build(tags, title, base), nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			switch token.Data {
+			case "meta":
+				key, content := metaTag(token)
+				if _, seen := tags[key]; key != "" && !seen {
+					tags[key] = content
+				}
+			case "title":
+				if title == "" && z.Next() == html.TextToken {
+					title = string(z.Text())
+				}
+			case "body":
+				// Metadata belongs in <head>; stop before reading the page itself
+				return build(tags, title, base), nil
+			}
+		}
+	}
+}
+
+// metaTag returns the lower-cased property or name of a <meta> tag and its content
+func metaTag(token html.Token) (string, string) {
+	var key, content string
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(attr.Val))
+			}
+		case "content":
+			content = attr.Val
+		}
+	}
+	return key, content
+}
+
+func build(tags map[string]string, title string, base *url.URL) *domain.OpenGraph {
+	og := &domain.OpenGraph{
+		Title:       clean(first(tags["og:title"], tags["twitter:title"], title), domain.MaxOGTitleLength),
+		Description: clean(first(tags["og:description"], tags["twitter:description"], tags["description"]), domain.MaxOGDescriptionLength),
+	}
+	if image := strings.TrimSpace(first(tags["og:image:secure_url"], tags["og:image"], tags["og:image:url"], tags["twitter:image"], tags["twitter:image:src"])); image != "" {
+		if ref, err := url.Parse(image); err == nil && base != nil {
+			ref = base.ResolveReference(ref)
+			if ref.Scheme == "http" || ref.Scheme == "https" {
+				og.Image = ref.String()
+			}
+		}
+	}
+	return og
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clean collapses whitespace and truncates s to at most limit bytes
+// without splitting a character
+func clean(s string, limit int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package opengraph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/articles/42")
+
+	t.Run("OpenGraphTags", func(t *testing.T) {
+		og, err := Parse(strings.NewReader(`<html><head>
+			<title>Fallback title</title>
+			<meta property="og:title" content="Launch day">
+			<meta property="og:description" content="  Everything you
+				need to know  ">
+			<meta property="og:image" content="/img/cover.png">
+			<meta name="twitter:title" content="Ignored">
+			</head><body><meta property="og:title" content="In body"></body></html>`), base)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Launch day", og.Title)
+		assert.Equal(t, "Everything you need to know", og.Description)
+		assert.Equal(t, "https://example.com/img/cover.png", og.Image)
+	})
+
+	t.Run("Fallbacks", func(t *testing.T) {
+		og, err := Parse(strings.NewReader(`<html><head>
+			<title>Tom &amp; Jerry</title>
+			<meta name="description" content="A classic">
+			<meta name="twitter:image" content="https://cdn.example.com/tj.jpg">
+			</head></html>`), base)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Tom & Jerry", og.Title)
+		assert.Equal(t, "A classic", og.Description)
+		assert.Equal(t, "https://cdn.example.com/tj.jpg", og.Image)
+	})
+
+	t.Run("RejectsNonWebImages", func(t *testing.T) {
+		og, err := Parse(strings.NewReader(`<meta property="og:image" content="javascript:alert(1)">`), base)
+
+		require.NoError(t, err)
+		assert.Empty(t, og.Image)
+	})
+
+	t.Run("TruncatesLongTitles", func(t *testing.T) {
+		og, err := Parse(strings.NewReader(`<title>`+strings.Repeat("é", 400)+`</title>`), base)
+
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(og.Title), domain.MaxOGTitleLength)
+		assert.True(t, strings.HasPrefix(og.Title, "éé"))
+	})
+}
+
+func TestFetcher_FetchOpenGraph(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<head><meta property="og:title" content="Hello"><meta property="og:image" content="cover.png"></head>`))
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.7"))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := NewFetcher(0, 0)
+	ctx := context.Background()
+
+	t.Run("FollowsRedirects", func(t *testing.T) {
+		og, err := fetcher.FetchOpenGraph(ctx, server.URL+"/old")
+
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", og.Title)
+		assert.Equal(t, server.URL+"/cover.png", og.Image) // Resolved against the final URL
+	})
+
+	t.Run("NonHTML", func(t *testing.T) {
+		og, err := fetcher.FetchOpenGraph(ctx, server.URL+"/file.pdf")
+
+		require.NoError(t, err)
+		assert.True(t, og.Empty())
+	})
+
+	t.Run("ErrorStatus", func(t *testing.T) {
+		_, err := fetcher.FetchOpenGraph(ctx, server.URL+"/missing")
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,202 @@
+// Package qr renders QR codes for short links as PNG or SVG images.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Formats supported by Encode
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Limits on the rendering options
+const (
+	MinSize   = 64
+	MaxSize   = 2048
+	MaxMargin = 16
+)
+
+var (
+	ErrInvalidFormat = errors.New("format must be png or svg")
+	ErrInvalidSize   = fmt.Errorf("size must be between %d and %d pixels", MinSize, MaxSize)
+	ErrInvalidMargin = fmt.Errorf("margin must be between 0 and %d modules", MaxMargin)
+	ErrInvalidLevel  = errors.New("error correction level must be L, M, Q or H")
+	ErrInvalidColor  = errors.New("colors must be 6-digit hex values such as 000000")
+)
+
+// Options control how a QR code is rendered
+type Options struct {
+	Format     string     // FormatPNG or FormatSVG
+	Size       int        // width and height in pixels
+	Margin     int        // quiet zone around the code, in modules
+	Level      string     // error correction: L, M, Q or H
+	Foreground color.RGBA // dark modules
+	Background color.RGBA // light modules and the quiet zone
+}
+
+// DefaultOptions returns a black-on-white 256px PNG with the standard
+// four-module quiet zone and medium error correction
+func DefaultOptions() Options {
+	return Options{
+		Format:     FormatPNG,
+		Size:       256,
+		Margin:     4,
+		Level:      "M",
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// Validate checks the options are within the supported limits
+func (o Options) Validate() error {
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return ErrInvalidFormat
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return ErrInvalidSize
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return ErrInvalidMargin
+	}
+	if _, err := recoveryLevel(o.Level); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Key identifies the rendered image for caching
+func (o Options) Key() string {
+	return fmt.Sprintf("%s:%d:%d:%s:%s:%s", o.Format, o.Size, o.Margin, o.Level,
+		FormatColor(o.Foreground), FormatColor(o.Background))
+}
+
+// ContentType is the MIME type of the rendered image
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// ParseColor reads a 6-digit hex color, with or without a leading '#'
+func ParseColor(value string) (color.RGBA, error) {
+	value = strings.TrimPrefix(value, "#")
+	if len(value) != 6 {
+		return color.RGBA{}, ErrInvalidColor
+	}
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return color.RGBA{}, ErrInvalidColor
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
+
+// FormatColor writes a color as 6 lower-case hex digits
+func FormatColor(c color.RGBA) string {
+	return fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Encode renders content as a QR code image
+func Encode(content string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := recoveryLevel(opts.Level)
+
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	code.DisableBorder = true // The margin is drawn here so it can be sized
+	modules := code.Bitmap()
+
+	if opts.Format == FormatSVG {
+		return renderSVG(modules, opts), nil
+	}
+	return renderPNG(modules, opts)
+}
+
+func recoveryLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qrcode.Low, nil
+	case "M":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	}
+	return 0, ErrInvalidLevel
+}
+
+// layout works out the pixels per module and the offset that centres the
+// code, margin included, in the requested size
+func layout(count int, opts Options) (scale, offset, size int) {
+	total := count + 2*opts.Margin
+	scale = max(opts.Size/total, 1)
+	size = max(opts.Size, scale*total)
+	offset = (size - scale*count) / 2
+	return scale, offset, size
+}
+
+func renderPNG(modules [][]bool, opts Options) ([]byte, error) {
+	scale, offset, size := layout(len(modules), opts)
+
+	palette := color.Palette{opts.Background, opts.Foreground}
+	img := image.NewPaletted(image.Rect(0, 0, size, size), palette) // Index 0 is the background
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := offset + y*scale; py < offset+(y+1)*scale; py++ {
+				for px := offset + x*scale; px < offset+(x+1)*scale; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func renderSVG(modules [][]bool, opts Options) []byte {
+	scale, offset, size := layout(len(modules), opts)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, size, size)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#%s"/>`, size, size, FormatColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="#%s" d="`, FormatColor(opts.Foreground))
+	for y, row := range modules {
+		// One sub-path per horizontal run of dark modules keeps the file small
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv%dh-%dz", offset+start*scale, offset+y*scale, (x-start)*scale, scale, (x-start)*scale)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
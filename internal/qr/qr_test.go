@@ -0,0 +1,81 @@
+package qr
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodePNG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Size = 300
+	opts.Foreground = color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}
+
+	data, err := Encode("http://localhost:8080/abc123", opts)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	// The corner sits in the quiet zone; the top-left finder pattern starts right after it
+	scale, offset, _ := layout(21, opts) // Version 1 codes are 21 modules wide
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, color.RGBAModel.Convert(img.At(0, 0)))
+	assert.Equal(t, opts.Foreground, color.RGBAModel.Convert(img.At(offset+scale/2, offset+scale/2)))
+}
+
+func TestEncodeSVG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Format = FormatSVG
+	opts.Background = color.RGBA{R: 0xff, G: 0xee, B: 0xdd, A: 0xff}
+
+	data, err := Encode("http://localhost:8080/abc123", opts)
+	require.NoError(t, err)
+
+	svg := string(data)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256"`))
+	assert.Contains(t, svg, `fill="#ffeedd"`)
+	assert.Contains(t, svg, `fill="#000000"`)
+	assert.True(t, strings.HasSuffix(svg, "</svg>"))
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*Options)
+		want   error
+	}{
+		{"Defaults", func(*Options) {}, nil},
+		{"Format", func(o *Options) { o.Format = "gif" }, ErrInvalidFormat},
+		{"TooSmall", func(o *Options) { o.Size = 10 }, ErrInvalidSize},
+		{"TooLarge", func(o *Options) { o.Size = 5000 }, ErrInvalidSize},
+		{"Margin", func(o *Options) { o.Margin = -1 }, ErrInvalidMargin},
+		{"Level", func(o *Options) { o.Level = "X" }, ErrInvalidLevel},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tc.modify(&opts)
+			assert.Equal(t, tc.want, opts.Validate())
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#1a2B3c")
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}, c)
+	assert.Equal(t, "1a2b3c", FormatColor(c))
+
+	for _, bad := range []string{"", "fff", "zzzzzz", "1234567"} {
+		_, err := ParseColor(bad)
+		assert.Equal(t, ErrInvalidColor, err, bad)
+	}
+}
@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// Blocklist flags destinations on blocked domains. Entries match the host
+// exactly; entries written as "*.example.com" or ".example.com" also match
+// every subdomain.
+type Blocklist struct {
+	exact  map[string]bool
+	suffix map[string]bool
+}
+
+// NewBlocklist builds a blocklist from domain entries
+func NewBlocklist(entries ...string) *Blocklist {
+	b := &Blocklist{exact: map[string]bool{}, suffix: map[string]bool{}}
+	for _, entry := range entries {
+		b.Add(entry)
+	}
+	return b
+}
+
+// LoadBlocklist reads one entry per line; blank lines and lines starting
+// with '#' are ignored
+func LoadBlocklist(path string) (*Blocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer file.Close()
+
+	b := NewBlocklist()
+	lines := bufio.NewScanner(file)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b.Add(line)
+	}
+	if err := lines.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return b, nil
+}
+
+// Add blocks a domain, or a domain and its subdomains for wildcard entries
+func (b *Blocklist) Add(entry string) {
+	entry = normalizeHost(entry)
+	if rest, ok := strings.CutPrefix(entry, "*."); ok {
+		b.suffix[rest] = true
+	} else if rest, ok := strings.CutPrefix(entry, "."); ok {
+		b.suffix[rest] = true
+	} else if entry != "" {
+		b.exact[entry] = true
+	}
+}
+
+// Len returns the number of entries
+func (b *Blocklist) Len() int {
+	return len(b.exact) + len(b.suffix)
+}
+
+// Blocked reports whether a host is on the list
+func (b *Blocklist) Blocked(host string) bool {
+	host = normalizeHost(host)
+	if b.exact[host] || b.suffix[host] {
+		return true
+	}
+	// Walk up the labels: a.b.example.com, b.example.com, example.com
+	for rest := host; ; {
+		_, parent, ok := strings.Cut(rest, ".")
+		if !ok {
+			return false
+		}
+		if b.suffix[parent] {
+			return true
+		}
+		rest = parent
+	}
+}
+
+func (b *Blocklist) Scan(ctx context.Context, rawURL string) (*domain.ScanResult, error) {
+	result := &domain.ScanResult{URL: rawURL}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return result, nil
+	}
+	if b.Blocked(u.Hostname()) {
+		result.Threats = append(result.Threats, domain.Threat{
+			Provider: "blocklist",
+			Category: domain.ThreatBlocklisted,
+			Detail:   normalizeHost(u.Hostname()),
+		})
+	}
+	return result, nil
+}
+
+// normalizeHost lower-cases a host and drops the trailing dot of a fully
+// qualified name so "Evil.COM." and "evil.com" match
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
@@ -0,0 +1,159 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/idna"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/utils"
+)
+
+// numericHost matches hosts browsers read as IPv4 addresses even though
+// they are not dotted quads, such as 2130706433 or 0x7f.1
+var numericHost = regexp.MustCompile(`^(0x[0-9a-f]+|[0-9]+)(\.(0x[0-9a-f]+|[0-9]+))*$`)
+
+// lookalikeScripts are the scripts whose letters are routinely passed off as Latin
+var lookalikeScripts = []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic, unicode.Greek, unicode.Armenian}
+
+var errTooManyRedirects = errors.New("too many redirects")
+
+// Heuristics flags destinations that look like attempts to hide where a
+// link leads: IP-literal hosts, internationalized names that mix scripts to
+// imitate another domain, and long redirect chains
+type Heuristics struct {
+	maxRedirects int // 0 skips following redirects
+	client       *http.Client
+}
+
+// NewHeuristics creates the heuristic checks. Destinations that redirect
+// more than maxRedirects times are flagged; 0 disables that check, which
+// is the only one that makes network requests.
+func NewHeuristics(maxRedirects int, timeout time.Duration) *Heuristics {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	h := &Heuristics{maxRedirects: maxRedirects}
+	h.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > h.maxRedirects {
+				return errTooManyRedirects
+			}
+			return nil
+		},
+	}
+	return h
+}
+
+// WithTransport sets the transport used to follow redirects, such as a
+// NetworkPolicy's, so the scan itself cannot be pointed at internal services
+func (h *Heuristics) WithTransport(transport http.RoundTripper) *Heuristics {
+	h.client.Transport = transport
+	return h
+}
+
+func (h *Heuristics) Scan(ctx context.Context, rawURL string) (*domain.ScanResult, error) {
+	result := &domain.ScanResult{URL: rawURL}
+	u, err := url.Parse(rawURL)
+	if err != nil || !utils.IsWebURL(rawURL) {
+		return result, nil
+	}
+	host := normalizeHost(u.Hostname())
+
+	if net.ParseIP(host) != nil || numericHost.MatchString(host) {
+		result.Threats = append(result.Threats, domain.Threat{
+			Provider: "heuristics",
+			Category: domain.ThreatIPLiteral,
+			Detail:   host,
+		})
+	}
+	if label, ok := homographLabel(host); ok {
+		result.Threats = append(result.Threats, domain.Threat{
+			Provider: "heuristics",
+			Category: domain.ThreatHomograph,
+			Detail:   label,
+		})
+	}
+
+	if h.maxRedirects > 0 {
+		hops, err := h.redirectHops(ctx, rawURL)
+		if errors.Is(err, errTooManyRedirects) {
+			result.Threats = append(result.Threats, domain.Threat{
+				Provider: "heuristics",
+				Category: domain.ThreatExcessiveRedirects,
+				Detail:   fmt.Sprintf("more than %d redirects", h.maxRedirects),
+			})
+		} else if err != nil {
+			return result, fmt.Errorf("failed to follow redirects after %d hops: %w", hops, err)
+		}
+	}
+	return result, nil
+}
+
+// redirectHops follows the destination's redirects with HEAD requests
+func (h *Heuristics) redirectHops(ctx context.Context, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "go-url-shortener-scanner/1.0")
+
+	resp, err := h.client.Do(req)
+	hops := 0
+	if resp != nil {
+		resp.Body.Close()
+		for r := resp.Request; r != nil && r.Response != nil; r = r.Response.Request {
+			hops++
+		}
+	}
+	return hops, err
+}
+
+// homographLabel returns the decoded form of the first punycode label that
+// mixes lookalike scripts, such as Latin with Cyrillic
+func homographLabel(host string) (string, bool) {
+	for _, label := range strings.Split(host, ".") {
+		if !strings.HasPrefix(label, "xn--") {
+			continue
+		}
+		decoded, err := idna.Punycode.ToUnicode(label)
+		if err != nil {
+			return label, true // Malformed punycode has no business in a destination
+		}
+		if mixedScripts(decoded) {
+			return decoded, true
+		}
+	}
+	return "", false
+}
+
+// mixedScripts reports whether the letters of s come from more than one of
+// the lookalike scripts
+func mixedScripts(s string) bool {
+	var seen *unicode.RangeTable
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for _, script := range lookalikeScripts {
+			if !unicode.Is(script, r) {
+				continue
+			}
+			if seen != nil && seen != script {
+				return true
+			}
+			seen = script
+		}
+	}
+	return false
+}
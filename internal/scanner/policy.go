@@ -0,0 +1,137 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/utils"
+)
+
+var (
+	ErrPrivateDestination = errors.New("destination resolves to a private, loopback, link-local or multicast address")
+	ErrNestedShortener    = errors.New("destination is another short link")
+)
+
+// DefaultShortenerDomains are public URL shorteners whose links are not
+// accepted as destinations, to stop redirect chains hiding the real target
+var DefaultShortenerDomains = []string{
+	"bit.ly", "bitly.com", "tinyurl.com", "t.co", "goo.gl", "ow.ly", "is.gd",
+	"buff.ly", "rebrand.ly", "cutt.ly", "shorturl.at", "rb.gy", "tiny.cc", "s.id",
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal
+// to providers but not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Resolver looks up the addresses of a host; *net.Resolver satisfies it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// NetworkPolicy keeps links from pointing at internal services: destinations
+// must resolve to public addresses, unless an allowlisted network covers
+// them, and must not be short links themselves
+type NetworkPolicy struct {
+	allowed    []*net.IPNet
+	shorteners *Blocklist
+	resolver   Resolver
+}
+
+// NewNetworkPolicy builds a policy. allowedNetworks are CIDRs that may be
+// used even though they are internal; shortenerDomains, and their
+// subdomains, are rejected as destinations.
+func NewNetworkPolicy(allowedNetworks, shortenerDomains []string, resolver Resolver) (*NetworkPolicy, error) {
+	p := &NetworkPolicy{shorteners: NewBlocklist(), resolver: resolver}
+	if p.resolver == nil {
+		p.resolver = net.DefaultResolver
+	}
+	for _, cidr := range allowedNetworks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed network %q: %w", cidr, err)
+		}
+		p.allowed = append(p.allowed, network)
+	}
+	for _, domain := range shortenerDomains {
+		p.shorteners.Add("*." + strings.TrimPrefix(domain, "*."))
+	}
+	return p, nil
+}
+
+// Allow checks a destination. Hosts that cannot be resolved are let
+// through: they cannot be reached now, and Transport re-checks every
+// connection when one is made.
+func (p *NetworkPolicy) Allow(ctx context.Context, rawURL string) error {
+	if !utils.IsWebURL(rawURL) { // Deep links open apps, they are never fetched
+		return nil
+	}
+	u, _ := url.Parse(rawURL)
+	host := normalizeHost(u.Hostname())
+
+	if p.shorteners.Blocked(host) {
+		return ErrNestedShortener
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return p.allowIP(ip)
+	}
+	addrs, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if err := p.allowIP(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *NetworkPolicy) allowIP(ip net.IP) error {
+	for _, network := range p.allowed {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return ErrPrivateDestination
+	}
+	return nil
+}
+
+// DialControl rejects connections to addresses the policy forbids. Used as
+// net.Dialer.Control it runs after DNS resolution, so redirects and DNS
+// rebinding cannot reach internal services either.
+func (p *NetworkPolicy) DialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("unexpected dial address %q", address)
+	}
+	return p.allowIP(ip)
+}
+
+// Transport returns an HTTP transport for fetching destinations whose
+// connections pass DialControl. Proxies are not used, since the policy
+// must see the address actually dialled.
+func (p *NetworkPolicy) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.DialControl,
+	}).DialContext
+	return transport
+}
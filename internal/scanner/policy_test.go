@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubResolver answers lookups from a fixed table
+type stubResolver map[string][]string
+
+func (r stubResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func TestNetworkPolicy_Allow(t *testing.T) {
+	resolver := stubResolver{
+		"example.com":       {"93.184.215.14", "2606:2800:21f:cb07:6820:80da:af6b:8b2c"},
+		"internal.corp":     {"10.0.0.5"},
+		"rebind.example":    {"93.184.215.14", "127.0.0.1"}, // Any private answer is enough
+		"metadata.example":  {"169.254.169.254"},
+		"allowed.corp":      {"10.20.1.1"},
+		"cgnat.example":     {"100.64.0.1"},
+		"multicast.example": {"224.0.0.1"},
+	}
+	policy, err := NewNetworkPolicy([]string{"10.20.0.0/16"}, []string{"bit.ly", "sho.rt"}, resolver)
+	require.NoError(t, err)
+
+	cases := []struct {
+		url  string
+		want error
+	}{
+		{"https://example.com/page", nil},
+		{"http://127.0.0.1:6379", ErrPrivateDestination},
+		{"http://[::1]/", ErrPrivateDestination},
+		{"http://169.254.169.254/latest/meta-data/", ErrPrivateDestination},
+		{"http://0.0.0.0/", ErrPrivateDestination},
+		{"https://internal.corp/admin", ErrPrivateDestination},
+		{"https://rebind.example/", ErrPrivateDestination},
+		{"https://metadata.example/", ErrPrivateDestination},
+		{"https://cgnat.example/", ErrPrivateDestination},
+		{"https://multicast.example/", ErrPrivateDestination},
+		{"https://allowed.corp/wiki", nil},              // Allowlisted network
+		{"http://10.20.3.4/", nil},                      // Allowlisted literal
+		{"https://bit.ly/abc", ErrNestedShortener},      // Known shortener
+		{"https://j.mp.bit.ly/abc", ErrNestedShortener}, // ...and its subdomains
+		{"https://SHO.RT./abc123", ErrNestedShortener},  // Our own domain
+		{"https://notbit.ly/abc", nil},                  // Unresolvable, not a shortener
+		{"myapp://product/42", nil},                     // Deep links are never fetched
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, policy.Allow(context.Background(), tc.url), tc.url)
+	}
+}
+
+func TestNetworkPolicy_InvalidNetwork(t *testing.T) {
+	_, err := NewNetworkPolicy([]string{"10.0.0.0/33"}, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestNetworkPolicy_Transport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// The test server listens on loopback, which the policy refuses to dial
+	policy, err := NewNetworkPolicy(nil, nil, stubResolver{})
+	require.NoError(t, err)
+	_, err = (&http.Client{Transport: policy.Transport()}).Get(server.URL)
+	assert.True(t, errors.Is(err, ErrPrivateDestination), "got %v", err)
+
+	// Allowlisting loopback lets it through
+	policy, err = NewNetworkPolicy([]string{"127.0.0.0/8"}, nil, stubResolver{})
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: policy.Transport()}).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/utils"
+)
+
+// DefaultSafeBrowsingEndpoint is Google's Safe Browsing Lookup API (v4)
+const DefaultSafeBrowsingEndpoint = "https://safebrowsing.googleapis.com"
+
+// threatCategories maps Safe Browsing threat types onto our categories
+var threatCategories = map[string]string{
+	"MALWARE":                         domain.ThreatMalware,
+	"SOCIAL_ENGINEERING":              domain.ThreatPhishing,
+	"UNWANTED_SOFTWARE":               domain.ThreatUnwanted,
+	"POTENTIALLY_HARMFUL_APPLICATION": domain.ThreatMalware,
+}
+
+// SafeBrowsing looks URLs up with the Safe Browsing Lookup API, or any
+// service that speaks its threatMatches:find protocol
+type SafeBrowsing struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+// NewSafeBrowsing creates a lookup client; an empty endpoint uses Google's
+func NewSafeBrowsing(endpoint, apiKey string, timeout time.Duration) *SafeBrowsing {
+	if endpoint == "" {
+		endpoint = DefaultSafeBrowsingEndpoint
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &SafeBrowsing{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		apiKey:   apiKey,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+type findRequest struct {
+	Client struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+	ThreatInfo threatInfo `json:"threatInfo"`
+}
+
+type threatInfo struct {
+	ThreatTypes      []string      `json:"threatTypes"`
+	PlatformTypes    []string      `json:"platformTypes"`
+	ThreatEntryTypes []string      `json:"threatEntryTypes"`
+	ThreatEntries    []threatEntry `json:"threatEntries"`
+}
+
+type threatEntry struct {
+	URL string `json:"url"`
+}
+
+type findResponse struct {
+	Matches []struct {
+		ThreatType   string      `json:"threatType"`
+		PlatformType string      `json:"platformType"`
+		Threat       threatEntry `json:"threat"`
+	} `json:"matches"`
+}
+
+func (s *SafeBrowsing) Scan(ctx context.Context, rawURL string) (*domain.ScanResult, error) {
+	result := &domain.ScanResult{URL: rawURL}
+	if !utils.IsWebURL(rawURL) { // The service only knows about web pages
+		return result, nil
+	}
+
+	var body findRequest
+	body.Client.ClientID = "go-url-shortener"
+	body.Client.ClientVersion = "1.0.0"
+	body.ThreatInfo = threatInfo{
+		ThreatTypes:      []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"},
+		PlatformTypes:    []string{"ANY_PLATFORM"},
+		ThreatEntryTypes: []string{"URL"},
+		ThreatEntries:    []threatEntry{{URL: rawURL}},
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return result, fmt.Errorf("failed to encode safe browsing request: %w", err)
+	}
+
+	endpoint := s.endpoint + "/v4/threatMatches:find?key=" + url.QueryEscape(s.apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return result, fmt.Errorf("failed to build safe browsing request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("safe browsing lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("safe browsing lookup failed: status %d", resp.StatusCode)
+	}
+
+	var found findResponse
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return result, fmt.Errorf("failed to decode safe browsing response: %w", err)
+	}
+	for _, match := range found.Matches {
+		category, ok := threatCategories[match.ThreatType]
+		if !ok {
+			category = strings.ToLower(match.ThreatType)
+		}
+		result.Threats = append(result.Threats, domain.Threat{
+			Provider: "safe_browsing",
+			Category: category,
+			Detail:   match.ThreatType,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// newSafeBrowsingStub serves threatMatches:find, flagging the URLs in threats
+func newSafeBrowsingStub(t *testing.T, threats map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v4/threatMatches:find", r.URL.Path)
+		assert.Equal(t, "test-key", r.URL.Query().Get("key"))
+
+		var req findRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		var resp findResponse
+		for _, entry := range req.ThreatInfo.ThreatEntries {
+			if threatType, ok := threats[entry.URL]; ok {
+				resp.Matches = append(resp.Matches, struct {
+					ThreatType   string      `json:"threatType"`
+					PlatformType string      `json:"platformType"`
+					Threat       threatEntry `json:"threat"`
+				}{ThreatType: threatType, PlatformType: "ANY_PLATFORM", Threat: entry})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp) // An empty object means no matches
+	}))
+}
+
+func TestSafeBrowsing(t *testing.T) {
+	server := newSafeBrowsingStub(t, map[string]string{
+		"https://phish.example/login": "SOCIAL_ENGINEERING",
+	})
+	defer server.Close()
+
+	client := NewSafeBrowsing(server.URL, "test-key", time.Second)
+
+	result, err := client.Scan(context.Background(), "https://phish.example/login")
+	require.NoError(t, err)
+	require.Len(t, result.Threats, 1)
+	assert.Equal(t, domain.Threat{Provider: "safe_browsing", Category: domain.ThreatPhishing, Detail: "SOCIAL_ENGINEERING"}, result.Threats[0])
+
+	result, err = client.Scan(context.Background(), "https://example.com/")
+	require.NoError(t, err)
+	assert.True(t, result.Safe())
+}
+
+func TestSafeBrowsing_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewSafeBrowsing(server.URL, "test-key", time.Second)
+
+	result, err := client.Scan(context.Background(), "https://example.com/")
+	assert.Error(t, err)
+	assert.True(t, result.Safe())
+}
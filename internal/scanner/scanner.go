@@ -0,0 +1,42 @@
+// Package scanner checks link destinations against blocklists, threat
+// intelligence services and heuristics.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// defaultTimeout bounds network checks when no timeout is configured
+const defaultTimeout = 5 * time.Second
+
+// Multi runs several scanners and merges their findings
+type Multi struct {
+	scanners []domain.URLScanner
+}
+
+// NewMulti combines scanners; they run in order and all of them run even
+// when an earlier one flags the URL, so the result lists every threat
+func NewMulti(scanners ...domain.URLScanner) *Multi {
+	return &Multi{scanners: scanners}
+}
+
+// Scan reports the threats found by every scanner, along with the errors of
+// scanners that could not finish
+func (m *Multi) Scan(ctx context.Context, rawURL string) (*domain.ScanResult, error) {
+	result := &domain.ScanResult{URL: rawURL}
+	var errs []error
+	for _, scanner := range m.scanners {
+		found, err := scanner.Scan(ctx, rawURL)
+		if found != nil {
+			result.Threats = append(result.Threats, found.Threats...)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return result, errors.Join(errs...)
+}
@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+func categories(result *domain.ScanResult) []string {
+	var found []string
+	for _, threat := range result.Threats {
+		found = append(found, threat.Category)
+	}
+	return found
+}
+
+func TestBlocklist(t *testing.T) {
+	list := NewBlocklist("malware.example.com", "*.phishing.test", ".spam.test")
+
+	cases := []struct {
+		host    string
+		blocked bool
+	}{
+		{"malware.example.com", true},
+		{"MALWARE.example.com.", true},
+		{"cdn.malware.example.com", false}, // Exact entries do not cover subdomains
+		{"notmalware.example.com", false},
+		{"malware.example.com.evil", false},
+		{"phishing.test", true},
+		{"login.phishing.test", true},
+		{"a.b.spam.test", true},
+		{"notphishing.test", false},
+		{"example.com", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.blocked, list.Blocked(tc.host), tc.host)
+	}
+
+	result, err := list.Scan(context.Background(), "https://login.phishing.test/account")
+	require.NoError(t, err)
+	assert.Equal(t, []string{domain.ThreatBlocklisted}, categories(result))
+	assert.False(t, result.Safe())
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# known bad hosts\nbad.example\n\n*.worse.example\n"), 0644))
+
+	list, err := LoadBlocklist(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, list.Len())
+	assert.True(t, list.Blocked("bad.example"))
+	assert.True(t, list.Blocked("www.worse.example"))
+
+	_, err = LoadBlocklist(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestHeuristics(t *testing.T) {
+	heuristics := NewHeuristics(0, time.Second)
+
+	cases := []struct {
+		url  string
+		want []string
+	}{
+		{"https://example.com/path", nil},
+		{"http://192.168.1.10/login", []string{domain.ThreatIPLiteral}},
+		{"http://[2001:db8::1]/", []string{domain.ThreatIPLiteral}},
+		{"http://2130706433/", []string{domain.ThreatIPLiteral}},
+		{"http://0x7f.0x0.0x0.0x1/", []string{domain.ThreatIPLiteral}},
+		{"https://xn--pple-43d.com/", []string{domain.ThreatHomograph}}, // Cyrillic "а" in "apple"
+		{"https://xn--mnchen-3ya.de/", nil},                             // Legitimate "münchen"
+		{"https://xn--80ak6aa92e.com/", nil},                            // All-Cyrillic names are left alone
+		{"myapp://product/42", nil},                                     // Deep links are not web destinations
+	}
+	for _, tc := range cases {
+		result, err := heuristics.Scan(context.Background(), tc.url)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, categories(result), tc.url)
+	}
+}
+
+func TestHeuristics_Redirects(t *testing.T) {
+	// /hop/N redirects to /hop/N-1 until /hop/0 answers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		if n == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusFound)
+	}))
+	defer server.Close()
+
+	heuristics := NewHeuristics(3, time.Second)
+
+	result, err := heuristics.Scan(context.Background(), server.URL+"/hop/3")
+	require.NoError(t, err)
+	assert.NotContains(t, categories(result), domain.ThreatExcessiveRedirects)
+
+	result, err = heuristics.Scan(context.Background(), server.URL+"/hop/4")
+	require.NoError(t, err)
+	assert.Contains(t, categories(result), domain.ThreatExcessiveRedirects)
+}
+
+type stubScanner struct {
+	threats []domain.Threat
+	err     error
+}
+
+func (s stubScanner) Scan(ctx context.Context, rawURL string) (*domain.ScanResult, error) {
+	return &domain.ScanResult{URL: rawURL, Threats: s.threats}, s.err
+}
+
+func TestMulti(t *testing.T) {
+	unavailable := errors.New("provider unavailable")
+	multi := NewMulti(
+		stubScanner{threats: []domain.Threat{{Provider: "a", Category: domain.ThreatMalware}}},
+		stubScanner{err: unavailable},
+		stubScanner{threats: []domain.Threat{{Provider: "c", Category: domain.ThreatPhishing}}},
+	)
+
+	result, err := multi.Scan(context.Background(), "https://example.com")
+	assert.ErrorIs(t, err, unavailable)
+	assert.Equal(t, []string{domain.ThreatMalware, domain.ThreatPhishing}, categories(result))
+	assert.Equal(t, "https://example.com [a:malware, c:phishing]", result.String())
+}
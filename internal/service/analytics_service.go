@@ -11,29 +11,35 @@ import (
 )
 
 type AnalyticsService struct {
-	urlRepo   domain.URLRepository
-	cacheRepo domain.CacheRepository
-	logger    *zap.Logger
+	urlService *URLService
+	urlRepo    domain.URLRepository
+	cacheRepo  domain.CacheRepository
+	logger     *zap.Logger
 }
 
-func NewAnalyticsService(urlRepo domain.URLRepository, cacheRepo domain.CacheRepository, logger *zap.Logger) *AnalyticsService {
+func NewAnalyticsService(urlService *URLService, urlRepo domain.URLRepository, cacheRepo domain.CacheRepository, logger *zap.Logger) *AnalyticsService {
 	return &AnalyticsService{
-		urlRepo:   urlRepo,
-		cacheRepo: cacheRepo,
-		logger:    logger,
+		urlService: urlService,
+		urlRepo:    urlRepo,
+		cacheRepo:  cacheRepo,
+		logger:     logger,
 	}
 }
 
-func (s *AnalyticsService) GetAnalytics(ctx context.Context, shortCode string, days int) (*domain.AnalyticsResponse, error) {
+// GetAnalytics returns the click statistics of a link; host is its branded
+// domain, or "" or the base URL's host for the default one
+func (s *AnalyticsService) GetAnalytics(ctx context.Context, host, shortCode string, days int) (*domain.AnalyticsResponse, error) {
+	host = s.urlService.domainName(host)
+
 	// Try cache first
-	cacheKey := fmt.Sprintf("analytics:%s:%d", shortCode, days)
+	cacheKey := fmt.Sprintf("analytics:%s:%d", domain.LinkKey(host, shortCode), days)
 	var cachedAnalytics domain.AnalyticsResponse
 	if err := s.cacheRepo.Get(ctx, cacheKey, &cachedAnalytics); err == nil {
 		return &cachedAnalytics, nil
 	}
 
 	// Fallback to database
-	analytics, err := s.urlRepo.GetAnalytics(ctx, shortCode, days)
+	analytics, err := s.urlRepo.GetAnalytics(ctx, host, shortCode, days)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get analytics: %w", err)
 	}
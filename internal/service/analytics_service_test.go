@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
@@ -32,14 +33,14 @@ func TestGetAnalytics_FromCache(t *testing.T) {
 		}).
 		Return(nil)
 
-	svc := service.NewAnalyticsService(urlRepo, cacheRepo, logger)
+	svc := service.NewAnalyticsService(service.NewURLService(urlRepo, cacheRepo, logger, nil), urlRepo, cacheRepo, logger)
 
-	resp, err := svc.GetAnalytics(ctx, "abc123", 7)
+	resp, err := svc.GetAnalytics(ctx, "", "abc123", 7)
 	require.NoError(t, err)
 	require.Equal(t, expected, resp)
 
 	cacheRepo.AssertExpectations(t)
-	urlRepo.AssertNotCalled(t, "GetAnalytics", ctx, "abc123", 7)
+	urlRepo.AssertNotCalled(t, "GetAnalytics", ctx, "", "abc123", 7)
 }
 
 func TestGetAnalytics_FromDB_AndCacheSet(t *testing.T) {
@@ -56,16 +57,16 @@ func TestGetAnalytics_FromDB_AndCacheSet(t *testing.T) {
 		Return(errors.New("cache miss"))
 
 	// DB hit
-	urlRepo.On("GetAnalytics", ctx, "abc123", 7).
+	urlRepo.On("GetAnalytics", ctx, "", "abc123", 7).
 		Return(expected, nil)
 
 	// Set cache
 	cacheRepo.On("Set", ctx, "analytics:abc123:7", expected, 15*time.Minute).
 		Return(nil)
 
-	svc := service.NewAnalyticsService(urlRepo, cacheRepo, logger)
+	svc := service.NewAnalyticsService(service.NewURLService(urlRepo, cacheRepo, logger, nil), urlRepo, cacheRepo, logger)
 
-	resp, err := svc.GetAnalytics(ctx, "abc123", 7)
+	resp, err := svc.GetAnalytics(ctx, "", "abc123", 7)
 	require.NoError(t, err)
 	require.Equal(t, expected, resp)
 
@@ -85,12 +86,12 @@ func TestGetAnalytics_DBError(t *testing.T) {
 		Return(errors.New("cache miss"))
 
 	// DB error
-	urlRepo.On("GetAnalytics", ctx, "abc123", 7).
+	urlRepo.On("GetAnalytics", ctx, "", "abc123", 7).
 		Return(nil, errors.New("db error"))
 
-	svc := service.NewAnalyticsService(urlRepo, cacheRepo, logger)
+	svc := service.NewAnalyticsService(service.NewURLService(urlRepo, cacheRepo, logger, nil), urlRepo, cacheRepo, logger)
 
-	resp, err := svc.GetAnalytics(ctx, "abc123", 7)
+	resp, err := svc.GetAnalytics(ctx, "", "abc123", 7)
 	require.Nil(t, resp)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "failed to get analytics")
@@ -98,3 +99,30 @@ func TestGetAnalytics_DBError(t *testing.T) {
 	cacheRepo.AssertExpectations(t)
 	urlRepo.AssertExpectations(t)
 }
+
+func TestGetAnalytics_BaseHostIsTheDefaultDomain(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+
+	urlRepo := new(mocks.MockURLRepository)
+	cacheRepo := new(mocks.MockCacheRepository)
+	urlService := service.NewURLService(urlRepo, cacheRepo, logger, &config.Config{
+		Server: config.ServerConfig{BaseURL: "https://sho.rt"},
+	})
+
+	expected := &domain.AnalyticsResponse{ShortCode: "abc123", ClickCount: 5}
+	cacheRepo.On("Get", ctx, "analytics:abc123:7", &domain.AnalyticsResponse{}).
+		Return(errors.New("cache miss"))
+	urlRepo.On("GetAnalytics", ctx, "", "abc123", 7).
+		Return(expected, nil)
+	cacheRepo.On("Set", ctx, "analytics:abc123:7", expected, 15*time.Minute).
+		Return(nil)
+
+	svc := service.NewAnalyticsService(urlService, urlRepo, cacheRepo, logger)
+
+	resp, err := svc.GetAnalytics(ctx, "SHO.RT", "abc123", 7)
+	require.NoError(t, err)
+	require.Equal(t, expected, resp)
+
+	urlRepo.AssertExpectations(t)
+}
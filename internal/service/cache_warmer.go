@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// CacheWarmer keeps popular links in the cache used by redirects. Warm
+// preloads the most popular ones before the server starts, and Run renews
+// trending ones before their entries expire, so hot links never fall back
+// to the database.
+type CacheWarmer struct {
+	popular   domain.PopularURLRepository
+	cacheRepo domain.CacheRepository
+	logger    *zap.Logger
+	cfg       config.WarmupConfig
+	ttl       time.Duration
+}
+
+// NewCacheWarmer creates a warmer caching links for ttl; 0 uses an hour,
+// like lookups
+func NewCacheWarmer(popular domain.PopularURLRepository, cacheRepo domain.CacheRepository, logger *zap.Logger, cfg config.WarmupConfig, ttl time.Duration) *CacheWarmer {
+	if cfg.Order == "" {
+		cfg.Order = domain.PopularByClicks
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &CacheWarmer{
+		popular:   popular,
+		cacheRepo: cacheRepo,
+		logger:    logger,
+		cfg:       cfg,
+		ttl:       ttl,
+	}
+}
+
+// Warm caches the most popular links of the last Window, or of all time
+// without one, in the configured order and returns how many were cached
+func (w *CacheWarmer) Warm(ctx context.Context) (int, error) {
+	var since time.Time
+	if w.cfg.Window > 0 {
+		since = time.Now().Add(-w.cfg.Window)
+	}
+	urls, err := w.popular.ListPopularURLs(ctx, since, w.cfg.Order, w.cfg.Links)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list popular URLs: %w", err)
+	}
+
+	ctx = domain.AsCacheFill(domain.WithoutWorkspace(ctx))
+	for i, url := range urls {
+		if err := w.cacheRepo.Set(ctx, fmt.Sprintf("url:%s", url.Key()), url, w.ttl); err != nil {
+			return i, fmt.Errorf("failed to cache URL: %w", err)
+		}
+	}
+	return len(urls), nil
+}
+
+// Refresh renews the entries of up to limit links clicked most since the
+// cutoff: cached ones get a fresh time to live and the others are cached
+// again. It returns how many were renewed.
+func (w *CacheWarmer) Refresh(ctx context.Context, since time.Time, limit int) (int, error) {
+	urls, err := w.popular.ListPopularURLs(ctx, since, domain.PopularByClicks, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trending URLs: %w", err)
+	}
+
+	ctx = domain.AsCacheFill(domain.WithoutWorkspace(ctx))
+	renewed := 0
+	for _, url := range urls {
+		key := fmt.Sprintf("url:%s", url.Key())
+		var cached domain.URL
+		if err := w.cacheRepo.Get(ctx, key, &cached); err == nil {
+			err = w.cacheRepo.Expire(ctx, key, w.ttl)
+		} else {
+			err = w.cacheRepo.Set(ctx, key, url, w.ttl)
+		}
+		if err != nil {
+			w.logger.Warn("Failed to renew cached URL", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		renewed++
+	}
+	return renewed, nil
+}
+
+// Run calls Refresh every RefreshInterval for the links clicked since the
+// previous run, until ctx is cancelled
+func (w *CacheWarmer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			renewed, err := w.Refresh(ctx, time.Now().Add(-w.cfg.RefreshInterval), w.cfg.Trending)
+			if err != nil {
+				w.logger.Error("Cache refresh failed", zap.Error(err))
+			} else if renewed > 0 {
+				w.logger.Debug("Renewed trending URLs in cache", zap.Int("count", renewed))
+			}
+		}
+	}
+}
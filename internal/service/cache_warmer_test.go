@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/memory"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
+)
+
+// newPopularStore returns a store where hot was clicked twice, recent once
+// and old a month ago
+func newPopularStore(t *testing.T) *memory.Store {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+	now := time.Now()
+	for _, code := range []string{"hot", "recent", "old"} {
+		require.NoError(t, store.CreateURL(ctx, &domain.URL{WorkspaceID: 2, ShortCode: code, OriginalURL: "https://example.com/" + code, CreatedAt: now}))
+	}
+	for _, click := range []*domain.URLAnalytics{
+		{ShortCode: "hot", ClickedAt: now.Add(-2 * time.Minute)},
+		{ShortCode: "hot", ClickedAt: now.Add(-2 * time.Minute)},
+		{ShortCode: "recent", ClickedAt: now.Add(-time.Minute)},
+		{ShortCode: "old", ClickedAt: now.AddDate(0, 0, -30)},
+	} {
+		require.NoError(t, store.RecordClick(ctx, click))
+	}
+	return store
+}
+
+func TestCacheWarmer_Warm(t *testing.T) {
+	tests := []struct {
+		name  string
+		order string
+		want  string
+	}{
+		{"MostClicked", domain.PopularByClicks, "hot"},
+		{"MostRecent", domain.PopularByRecent, "recent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cache := memory.NewCache()
+			warmer := NewCacheWarmer(newPopularStore(t), cache, zaptest.NewLogger(t), config.WarmupConfig{
+				Links:  1,
+				Order:  tt.order,
+				Window: 24 * time.Hour,
+			}, time.Hour)
+
+			warmed, err := warmer.Warm(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 1, warmed)
+
+			var got domain.URL
+			require.NoError(t, cache.Get(ctx, "url:"+tt.want, &got), "cached where redirects look")
+			assert.Equal(t, "https://example.com/"+tt.want, got.OriginalURL)
+			assert.ErrorIs(t, cache.Get(ctx, "url:old", &got), domain.ErrNotFound, "clicks outside the window do not count")
+		})
+	}
+}
+
+func TestCacheWarmer_Refresh(t *testing.T) {
+	ctx := context.Background()
+	mockCache := new(mocks.MockCacheRepository)
+	warmer := NewCacheWarmer(newPopularStore(t), mockCache, zaptest.NewLogger(t), config.WarmupConfig{}, 0)
+
+	mockCache.On("Get", mock.Anything, "url:hot", mock.Anything).Return(nil)
+	mockCache.On("Expire", mock.Anything, "url:hot", time.Hour).Return(nil)
+	mockCache.On("Get", mock.Anything, "url:recent", mock.Anything).Return(domain.ErrNotFound)
+	mockCache.On("Set", mock.MatchedBy(domain.IsCacheFill), "url:recent", mock.Anything, time.Hour).Return(nil)
+
+	renewed, err := warmer.Refresh(ctx, time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, renewed)
+	mockCache.AssertExpectations(t)
+	mockCache.AssertNotCalled(t, "Set", mock.Anything, "url:hot", mock.Anything, mock.Anything)
+}
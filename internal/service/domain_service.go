@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+var (
+	ErrInvalidDomain = errors.New("domain must be a valid host name other than the default one")
+	ErrDomainExists  = errors.New("domain already registered")
+)
+
+// hostnamePattern matches a fully qualified host name of letters, digits and hyphens
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+const domainCacheTTL = 5 * time.Minute
+
+type DomainService struct {
+	domainRepo domain.DomainRepository
+	cacheRepo  domain.CacheRepository
+	logger     *zap.Logger
+	cfg        *config.Config
+}
+
+func NewDomainService(domainRepo domain.DomainRepository, cacheRepo domain.CacheRepository, logger *zap.Logger, cfg *config.Config) *DomainService {
+	return &DomainService{
+		domainRepo: domainRepo,
+		cacheRepo:  cacheRepo,
+		logger:     logger,
+		cfg:        cfg,
+	}
+}
+
+// AddDomain registers a branded domain. Its DNS must point at this service
+// for redirects to reach it.
+func (s *DomainService) AddDomain(ctx context.Context, name string) (*domain.Domain, error) {
+	name = domain.NormalizeHost(name)
+	if len(name) > 253 || !hostnamePattern.MatchString(name) || name == defaultHost(s.cfg) {
+		return nil, ErrInvalidDomain
+	}
+
+	// Names are unique across workspaces, since redirects look them up by Host
+	if _, err := s.domainRepo.GetDomain(domain.WithoutWorkspace(ctx), name); err == nil {
+		return nil, ErrDomainExists
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("failed to look up domain: %w", err)
+	}
+
+	workspaceID, _ := domain.WorkspaceFromContext(ctx)
+	d := &domain.Domain{WorkspaceID: workspaceID, Name: name, CreatedAt: time.Now()}
+	if err := s.domainRepo.CreateDomain(ctx, d); err != nil {
+		if errors.Is(err, domain.ErrConflict) { // Registered concurrently
+			return nil, ErrDomainExists
+		}
+		return nil, fmt.Errorf("failed to create domain: %w", err)
+	}
+
+	// Forget that the host was unknown so redirects start resolving at once
+	for _, scope := range []context.Context{ctx, domain.WithoutWorkspace(ctx)} {
+		if err := s.cacheRepo.Delete(scope, domainCacheKey(name)); err != nil {
+			s.logger.Warn("Failed to evict cached domain", zap.String("domain", name), zap.Error(err))
+		}
+	}
+
+	s.logger.Info("Domain registered", zap.String("domain", name))
+	return d, nil
+}
+
+// ListDomains returns the branded domains of the caller's workspace
+func (s *DomainService) ListDomains(ctx context.Context) ([]*domain.Domain, error) {
+	domains, err := s.domainRepo.ListDomains(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	return domains, nil
+}
+
+// defaultHost is the host of the base URL
+func defaultHost(cfg *config.Config) string {
+	if cfg == nil {
+		return ""
+	}
+	u, err := neturl.Parse(cfg.BaseURL())
+	if err != nil {
+		return ""
+	}
+	return domain.NormalizeHost(u.Host)
+}
+
+func domainCacheKey(name string) string {
+	return fmt.Sprintf("domain:%s", name)
+}
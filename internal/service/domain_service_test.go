@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
+)
+
+func TestDomainService_AddDomain(t *testing.T) {
+	domainRepo := new(mocks.MockDomainRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	svc := NewDomainService(domainRepo, mockCache, zaptest.NewLogger(t), &config.Config{
+		Server: config.ServerConfig{BaseURL: "https://sho.rt"},
+	})
+	ctx := context.Background()
+
+	domainRepo.On("GetDomain", mock.Anything, "go.brand.com").Return(nil, domain.ErrNotFound).Once()
+	domainRepo.On("CreateDomain", mock.Anything, mock.AnythingOfType("*domain.Domain")).Return(nil)
+	mockCache.On("Delete", mock.Anything, "domain:go.brand.com").Return(nil)
+
+	d, err := svc.AddDomain(ctx, "Go.Brand.com.")
+	assert.NoError(t, err)
+	assert.Equal(t, "go.brand.com", d.Name)
+	mockCache.AssertExpectations(t) // A cached miss must not hide the new domain
+
+	domainRepo.On("GetDomain", mock.Anything, "go.brand.com").Return(d, nil)
+	_, err = svc.AddDomain(ctx, "go.brand.com")
+	assert.Equal(t, ErrDomainExists, err)
+
+	for _, name := range []string{"", "localhost", "not a host.com", "-bad.com", "sho.rt", "sho.rt:443"} {
+		_, err := svc.AddDomain(ctx, name)
+		assert.Equal(t, ErrInvalidDomain, err, name)
+	}
+}
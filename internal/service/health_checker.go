@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/utils"
+)
+
+const (
+	healthCheckUserAgent    = "URLShortener-HealthCheck/1.0"
+	defaultHealthTimeout    = 10 * time.Second
+	maxHealthCheckRedirects = 10
+)
+
+// HealthChecker periodically requests link destinations and records whether
+// they still respond. Links dead for DisableAfter consecutive checks are
+// disabled so they stop redirecting.
+type HealthChecker struct {
+	healthRepo domain.LinkHealthRepository
+	cacheRepo  domain.CacheRepository
+	links      domain.LinkPublisher // Optional; announces disabled links to other instances
+	logger     *zap.Logger
+	cfg        config.HealthCheckConfig
+	client     *http.Client
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time // Earliest start of the next request per host
+}
+
+// NewHealthChecker creates a checker that makes its requests through
+// transport, such as a NetworkPolicy's, so checks cannot reach internal
+// services; nil uses http.DefaultTransport.
+func NewHealthChecker(healthRepo domain.LinkHealthRepository, cacheRepo domain.CacheRepository, logger *zap.Logger, cfg config.HealthCheckConfig, transport http.RoundTripper) *HealthChecker {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHealthTimeout
+	}
+	return &HealthChecker{
+		healthRepo: healthRepo,
+		cacheRepo:  cacheRepo,
+		logger:     logger,
+		cfg:        cfg,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxHealthCheckRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxHealthCheckRedirects)
+				}
+				return nil
+			},
+		},
+		nextSlot: make(map[string]time.Time),
+	}
+}
+
+// WithLinkPublisher announces links the checker disables, so other
+// instances stop serving them from memory
+func (h *HealthChecker) WithLinkPublisher(links domain.LinkPublisher) *HealthChecker {
+	h.links = links
+	return h
+}
+
+// Check requests a link's destination and returns the outcome, counting
+// consecutive failures on top of the link's previous checks. It tries HEAD
+// first and falls back to GET for servers that refuse HEAD. A destination
+// that cannot be reached is not asked again, which would double the wait
+// for one that times out.
+func (h *HealthChecker) Check(ctx context.Context, link *domain.URL) *domain.LinkHealth {
+	health := h.request(ctx, http.MethodHead, link.OriginalURL)
+	if health.Status == http.StatusMethodNotAllowed || health.Status == http.StatusNotImplemented {
+		health = h.request(ctx, http.MethodGet, link.OriginalURL)
+	}
+
+	health.CheckedAt = time.Now()
+	if health.Dead() {
+		health.ConsecutiveFailures = link.HealthFailures + 1
+	}
+	return health
+}
+
+func (h *HealthChecker) request(ctx context.Context, method, rawURL string) *domain.LinkHealth {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return &domain.LinkHealth{Error: err.Error()}
+	}
+	req.Header.Set("User-Agent", healthCheckUserAgent)
+
+	if err := h.waitForHost(ctx, req.URL.Hostname()); err != nil {
+		return &domain.LinkHealth{Error: err.Error()}
+	}
+
+	start := time.Now()
+	resp, err := h.client.Do(req)
+	latency := time.Since(start).Milliseconds()
+	if err != nil {
+		return &domain.LinkHealth{LatencyMs: latency, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	// A few bytes are enough to tell the server answered; the rest is not needed
+	io.CopyN(io.Discard, resp.Body, 512)
+
+	return &domain.LinkHealth{
+		Status:    resp.StatusCode,
+		LatencyMs: latency,
+		FinalURL:  resp.Request.URL.String(),
+	}
+}
+
+// waitForHost blocks until at least HostDelay has passed since the last
+// request to host was allowed to start, so a batch full of links to one
+// site does not hammer it. Hosts whose next slot has passed are forgotten.
+func (h *HealthChecker) waitForHost(ctx context.Context, host string) error {
+	if h.cfg.HostDelay <= 0 {
+		return nil
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	for other, slot := range h.nextSlot {
+		if slot.Before(now) {
+			delete(h.nextSlot, other)
+		}
+	}
+	start := h.nextSlot[host]
+	if start.Before(now) {
+		start = now
+	}
+	h.nextSlot[host] = start.Add(h.cfg.HostDelay)
+	h.mu.Unlock()
+
+	wait := time.Until(start)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// CheckLinks checks up to limit enabled links that were never checked or
+// were last checked before checkedBefore, running up to Concurrency checks
+// at once. It returns how many destinations were found dead.
+func (h *HealthChecker) CheckLinks(ctx context.Context, checkedBefore time.Time, limit int) (int, error) {
+	links, err := h.healthRepo.ListURLsForHealthCheck(ctx, checkedBefore, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list URLs for health check: %w", err)
+	}
+
+	var dead atomic.Int64
+	sem := make(chan struct{}, h.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for _, link := range links {
+		if !utils.IsWebURL(link.OriginalURL) {
+			continue // Not listed by the repositories; deep links cannot be requested
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return int(dead.Load()), ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(link *domain.URL) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			health := h.Check(ctx, link)
+			if ctx.Err() != nil {
+				return // Cancelled mid-check; the result says nothing about the destination
+			}
+			if health.Dead() {
+				dead.Add(1)
+			}
+			if err := h.record(ctx, link, health); err != nil {
+				h.logger.Warn("Failed to record link health", zap.String("short_code", link.ShortCode), zap.Error(err))
+			}
+		}(link)
+	}
+	wg.Wait()
+	return int(dead.Load()), nil
+}
+
+func (h *HealthChecker) record(ctx context.Context, link *domain.URL, health *domain.LinkHealth) error {
+	disable := h.cfg.DisableAfter > 0 && health.ConsecutiveFailures >= h.cfg.DisableAfter
+	if err := h.healthRepo.UpdateLinkHealth(ctx, link.Domain, link.ShortCode, health, disable); err != nil {
+		return err
+	}
+	if disable {
+		h.logger.Warn("Disabled dead link",
+			zap.String("short_code", link.ShortCode),
+			zap.String("original_url", link.OriginalURL),
+			zap.Int("failures", health.ConsecutiveFailures),
+		)
+		evictURL(ctx, h.cacheRepo, h.links, h.logger, link)
+	}
+	return nil
+}
+
+// Run calls CheckLinks every Interval, re-checking links last checked more
+// than an interval ago, until ctx is cancelled
+func (h *HealthChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			dead, err := h.CheckLinks(ctx, time.Now().Add(-h.cfg.Interval), h.cfg.Batch)
+			if err != nil && ctx.Err() == nil {
+				h.logger.Error("Link health check failed", zap.Error(err))
+			} else if dead > 0 {
+				h.logger.Warn("Link health check found dead destinations", zap.Int("count", dead))
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
+)
+
+func newHealthServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHealthChecker_Check(t *testing.T) {
+	server := newHealthServer(t)
+	checker := NewHealthChecker(nil, nil, zaptest.NewLogger(t), config.HealthCheckConfig{}, nil)
+	ctx := context.Background()
+
+	t.Run("FollowsRedirects", func(t *testing.T) {
+		health := checker.Check(ctx, &domain.URL{OriginalURL: server.URL + "/moved", HealthFailures: 2})
+
+		assert.Equal(t, http.StatusOK, health.Status)
+		assert.Equal(t, server.URL+"/ok", health.FinalURL)
+		assert.Zero(t, health.ConsecutiveFailures) // A healthy check resets the streak
+		assert.False(t, health.CheckedAt.IsZero())
+	})
+
+	t.Run("FallsBackToGet", func(t *testing.T) {
+		health := checker.Check(ctx, &domain.URL{OriginalURL: server.URL + "/no-head"})
+
+		assert.Equal(t, http.StatusOK, health.Status)
+		assert.False(t, health.Dead())
+	})
+
+	t.Run("CountsDeadChecks", func(t *testing.T) {
+		health := checker.Check(ctx, &domain.URL{OriginalURL: server.URL + "/gone", HealthFailures: 2})
+
+		assert.Equal(t, http.StatusGone, health.Status)
+		assert.True(t, health.Dead())
+		assert.Equal(t, 3, health.ConsecutiveFailures)
+	})
+
+	t.Run("NoGetAfterHeadFails", func(t *testing.T) {
+		var requests atomic.Int32
+		dropped := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close() // No response at all
+		}))
+		defer dropped.Close()
+
+		health := checker.Check(ctx, &domain.URL{OriginalURL: dropped.URL + "/ok"})
+
+		assert.Zero(t, health.Status)
+		assert.NotEmpty(t, health.Error)
+		assert.Equal(t, int32(1), requests.Load(), "a destination that did not answer is not asked again")
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		health := checker.Check(ctx, &domain.URL{OriginalURL: "http://127.0.0.1:1/"})
+
+		assert.Zero(t, health.Status)
+		assert.NotEmpty(t, health.Error)
+		assert.Equal(t, 1, health.ConsecutiveFailures)
+	})
+}
+
+func TestHealthChecker_CheckLinks(t *testing.T) {
+	server := newHealthServer(t)
+	healthRepo := new(mocks.MockLinkHealthRepository)
+	cacheRepo := new(mocks.MockCacheRepository)
+	checker := NewHealthChecker(healthRepo, cacheRepo, zaptest.NewLogger(t), config.HealthCheckConfig{
+		Concurrency:  2,
+		DisableAfter: 3,
+	}, nil)
+
+	cutoff := time.Now().Add(-time.Hour)
+	healthRepo.On("ListURLsForHealthCheck", mock.Anything, cutoff, 10).Return([]*domain.URL{
+		{ShortCode: "ok", OriginalURL: server.URL + "/ok", HealthFailures: 1},
+		{ShortCode: "flaky", OriginalURL: server.URL + "/gone"},
+		{ShortCode: "dead", OriginalURL: server.URL + "/gone", HealthFailures: 2},
+		{ShortCode: "mail", OriginalURL: "mailto:someone@example.com"},
+	}, nil)
+	healthRepo.On("UpdateLinkHealth", mock.Anything, "", "ok", mock.MatchedBy(func(h *domain.LinkHealth) bool {
+		return h.Status == http.StatusOK && h.ConsecutiveFailures == 0
+	}), false).Return(nil)
+	healthRepo.On("UpdateLinkHealth", mock.Anything, "", "flaky", mock.MatchedBy(func(h *domain.LinkHealth) bool {
+		return h.ConsecutiveFailures == 1
+	}), false).Return(nil)
+	healthRepo.On("UpdateLinkHealth", mock.Anything, "", "dead", mock.MatchedBy(func(h *domain.LinkHealth) bool {
+		return h.ConsecutiveFailures == 3
+	}), true).Return(nil)
+
+	// Only the disabled link is evicted so redirects stop
+	cacheRepo.On("Delete", mock.Anything, "url:dead").Return(nil)
+	cacheRepo.On("Delete", mock.Anything, "lurl:"+server.URL+"/gone").Return(nil)
+
+	dead, err := checker.CheckLinks(context.Background(), cutoff, 10)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, dead)
+	healthRepo.AssertExpectations(t)
+	cacheRepo.AssertExpectations(t)
+	healthRepo.AssertNotCalled(t, "UpdateLinkHealth", mock.Anything, "", "mail", mock.Anything, mock.Anything)
+}
+
+func TestHealthChecker_Politeness(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	healthRepo := new(mocks.MockLinkHealthRepository)
+	checker := NewHealthChecker(healthRepo, nil, zaptest.NewLogger(t), config.HealthCheckConfig{
+		Concurrency: 4,
+		HostDelay:   50 * time.Millisecond,
+	}, nil)
+
+	var links []*domain.URL
+	for _, code := range []string{"a", "b", "c"} {
+		links = append(links, &domain.URL{ShortCode: code, OriginalURL: server.URL + "/" + code})
+	}
+	healthRepo.On("ListURLsForHealthCheck", mock.Anything, mock.Anything, 3).Return(links, nil)
+	healthRepo.On("UpdateLinkHealth", mock.Anything, "", mock.Anything, mock.Anything, false).Return(nil)
+
+	_, err := checker.CheckLinks(context.Background(), time.Now(), 3)
+	require.NoError(t, err)
+
+	// Same host: requests run one at a time and at least HostDelay apart
+	require.Len(t, starts, 3)
+	assert.Equal(t, int32(1), maxInFlight.Load())
+	for i := 1; i < len(starts); i++ {
+		assert.GreaterOrEqual(t, starts[i].Sub(starts[i-1]), 45*time.Millisecond)
+	}
+}
+
+func TestHealthChecker_ForgetsIdleHosts(t *testing.T) {
+	checker := NewHealthChecker(nil, nil, zaptest.NewLogger(t), config.HealthCheckConfig{HostDelay: time.Millisecond}, nil)
+	ctx := context.Background()
+
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		require.NoError(t, checker.waitForHost(ctx, host))
+	}
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, checker.waitForHost(ctx, "c.example.com"))
+
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	assert.Len(t, checker.nextSlot, 1, "only hosts that must still wait are remembered")
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/bloom"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// linkFilterSyncTimeout bounds catching up after missed link changes
+const linkFilterSyncTimeout = time.Minute
+
+// maxMirrorBits is the most bits a sync sets in the mirror one by one;
+// beyond it the whole filter is merged in
+const maxMirrorBits = 4096
+
+// LinkFilter tells apart short codes that certainly do not exist, so they
+// are answered without asking the cache or the database. It holds a Bloom
+// filter of every link's key, filled from the repository by Rebuild and
+// then as links are created here or, through LinkChanged, on other
+// instances. With a mirror its bits are also kept in a shared bitmap, from
+// which instances catch up on links they were not told about.
+type LinkFilter struct {
+	filter    *bloom.Filter
+	capacity  int
+	interval  time.Duration // How often Run catches up on lost announcements
+	links     domain.LinkKeyRepository
+	mirror    domain.BitmapRepository // optional shared copy of the bits
+	mirrorKey string
+	logger    *zap.Logger
+
+	checks, rejected atomic.Int64
+}
+
+// NewLinkFilter sizes an empty filter; mirror may be nil
+func NewLinkFilter(links domain.LinkKeyRepository, mirror domain.BitmapRepository, logger *zap.Logger, cfg config.BloomFilterConfig) *LinkFilter {
+	filter := bloom.New(cfg.Capacity, cfg.FalsePositiveRate)
+	return &LinkFilter{
+		filter:   filter,
+		capacity: cfg.Capacity,
+		interval: cfg.SyncInterval,
+		links:    links,
+		mirror:   mirror,
+		// Filters of another size hash to other bits, so they never share a bitmap
+		mirrorKey: fmt.Sprintf("%s:%d:%d", cfg.RedisKey, filter.Bits(), filter.Hashes()),
+		logger:    logger,
+	}
+}
+
+// Rebuild adds every stored link, then merges the filter with its mirror.
+// Lookups must not use the filter before it has been rebuilt.
+func (f *LinkFilter) Rebuild(ctx context.Context) error {
+	count := 0
+	err := f.links.ListLinkKeys(ctx, func(key string) error {
+		f.filter.Add(key)
+		count++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rebuild link filter: %w", err)
+	}
+	if err := f.sync(ctx); err != nil {
+		return err
+	}
+
+	if count > f.capacity {
+		f.logger.Warn("More links than the link filter is sized for", zap.Int("links", count), zap.Int("capacity", f.capacity))
+	}
+	f.logger.Info("Link filter rebuilt",
+		zap.Int("links", count),
+		zap.Uint64("bits", f.filter.Bits()),
+		zap.Float64("false_positive_rate", f.filter.FalsePositiveRate()),
+	)
+	return nil
+}
+
+// sync merges the mirror into the filter and writes back the bits only the
+// filter has, which are usually none
+func (f *LinkFilter) sync(ctx context.Context) error {
+	if f.mirror == nil {
+		return nil
+	}
+	ctx = domain.WithoutWorkspace(ctx)
+
+	bitmap, err := f.mirror.GetBitmap(ctx, f.mirrorKey)
+	if err != nil {
+		return fmt.Errorf("failed to read link filter mirror: %w", err)
+	}
+	f.filter.Merge(bitmap)
+
+	missing, ok := f.filter.Missing(bitmap, maxMirrorBits)
+	switch {
+	case !ok:
+		err = f.mirror.MergeBitmap(ctx, f.mirrorKey, f.filter.Bitmap())
+	case len(missing) > 0:
+		err = f.mirror.SetBits(ctx, f.mirrorKey, missing)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write link filter mirror: %w", err)
+	}
+	return nil
+}
+
+// MayExist is false only for keys of links that certainly do not exist.
+// Keys missing here are looked up in the mirror before they are turned
+// away, since their link may have been created on another instance that
+// has not told this one yet; if the mirror cannot be read, they may exist.
+func (f *LinkFilter) MayExist(ctx context.Context, key string) bool {
+	f.checks.Add(1)
+	if f.filter.MayContain(key) {
+		return true
+	}
+	if f.mirror != nil {
+		found, err := f.mirror.HasBits(domain.WithoutWorkspace(ctx), f.mirrorKey, f.filter.Locations(key))
+		if err != nil {
+			f.logger.Warn("Failed to check link filter mirror", zap.String("key", key), zap.Error(err))
+			return true
+		}
+		if found {
+			f.filter.Add(key)
+			return true
+		}
+	}
+	f.rejected.Add(1)
+	return false
+}
+
+// Add records a link created on this instance, here and in the mirror
+func (f *LinkFilter) Add(ctx context.Context, key string) {
+	bits := f.filter.Add(key)
+	if f.mirror == nil {
+		return
+	}
+	if err := f.mirror.SetBits(domain.WithoutWorkspace(ctx), f.mirrorKey, bits); err != nil {
+		f.logger.Warn("Failed to mirror link filter", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// LinkChanged records links created on other instances
+func (f *LinkFilter) LinkChanged(change *domain.LinkChange) {
+	f.filter.Add(domain.LinkKey(change.Domain, change.ShortCode))
+}
+
+// LinkChangesMissed catches up on links created while announcements were
+// lost
+func (f *LinkFilter) LinkChangesMissed() {
+	go f.catchUp(context.Background())
+}
+
+// catchUp adds links this instance may not have been told about: from the
+// mirror if there is one, or else from the repository
+func (f *LinkFilter) catchUp(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, linkFilterSyncTimeout)
+	defer cancel()
+
+	var err error
+	if f.mirror != nil {
+		err = f.sync(ctx)
+	} else {
+		err = f.Rebuild(ctx)
+	}
+	if err != nil && ctx.Err() == nil {
+		f.logger.Error("Failed to catch up on missed links", zap.Error(err))
+	}
+}
+
+// Run catches up every SyncInterval, until ctx is cancelled. Announcements
+// that fail to publish are not reported as missed to anyone, so this bounds
+// how long other instances turn away a link created meanwhile.
+func (f *LinkFilter) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.catchUp(ctx)
+		}
+	}
+}
+
+// Stats reports the filter's size, fill and how often it answered alone
+func (f *LinkFilter) Stats() domain.LinkFilterStats {
+	return domain.LinkFilterStats{
+		Bits:              f.filter.Bits(),
+		Hashes:            f.filter.Hashes(),
+		Added:             f.filter.Added(),
+		FalsePositiveRate: f.filter.FalsePositiveRate(),
+		Checks:            f.checks.Load(),
+		Rejected:          f.rejected.Load(),
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/memory"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/redis"
+)
+
+var testBloomConfig = config.BloomFilterConfig{Enabled: true, Capacity: 1000, FalsePositiveRate: 0.001, RedisKey: "bloom:links"}
+
+func TestLinkFilter_Rebuild(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+	require.NoError(t, store.CreateURL(ctx, &domain.URL{ShortCode: "abc", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateURL(ctx, &domain.URL{Domain: "go.brand.com", ShortCode: "sale", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+
+	filter := NewLinkFilter(store, nil, zaptest.NewLogger(t), testBloomConfig)
+	require.NoError(t, filter.Rebuild(ctx))
+
+	assert.True(t, filter.MayExist(ctx, "abc"))
+	assert.True(t, filter.MayExist(ctx, "go.brand.com/sale"))
+	assert.False(t, filter.MayExist(ctx, "sale"), "codes belong to their domain")
+	assert.False(t, filter.MayExist(ctx, "missing"))
+
+	filter.LinkChanged(&domain.LinkChange{ShortCode: "elsewhere"})
+	assert.True(t, filter.MayExist(ctx, "elsewhere"), "links created on other instances are added")
+
+	stats := filter.Stats()
+	assert.Equal(t, int64(3), stats.Added)
+	assert.Equal(t, int64(5), stats.Checks)
+	assert.Equal(t, int64(2), stats.Rejected)
+	assert.Less(t, stats.FalsePositiveRate, 0.001)
+}
+
+func TestLinkFilter_Mirror(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	mirror, err := redis.NewCacheRepository("redis://" + srv.Addr())
+	require.NoError(t, err)
+	defer mirror.Close()
+
+	store := memory.NewStore(nil)
+	a := NewLinkFilter(store, mirror, zaptest.NewLogger(t), testBloomConfig)
+	b := NewLinkFilter(store, mirror, zaptest.NewLogger(t), testBloomConfig)
+	require.NoError(t, a.Rebuild(ctx))
+	require.NoError(t, b.Rebuild(ctx))
+
+	a.Add(domain.WithWorkspace(ctx, 3), "fresh")
+	a.Add(ctx, "later")
+	require.False(t, b.filter.MayContain("fresh"), "b was not told")
+	assert.True(t, b.MayExist(ctx, "fresh"), "b finds links it was not told about in the shared bitmap")
+	assert.True(t, b.filter.MayContain("fresh"), "and remembers them")
+	assert.False(t, b.MayExist(ctx, "missing"))
+	assert.Equal(t, int64(1), b.Stats().Rejected)
+
+	// After missing announcements, b catches up from the shared bitmap
+	b.LinkChangesMissed()
+	assert.Eventually(t, func() bool { return b.filter.MayContain("later") }, time.Second, 10*time.Millisecond)
+
+	// A new instance starts with every link in the mirror
+	c := NewLinkFilter(store, mirror, zaptest.NewLogger(t), testBloomConfig)
+	require.NoError(t, c.Rebuild(ctx))
+	assert.True(t, c.filter.MayContain("fresh"))
+
+	// Without the mirror, lookups are let through rather than turned away
+	srv.Close()
+	assert.True(t, c.MayExist(ctx, "missing"))
+}
+
+func TestLinkFilter_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := memory.NewStore(nil)
+	cfg := testBloomConfig
+	cfg.SyncInterval = 10 * time.Millisecond
+
+	filter := NewLinkFilter(store, nil, zaptest.NewLogger(t), cfg)
+	require.NoError(t, filter.Rebuild(ctx))
+	go filter.Run(ctx)
+
+	// Created on another instance whose announcement was lost
+	require.NoError(t, store.CreateURL(ctx, &domain.URL{ShortCode: "unannounced", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+	assert.Eventually(t, func() bool { return filter.MayExist(ctx, "unannounced") }, time.Second, 10*time.Millisecond)
+}
+
+// countingMirror counts writes to a mirror
+type countingMirror struct {
+	domain.BitmapRepository
+	setBits, merges int
+}
+
+func (m *countingMirror) SetBits(ctx context.Context, key string, offsets []uint64) error {
+	m.setBits++
+	return m.BitmapRepository.SetBits(ctx, key, offsets)
+}
+
+func (m *countingMirror) MergeBitmap(ctx context.Context, key string, bitmap []byte) error {
+	m.merges++
+	return m.BitmapRepository.MergeBitmap(ctx, key, bitmap)
+}
+
+func TestLinkFilter_SyncWritesChangesOnly(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	remote, err := redis.NewCacheRepository("redis://" + srv.Addr())
+	require.NoError(t, err)
+	defer remote.Close()
+	mirror := &countingMirror{BitmapRepository: remote}
+
+	store := memory.NewStore(nil)
+	require.NoError(t, store.CreateURL(ctx, &domain.URL{ShortCode: "abc", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+	filter := NewLinkFilter(store, mirror, zaptest.NewLogger(t), testBloomConfig)
+	require.NoError(t, filter.Rebuild(ctx))
+	assert.Equal(t, 1, mirror.setBits, "the new link's bits are written to the empty mirror")
+
+	require.NoError(t, filter.sync(ctx))
+	assert.Equal(t, 1, mirror.setBits, "nothing is written when nothing changed")
+
+	filter.LinkChanged(&domain.LinkChange{ShortCode: "elsewhere"})
+	require.NoError(t, filter.sync(ctx))
+	assert.Equal(t, 2, mirror.setBits)
+	assert.Zero(t, mirror.merges, "the whole bitmap is only merged for large changes")
+
+	other := NewLinkFilter(store, remote, zaptest.NewLogger(t), testBloomConfig)
+	require.NoError(t, other.sync(ctx))
+	assert.True(t, other.filter.MayContain("elsewhere"))
+}
+
+func TestURLService_LinkFilter(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	filter := NewLinkFilter(memory.NewStore(nil), nil, zaptest.NewLogger(t), testBloomConfig)
+	require.NoError(t, filter.Rebuild(ctx))
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), &config.Config{
+		Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+	}, WithLinkFilter(filter))
+
+	// Neither the cache nor the repository is asked
+	_, err := urlService.GetOriginalURL(ctx, "probe")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	mockCache.AssertNotCalled(t, "Get", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "GetURLByShortCode", mock.Anything, mock.Anything, mock.Anything)
+
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("key %w", domain.ErrNotFound))
+	mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, time.Hour).Return(nil)
+	mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").Return(nil, domain.ErrNotFound)
+	mockRepo.On("CreateURL", mock.Anything, mock.AnythingOfType("*domain.URL")).Return(nil)
+	_, err = urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com", CustomAlias: "probe"})
+	require.NoError(t, err)
+	assert.True(t, filter.MayExist(ctx, "probe"), "created links are added")
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/qr"
+)
+
+type QRService struct {
+	urlService *URLService
+	cacheRepo  domain.CacheRepository
+	logger     *zap.Logger
+	cacheTTL   time.Duration // 0 disables caching of rendered images
+}
+
+func NewQRService(urlService *URLService, cacheRepo domain.CacheRepository, logger *zap.Logger, cacheTTL time.Duration) *QRService {
+	return &QRService{
+		urlService: urlService,
+		cacheRepo:  cacheRepo,
+		logger:     logger,
+		cacheTTL:   cacheTTL,
+	}
+}
+
+// GenerateQRCode renders the short URL of a link as a QR code image; host is
+// the link's branded domain, or "" for the default one. Links that would not
+// redirect return the same errors as ResolveURL, even if their image is
+// still cached.
+func (s *QRService) GenerateQRCode(ctx context.Context, host, shortCode string, opts qr.Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	host = s.urlService.domainName(host)
+	link, err := s.urlService.lookupURL(ctx, host, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.urlService.checkRedirects(link); err != nil {
+		return nil, err
+	}
+
+	// Try cache first
+	cacheKey := fmt.Sprintf("qr:%s:%s", domain.LinkKey(host, shortCode), opts.Key())
+	if s.cacheTTL > 0 {
+		var cached []byte
+		if err := s.cacheRepo.Get(ctx, cacheKey, &cached); err == nil {
+			return cached, nil
+		}
+	}
+
+	image, err := qr.Encode(s.urlService.shortURL(link), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cacheTTL > 0 {
+		if err := s.cacheRepo.Set(ctx, cacheKey, image, s.cacheTTL); err != nil {
+			s.logger.Warn("Failed to cache QR code", zap.Error(err))
+		}
+	}
+
+	return image, nil
+}
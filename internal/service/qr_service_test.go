@@ -0,0 +1,135 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/qr"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/service"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newQRService(t *testing.T, urlRepo *mocks.MockURLRepository, cacheRepo *mocks.MockCacheRepository, ttl time.Duration) *service.QRService {
+	logger := zaptest.NewLogger(t)
+	urlService := service.NewURLService(urlRepo, cacheRepo, logger, &config.Config{
+		Server: config.ServerConfig{BaseURL: "http://sho.rt"},
+	})
+	return service.NewQRService(urlService, cacheRepo, logger, ttl)
+}
+
+func TestGenerateQRCode_RendersAndCaches(t *testing.T) {
+	ctx := context.Background()
+	urlRepo := new(mocks.MockURLRepository)
+	cacheRepo := new(mocks.MockCacheRepository)
+	opts := qr.DefaultOptions()
+
+	cacheRepo.On("Get", ctx, "qr:abc123:"+opts.Key(), mock.Anything).Return(errors.New("cache miss"))
+	cacheRepo.On("Get", ctx, "url:abc123", mock.Anything).
+		Run(func(args mock.Arguments) {
+			dest := args.Get(2).(*domain.URL)
+			*dest = domain.URL{ShortCode: "abc123", OriginalURL: "https://example.com", CreatedAt: time.Now()}
+		}).
+		Return(nil)
+	cacheRepo.On("Set", ctx, "qr:abc123:"+opts.Key(), mock.AnythingOfType("[]uint8"), 24*time.Hour).Return(nil)
+
+	svc := newQRService(t, urlRepo, cacheRepo, 24*time.Hour)
+
+	image, err := svc.GenerateQRCode(ctx, "", "abc123", opts)
+	require.NoError(t, err)
+	_, err = png.Decode(bytes.NewReader(image))
+	require.NoError(t, err)
+
+	cacheRepo.AssertExpectations(t)
+}
+
+func TestGenerateQRCode_FromCache(t *testing.T) {
+	ctx := context.Background()
+	urlRepo := new(mocks.MockURLRepository)
+	cacheRepo := new(mocks.MockCacheRepository)
+	opts := qr.DefaultOptions()
+	opts.Format = qr.FormatSVG
+
+	cacheRepo.On("Get", ctx, "url:abc123", mock.Anything).
+		Run(func(args mock.Arguments) {
+			dest := args.Get(2).(*domain.URL)
+			*dest = domain.URL{ShortCode: "abc123", OriginalURL: "https://example.com", CreatedAt: time.Now()}
+		}).
+		Return(nil)
+	cacheRepo.On("Get", ctx, "qr:abc123:"+opts.Key(), mock.Anything).
+		Run(func(args mock.Arguments) {
+			dest := args.Get(2).(*[]byte)
+			*dest = []byte("<svg/>")
+		}).
+		Return(nil)
+
+	svc := newQRService(t, urlRepo, cacheRepo, time.Hour)
+
+	image, err := svc.GenerateQRCode(ctx, "", "abc123", opts)
+	require.NoError(t, err)
+	require.Equal(t, []byte("<svg/>"), image)
+
+	urlRepo.AssertNotCalled(t, "GetURLByShortCode", mock.Anything, "", mock.Anything)
+}
+
+func TestGenerateQRCode_NotFound(t *testing.T) {
+	ctx := context.Background()
+	urlRepo := new(mocks.MockURLRepository)
+	cacheRepo := new(mocks.MockCacheRepository)
+
+	// Caching disabled: only the link lookup touches the cache
+	cacheRepo.On("Get", ctx, "url:missing", mock.Anything).Return(errors.New("cache miss"))
+	urlRepo.On("GetURLByShortCode", mock.Anything, "", "missing").Return(nil, domain.ErrNotFound)
+
+	svc := newQRService(t, urlRepo, cacheRepo, 0)
+
+	_, err := svc.GenerateQRCode(ctx, "", "missing", qr.DefaultOptions())
+	require.Equal(t, service.ErrURLNotFound, err)
+}
+
+func TestGenerateQRCode_LinkNotRedirecting(t *testing.T) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	cases := []struct {
+		name string
+		link domain.URL
+		want error
+	}{
+		{"Expired", domain.URL{ExpiresAt: &past}, service.ErrURLExpired},
+		{"Disabled", domain.URL{Disabled: true}, service.ErrURLDisabled},
+		{"NotActive", domain.URL{ActivatesAt: &future}, service.ErrURLNotActive},
+		{"Unsafe", domain.URL{SafetyStatus: domain.SafetyUnsafe}, service.ErrUnsafeURL},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlRepo := new(mocks.MockURLRepository)
+			cacheRepo := new(mocks.MockCacheRepository)
+			opts := qr.DefaultOptions()
+
+			link := tc.link
+			link.ShortCode, link.OriginalURL, link.CreatedAt = "abc123", "https://example.com", time.Now()
+			cacheRepo.On("Get", ctx, "url:abc123", mock.Anything).
+				Run(func(args mock.Arguments) { *args.Get(2).(*domain.URL) = link }).
+				Return(nil)
+			// Rendered while the link still worked
+			cacheRepo.On("Get", ctx, "qr:abc123:"+opts.Key(), mock.Anything).
+				Run(func(args mock.Arguments) { *args.Get(2).(*[]byte) = []byte("<svg/>") }).
+				Return(nil)
+
+			svc := newQRService(t, urlRepo, cacheRepo, time.Hour)
+
+			image, err := svc.GenerateQRCode(ctx, "", "abc123", opts)
+			require.ErrorIs(t, err, tc.want)
+			require.Nil(t, image)
+		})
+	}
+}
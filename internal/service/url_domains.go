@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	neturl "net/url"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// DomainForHost maps the Host header of a redirect to the namespace its
+// short codes live in: a registered branded domain, or "" for the default
+// domain when the host is the base URL's or is not registered. Lookups,
+// including misses, are cached. In a workspace-scoped context only the
+// workspace's own domains are found.
+func (s *URLService) DomainForHost(ctx context.Context, host string) string {
+	host = s.domainName(host)
+	if host == "" || s.domains == nil {
+		return ""
+	}
+
+	cacheKey := domainCacheKey(host)
+	var cached domain.Domain
+	if err := s.cacheRepo.Get(ctx, cacheKey, &cached); err == nil {
+		return cached.Name // Empty for a cached miss
+	}
+
+	registered, err := s.domains.GetDomain(ctx, host)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		// Only misses are remembered; the host may be registered after all
+		s.logger.Warn("Failed to look up domain", zap.String("domain", host), zap.Error(err))
+		return ""
+	}
+	if err != nil {
+		registered = &domain.Domain{}
+	}
+	if err := s.cacheRepo.Set(domain.AsCacheFill(ctx), cacheKey, registered, domainCacheTTL); err != nil {
+		s.logger.Warn("Failed to cache domain", zap.Error(err))
+	}
+	return registered.Name
+}
+
+// requestDomain returns the namespace a new link is created in, refusing
+// domains that have not been registered
+func (s *URLService) requestDomain(ctx context.Context, name string) (string, error) {
+	name = s.domainName(name)
+	if name == "" {
+		return "", nil
+	}
+	if s.DomainForHost(ctx, name) != name {
+		return "", ErrUnknownDomain
+	}
+	return name, nil
+}
+
+// isBrandedURL reports whether rawURL points at one of the branded domains
+func (s *URLService) isBrandedURL(ctx context.Context, rawURL string) bool {
+	if s.domains == nil {
+		return false
+	}
+	u, err := neturl.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return s.DomainForHost(domain.WithoutWorkspace(ctx), u.Host) != ""
+}
+
+// domainName normalizes a domain given by a client, mapping the default
+// domain to ""
+func (s *URLService) domainName(host string) string {
+	host = domain.NormalizeHost(host)
+	if host == defaultHost(s.cfg) {
+		return ""
+	}
+	return host
+}
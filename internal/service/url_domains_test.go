@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
+)
+
+func TestURLService_DomainForHost(t *testing.T) {
+	domainRepo := new(mocks.MockDomainRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	urlService := NewURLService(new(mocks.MockURLRepository), mockCache, zaptest.NewLogger(t), &config.Config{
+		Server: config.ServerConfig{BaseURL: "https://sho.rt"},
+	}, WithDomainRepository(domainRepo))
+	ctx := context.Background()
+
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("not found"))
+	mockCache.On("Set", mock.Anything, "domain:go.brand.com", &domain.Domain{Name: "go.brand.com"}, domainCacheTTL).Return(nil)
+	mockCache.On("Set", mock.Anything, "domain:other.com", &domain.Domain{}, domainCacheTTL).Return(nil)
+	domainRepo.On("GetDomain", mock.Anything, "go.brand.com").Return(&domain.Domain{Name: "go.brand.com"}, nil)
+	domainRepo.On("GetDomain", mock.Anything, "other.com").Return(nil, domain.ErrNotFound)
+
+	assert.Equal(t, "go.brand.com", urlService.DomainForHost(ctx, "GO.brand.com:443"))
+	assert.Equal(t, "", urlService.DomainForHost(ctx, "other.com"))   // Unknown hosts use the default domain
+	assert.Equal(t, "", urlService.DomainForHost(ctx, "sho.rt:8080")) // The base URL's host is the default domain
+	assert.Equal(t, "", urlService.DomainForHost(ctx, ""))
+	domainRepo.AssertNotCalled(t, "GetDomain", mock.Anything, "sho.rt")
+	mockCache.AssertExpectations(t)
+}
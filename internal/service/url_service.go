@@ -2,11 +2,21 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	neturl "net/url"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
@@ -18,22 +28,111 @@ var (
 	ErrURLExpired       = errors.New("URL has expired")
 	ErrInvalidURL       = errors.New("invalid URL")
 	ErrCustomAliasTaken = errors.New("custom alias already taken")
+	ErrCodesExhausted   = errors.New("no free short code found")
+	ErrURLNotActive     = errors.New("URL is not active yet")
+	ErrInvalidSchedule  = errors.New("activation time must be before expiration time")
+
+	ErrInvalidRedirectStatus = errors.New("redirect status must be one of 301, 302, 307 or 308")
+	ErrInvalidGeoRule        = errors.New("geo rules need a two-letter country code and a valid URL")
+	ErrInvalidDeviceRule     = errors.New("device rules need a known os, device or browser and a valid URL")
+	ErrInvalidVariants       = errors.New("variants need unique names, valid URLs, weights from 1 to 10000 and a random or sticky mode")
+	ErrUnsafeURL             = errors.New("URL destination was flagged as unsafe")
+	ErrDestinationNotAllowed = errors.New("URL destination must be a public address and not another short link")
+	ErrURLDisabled           = errors.New("URL has been disabled")
+	ErrUnknownDomain         = errors.New("domain is not registered")
+	ErrLinkQuotaExceeded     = errors.New("workspace link quota exceeded")
+	ErrInvalidOpenGraph      = errors.New("open graph image must be a valid URL and title and description must not be too long")
 )
 
+// maxShortCodeAttempts bounds how often a generated short code that is
+// already taken is replaced before giving up
+const maxShortCodeAttempts = 3
+
 type URLService struct {
-	urlRepo   domain.URLRepository
-	cacheRepo domain.CacheRepository
-	logger    *zap.Logger
-	cfg       *config.Config
+	urlRepo       domain.URLRepository
+	cacheRepo     domain.CacheRepository
+	logger        *zap.Logger
+	cfg           *config.Config
+	analyticsRepo domain.AnalyticsRepository // optional per-click analytics
+	geo           domain.GeoLocator          // optional IP to country lookups
+	scanner       domain.URLScanner          // optional destination safety checks
+	safetyRepo    domain.SafetyRepository    // optional storage for periodic rescans
+	policy        domain.DestinationPolicy   // optional network policy for destinations
+	metadata      domain.MetadataFetcher     // optional social preview lookups on create
+	domains       domain.DomainRepository    // optional branded domains
+	workspaces    domain.WorkspaceRepository // optional link quotas
+	links         domain.LinkPublisher       // optional link change announcements to other instances
+	filter        *LinkFilter                // optional Bloom filter of existing links
+
+	lookups singleflight.Group // Coalesces concurrent cache misses per link
+}
+
+// Option configures optional URLService dependencies
+type Option func(*URLService)
+
+// WithAnalyticsRepository records every resolved click, not just the counter
+func WithAnalyticsRepository(analyticsRepo domain.AnalyticsRepository) Option {
+	return func(s *URLService) { s.analyticsRepo = analyticsRepo }
+}
+
+// WithGeoLocator enables geo rules and fills in the country of recorded clicks
+func WithGeoLocator(geo domain.GeoLocator) Option {
+	return func(s *URLService) { s.geo = geo }
+}
+
+// WithURLScanner checks destinations when links are created and rejects flagged ones
+func WithURLScanner(scanner domain.URLScanner) Option {
+	return func(s *URLService) { s.scanner = scanner }
+}
+
+// WithSafetyRepository lets RescanURLs re-check stored links
+func WithSafetyRepository(safetyRepo domain.SafetyRepository) Option {
+	return func(s *URLService) { s.safetyRepo = safetyRepo }
+}
+
+// WithDestinationPolicy rejects destinations the policy does not allow
+func WithDestinationPolicy(policy domain.DestinationPolicy) Option {
+	return func(s *URLService) { s.policy = policy }
+}
+
+// WithMetadataFetcher reads the destination's Open Graph metadata when a link is created
+func WithMetadataFetcher(metadata domain.MetadataFetcher) Option {
+	return func(s *URLService) { s.metadata = metadata }
+}
+
+// WithDomainRepository enables branded domains, each with its own short codes
+func WithDomainRepository(domains domain.DomainRepository) Option {
+	return func(s *URLService) { s.domains = domains }
+}
+
+// WithWorkspaceRepository enforces the link quotas of workspaces
+func WithWorkspaceRepository(workspaces domain.WorkspaceRepository) Option {
+	return func(s *URLService) { s.workspaces = workspaces }
+}
+
+// WithLinkPublisher announces created and changed links, so every instance
+// drops what it holds about them
+func WithLinkPublisher(links domain.LinkPublisher) Option {
+	return func(s *URLService) { s.links = links }
+}
+
+// WithLinkFilter answers lookups of short codes the filter rules out
+// without asking the cache or the database. The filter must be rebuilt.
+func WithLinkFilter(filter *LinkFilter) Option {
+	return func(s *URLService) { s.filter = filter }
 }
 
-func NewURLService(urlRepo domain.URLRepository, cacheRepo domain.CacheRepository, logger *zap.Logger, cfg *config.Config) *URLService {
-	return &URLService{
+func NewURLService(urlRepo domain.URLRepository, cacheRepo domain.CacheRepository, logger *zap.Logger, cfg *config.Config, opts ...Option) *URLService {
+	s := &URLService{
 		urlRepo:   urlRepo,
 		cacheRepo: cacheRepo,
 		logger:    logger,
 		cfg:       cfg,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *URLService) ShortenURL(ctx context.Context, req *domain.ShortenRequest) (*domain.ShortenResponse, error) {
@@ -42,22 +141,54 @@ func (s *URLService) ShortenURL(ctx context.Context, req *domain.ShortenRequest)
 		return nil, ErrInvalidURL
 	}
 
-	cache2 := fmt.Sprintf("lurl:%s", req.URL)
-	var cachedURL domain.URL
-	err := s.cacheRepo.Get(ctx, cache2, &cachedURL)
-	if err == nil {
-		if !s.isExpired(&cachedURL) {
-			// Increment click count asynchronously
-			return s.buildResponse(&cachedURL), nil
-		}
+	// Merge UTM and extra query parameters so deduplication sees the final URL
+	originalURL, err := composeURL(req)
+	if err != nil || !utils.IsValidURL(originalURL) {
+		return nil, ErrInvalidURL
 	}
-	// Check if URL already exists
-	if existing, err := s.urlRepo.GetURLByOriginalURL(ctx, req.URL); err == nil {
-		cacheKey2 := fmt.Sprintf("lurl:%s", existing.OriginalURL)
-		if err := s.cacheRepo.Set(ctx, cacheKey2, existing, time.Hour); err != nil {
-			s.logger.Warn("Failed to cache URL", zap.Error(err))
+	if req.ComingSoonURL != "" && !utils.IsValidURL(req.ComingSoonURL) {
+		return nil, ErrInvalidURL
+	}
+	if req.RedirectStatus != 0 && !domain.IsValidRedirectStatus(req.RedirectStatus) {
+		return nil, ErrInvalidRedirectStatus
+	}
+	geoRules, err := normalizeGeoRules(req.GeoRules)
+	if err != nil {
+		return nil, err
+	}
+	deviceRules, err := normalizeDeviceRules(req.DeviceRules, s.cfg.Validation.DeepLinkSchemes)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateVariants(req.Variants, req.VariantMode); err != nil {
+		return nil, err
+	}
+	if req.ActivatesAt != nil && req.ExpiresAt != nil && !req.ActivatesAt.Before(*req.ExpiresAt) {
+		return nil, ErrInvalidSchedule
+	}
+	if err := validateOpenGraph(req.OpenGraph); err != nil {
+		return nil, err
+	}
+	host, err := s.requestDomain(ctx, req.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	// A plain request reuses a plain link to the same destination; links
+	// with options are never shared
+	if !req.HasLinkOptions() {
+		existing, err := s.existingURL(ctx, host, originalURL)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return s.buildResponse(existing), nil
 		}
-		return s.buildResponse(existing), nil
+	}
+
+	workspaceID, _ := domain.WorkspaceFromContext(ctx)
+	if err := s.checkLinkQuota(ctx, workspaceID); err != nil {
+		return nil, err
 	}
 
 	// Generate short code
@@ -68,99 +199,878 @@ func (s *URLService) ShortenURL(ctx context.Context, req *domain.ShortenRequest)
 		if len(req.CustomAlias) < 3 || len(req.CustomAlias) > 20 {
 			return nil, fmt.Errorf("custom alias must be between 3 and 20 characters")
 		}
-
-		exists, err := s.urlRepo.IsShortCodeExists(ctx, req.CustomAlias)
-		if err != nil {
-			return nil, fmt.Errorf("failed to check alias availability: %w", err)
-		}
-		if exists {
-			return nil, fmt.Errorf("custom alias already exists")
+		if strings.HasSuffix(req.CustomAlias, "+") { // Reserved for link previews
+			return nil, fmt.Errorf("custom alias must not end with '+'")
 		}
 		shortCode = req.CustomAlias
-	} else {
-		shortCode = utils.GenerateID(s.cfg.MachineID())
-		if shortCode == "" {
-			return nil, fmt.Errorf("failed to generate short code: %w", err)
-		}
+	} else if shortCode, err = s.generateShortCode(); err != nil {
+		return nil, err
 	}
 
 	// Create URL record
 	url := &domain.URL{
+		WorkspaceID: workspaceID,
+		Domain:      host,
 		ShortCode:   shortCode,
-		OriginalURL: req.URL,
+		OriginalURL: originalURL,
 		CreatedAt:   time.Now(),
 		ExpiresAt:   req.ExpiresAt,
+
+		ActivatesAt:    req.ActivatesAt,
+		ComingSoonURL:  req.ComingSoonURL,
+		RedirectStatus: req.RedirectStatus,
+		ForwardQuery:   req.ForwardQuery,
+		ForwardPath:    req.ForwardPath,
+		GeoRules:       geoRules,
+		DeviceRules:    deviceRules,
+		Variants:       req.Variants,
+		VariantMode:    req.VariantMode,
+		Interstitial:   req.Interstitial,
 	}
 
-	if err := s.urlRepo.CreateURL(ctx, url); err != nil {
-		s.logger.Error("Failed to create URL", zap.Error(err))
-		return nil, fmt.Errorf("failed to create URL: %w", err)
+	if err := s.allowDestinations(ctx, url); err != nil {
+		return nil, err
+	}
+
+	// Scan before storing; an incomplete scan leaves the link for the periodic rescan
+	status, err := s.checkSafety(ctx, url)
+	if status == domain.SafetyUnsafe {
+		return nil, ErrUnsafeURL
+	}
+	if err != nil {
+		s.logger.Warn("Failed to scan URL", zap.String("original_url", originalURL), zap.Error(err))
+	} else if status != "" {
+		checkedAt := time.Now()
+		url.SafetyStatus, url.SafetyCheckedAt = status, &checkedAt
+	}
+
+	og := req.OpenGraph.Merge(s.fetchOpenGraph(ctx, req.OpenGraph, originalURL))
+	url.OGTitle, url.OGDescription, url.OGImage = og.Title, og.Description, og.Image
+
+	if err := s.createURL(ctx, url, req.CustomAlias != ""); err != nil {
+		return nil, err
+	}
+
+	if s.filter != nil {
+		s.filter.Add(ctx, url.Key())
 	}
 
 	// Cache the URL
-	cacheKey := fmt.Sprintf("url:%s", shortCode)
+	s.forgetMissing(ctx, url)
+	cacheKey := fmt.Sprintf("url:%s", url.Key())
 	if err := s.cacheRepo.Set(ctx, cacheKey, url, time.Hour); err != nil {
 		s.logger.Warn("Failed to cache URL", zap.Error(err))
 	}
-	cacheKey2 := fmt.Sprintf("lurl:%s", url.OriginalURL)
-	if err := s.cacheRepo.Set(ctx, cacheKey2, url, time.Hour); err != nil {
-		s.logger.Warn("Failed to cache URL", zap.Error(err))
+	if !url.HasLinkOptions() {
+		cacheKey2 := fmt.Sprintf("lurl:%s", domain.LinkKey(host, url.OriginalURL))
+		if err := s.cacheRepo.Set(ctx, cacheKey2, url, time.Hour); err != nil {
+			s.logger.Warn("Failed to cache URL", zap.Error(err))
+		}
 	}
+	announceLink(ctx, s.links, s.logger, url)
 	s.logger.Info("URL shortened successfully",
-		zap.String("short_code", shortCode),
-		zap.String("original_url", req.URL),
+		zap.String("short_code", url.ShortCode),
+		zap.String("original_url", originalURL),
 	)
 
 	return s.buildResponse(url), nil
 }
 
+// existingURL returns the newest plain link to originalURL on host, or nil
+// if there is none to reuse
+func (s *URLService) existingURL(ctx context.Context, host, originalURL string) (*domain.URL, error) {
+	cacheKey := fmt.Sprintf("lurl:%s", domain.LinkKey(host, originalURL))
+	var cachedURL domain.URL
+	if err := s.cacheRepo.Get(ctx, cacheKey, &cachedURL); err == nil {
+		if !s.isExpired(&cachedURL) && !cachedURL.HasLinkOptions() {
+			return &cachedURL, nil
+		}
+	}
+
+	existing, err := s.urlRepo.GetURLByOriginalURL(ctx, host, originalURL)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up URL: %w", err)
+	}
+	if existing.SafetyStatus == domain.SafetyUnsafe {
+		return nil, ErrUnsafeURL
+	}
+	if existing.HasLinkOptions() {
+		return nil, nil
+	}
+	if err := s.cacheRepo.Set(domain.AsCacheFill(ctx), cacheKey, existing, time.Hour); err != nil {
+		s.logger.Warn("Failed to cache URL", zap.Error(err))
+	}
+	return existing, nil
+}
+
+// generateShortCode returns a new short code for a link without an alias
+func (s *URLService) generateShortCode() (string, error) {
+	shortCode := utils.GenerateID(s.cfg.MachineID())
+	if shortCode == "" {
+		return "", fmt.Errorf("failed to generate short code: invalid machine ID %d", s.cfg.MachineID())
+	}
+	return shortCode, nil
+}
+
+// createURL stores a new link, relying on the repository's uniqueness of
+// short codes per domain rather than on an earlier existence check. A taken
+// alias yields ErrCustomAliasTaken; a taken generated code, which only
+// happens when instances share a machine ID, is replaced and retried until
+// ErrCodesExhausted.
+func (s *URLService) createURL(ctx context.Context, url *domain.URL, alias bool) error {
+	for attempt := 1; ; attempt++ {
+		err := s.urlRepo.CreateURL(ctx, url)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, domain.ErrConflict) && alias {
+			return ErrCustomAliasTaken
+		}
+		if !errors.Is(err, domain.ErrConflict) {
+			s.logger.Error("Failed to create URL", zap.Error(err))
+			return fmt.Errorf("failed to create URL: %w", err)
+		}
+		if attempt == maxShortCodeAttempts {
+			s.logger.Error("Generated short codes keep colliding, check that machine IDs are unique",
+				zap.String("short_code", url.ShortCode), zap.Int("attempts", attempt))
+			return ErrCodesExhausted
+		}
+
+		s.logger.Warn("Generated short code already taken, retrying", zap.String("short_code", url.ShortCode))
+		if url.ShortCode, err = s.generateShortCode(); err != nil {
+			return err
+		}
+	}
+}
+
+// composeURL merges the request's extra query parameters and UTM fields into
+// its URL. UTM fields take precedence, and both replace values already present.
+func composeURL(req *domain.ShortenRequest) (string, error) {
+	params := neturl.Values{}
+	for key, value := range req.QueryParams {
+		if key != "" {
+			params.Set(key, value)
+		}
+	}
+	for key, values := range req.UTM.Values() {
+		params[key] = values
+	}
+	return utils.SetQuery(req.URL, params)
+}
+
+// normalizeGeoRules validates geo rules and upper-cases their country codes
+func normalizeGeoRules(rules []domain.GeoRule) (domain.GeoRules, error) {
+	normalized := make(domain.GeoRules, 0, len(rules))
+	for _, rule := range rules {
+		if len(rule.Country) != 2 || !utils.IsValidURL(rule.URL) {
+			return nil, ErrInvalidGeoRule
+		}
+		normalized = append(normalized, domain.GeoRule{
+			Country: strings.ToUpper(rule.Country),
+			URL:     rule.URL,
+		})
+	}
+	return normalized, nil
+}
+
+// normalizeDeviceRules validates device rules and lower-cases their match
+// fields. Their destinations may be web URLs or deep links into the apps
+// whose schemes are allowed.
+func normalizeDeviceRules(rules []domain.DeviceRule, schemes []string) (domain.DeviceRules, error) {
+	normalized := make(domain.DeviceRules, 0, len(rules))
+	for _, rule := range rules {
+		rule.OS = strings.ToLower(rule.OS)
+		rule.Device = strings.ToLower(rule.Device)
+		rule.Browser = strings.ToLower(rule.Browser)
+
+		if rule.OS == "" && rule.Device == "" && rule.Browser == "" {
+			return nil, ErrInvalidDeviceRule
+		}
+		if (rule.OS != "" && !utils.IsKnownOS(rule.OS)) ||
+			(rule.Device != "" && !utils.IsKnownDevice(rule.Device)) ||
+			(rule.Browser != "" && !utils.IsKnownBrowser(rule.Browser)) ||
+			!(utils.IsValidURL(rule.URL) || utils.IsValidDeepLink(rule.URL, schemes)) {
+			return nil, ErrInvalidDeviceRule
+		}
+		normalized = append(normalized, rule)
+	}
+	return normalized, nil
+}
+
+// validateVariants checks the A/B destinations of a split link
+func validateVariants(variants domain.Variants, mode string) error {
+	if mode != "" && mode != domain.VariantModeRandom && mode != domain.VariantModeSticky {
+		return ErrInvalidVariants
+	}
+
+	names := make(map[string]bool, len(variants))
+	total := 0
+	for _, variant := range variants {
+		if variant.Name == "" || len(variant.Name) > 64 || names[variant.Name] ||
+			variant.Weight <= 0 || variant.Weight > domain.MaxVariantWeight || !utils.IsValidURL(variant.URL) {
+			return ErrInvalidVariants
+		}
+		names[variant.Name] = true
+		if total += variant.Weight; total > domain.MaxVariantTotalWeight {
+			return ErrInvalidVariants
+		}
+	}
+	return nil
+}
+
+func validateOpenGraph(og *domain.OpenGraph) error {
+	if og == nil {
+		return nil
+	}
+	if og.Image != "" && !utils.IsValidURL(og.Image) {
+		return ErrInvalidOpenGraph
+	}
+	if len(og.Title) > domain.MaxOGTitleLength || len(og.Description) > domain.MaxOGDescriptionLength {
+		return ErrInvalidOpenGraph
+	}
+	return nil
+}
+
+// fetchOpenGraph reads the destination's metadata unless the overrides
+// already fill every field. Failures only cost the link its preview.
+func (s *URLService) fetchOpenGraph(ctx context.Context, overrides *domain.OpenGraph, originalURL string) *domain.OpenGraph {
+	if s.metadata == nil || (overrides != nil && overrides.Title != "" && overrides.Description != "" && overrides.Image != "") {
+		return nil
+	}
+	og, err := s.metadata.FetchOpenGraph(ctx, originalURL)
+	if err != nil {
+		s.logger.Warn("Failed to fetch Open Graph metadata", zap.String("original_url", originalURL), zap.Error(err))
+		return nil
+	}
+	return og
+}
+
+// allowDestinations applies the destination policy to every destination of a link
+func (s *URLService) allowDestinations(ctx context.Context, url *domain.URL) error {
+	for _, destination := range destinations(url) {
+		if s.isBrandedURL(ctx, destination) { // Branded domains are short links too
+			s.logger.Warn("Destination not allowed", zap.String("destination", destination))
+			return ErrDestinationNotAllowed
+		}
+		if s.policy == nil {
+			continue
+		}
+		if err := s.policy.Allow(ctx, destination); err != nil {
+			s.logger.Warn("Destination not allowed",
+				zap.String("destination", destination),
+				zap.Error(err),
+			)
+			return ErrDestinationNotAllowed
+		}
+	}
+	return nil
+}
+
+// checkSafety scans every destination of a link. It returns SafetyUnsafe as
+// soon as one is flagged, SafetySafe when all scans completed cleanly, and an
+// empty status with the error when a scan could not finish. Without a
+// scanner nothing is checked and the status is empty.
+func (s *URLService) checkSafety(ctx context.Context, url *domain.URL) (string, error) {
+	if s.scanner == nil {
+		return "", nil
+	}
+
+	var errs []error
+	for _, destination := range destinations(url) {
+		result, err := s.scanner.Scan(ctx, destination)
+		if !result.Safe() {
+			s.logger.Warn("Unsafe destination",
+				zap.String("short_code", url.ShortCode),
+				zap.Stringer("scan", result),
+			)
+			return domain.SafetyUnsafe, nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return "", err
+	}
+	return domain.SafetySafe, nil
+}
+
+// destinations lists every URL a link may send visitors to, without duplicates
+func destinations(url *domain.URL) []string {
+	all := []string{url.OriginalURL, url.ComingSoonURL}
+	for _, rule := range url.GeoRules {
+		all = append(all, rule.URL)
+	}
+	for _, rule := range url.DeviceRules {
+		all = append(all, rule.URL)
+	}
+	for _, variant := range url.Variants {
+		all = append(all, variant.URL)
+	}
+
+	seen := make(map[string]bool, len(all))
+	unique := all[:0]
+	for _, destination := range all {
+		if destination != "" && !seen[destination] {
+			seen[destination] = true
+			unique = append(unique, destination)
+		}
+	}
+	return unique
+}
+
+// RescanURLs re-checks up to limit links that were never scanned or were
+// last scanned before checkedBefore, and returns how many were flagged.
+// Links whose verdict changes are evicted from the cache so redirects see it.
+func (s *URLService) RescanURLs(ctx context.Context, checkedBefore time.Time, limit int) (int, error) {
+	if s.scanner == nil || s.safetyRepo == nil {
+		return 0, nil
+	}
+
+	urls, err := s.safetyRepo.ListURLsForRescan(ctx, checkedBefore, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list URLs for rescan: %w", err)
+	}
+
+	flagged := 0
+	for _, url := range urls {
+		status, err := s.checkSafety(ctx, url)
+		if err != nil {
+			s.logger.Warn("Failed to rescan URL", zap.String("short_code", url.ShortCode), zap.Error(err))
+			// Keep the verdict but move the link to the back of the queue, so
+			// links that keep failing do not hold up the rest
+			if err := s.safetyRepo.UpdateSafetyStatus(ctx, url.Domain, url.ShortCode, url.SafetyStatus, time.Now()); err != nil {
+				return flagged, fmt.Errorf("failed to update safety status: %w", err)
+			}
+			continue
+		}
+		if err := s.safetyRepo.UpdateSafetyStatus(ctx, url.Domain, url.ShortCode, status, time.Now()); err != nil {
+			return flagged, fmt.Errorf("failed to update safety status: %w", err)
+		}
+		if status == domain.SafetyUnsafe {
+			flagged++
+		}
+		if status != url.SafetyStatus {
+			s.evict(ctx, url)
+		}
+	}
+	return flagged, nil
+}
+
+// RunSafetyRescans calls RescanURLs every interval, re-checking links last
+// scanned more than an interval ago, until ctx is cancelled
+func (s *URLService) RunSafetyRescans(ctx context.Context, interval time.Duration, batch int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			flagged, err := s.RescanURLs(ctx, time.Now().Add(-interval), batch)
+			if err != nil {
+				s.logger.Error("Safety rescan failed", zap.Error(err))
+			} else if flagged > 0 {
+				s.logger.Warn("Safety rescan flagged URLs", zap.Int("count", flagged))
+			}
+		}
+	}
+}
+
+// evict drops both cache entries of a link
+func (s *URLService) evict(ctx context.Context, url *domain.URL) {
+	evictURL(ctx, s.cacheRepo, s.links, s.logger, url)
+}
+
+// evictURL drops a link from the shared cache used by redirects and from
+// its workspace's partition, and announces the change to other instances
+func evictURL(ctx context.Context, cacheRepo domain.CacheRepository, links domain.LinkPublisher, logger *zap.Logger, url *domain.URL) {
+
+	for _, scope := range []context.Context{domain.WithoutWorkspace(ctx), domain.WithWorkspace(ctx, url.WorkspaceID)} {
+		for _, key := range []string{fmt.Sprintf("url:%s", url.Key()), fmt.Sprintf("lurl:%s", domain.LinkKey(url.Domain, url.OriginalURL))} {
+			if err := cacheRepo.Delete(scope, key); err != nil {
+				logger.Warn("Failed to evict cached URL", zap.String("key", key), zap.Error(err))
+			}
+		}
+	}
+	announceLink(ctx, links, logger, url)
+}
+
+// announceLink publishes a change to url, if there is a publisher. A lost
+// announcement is only logged: other instances keep serving their local
+// copies of the link for up to cache.local_ttl, and their link filters add
+// it on their next sync.
+func announceLink(ctx context.Context, links domain.LinkPublisher, logger *zap.Logger, url *domain.URL) {
+	if links == nil {
+		return
+	}
+	if err := links.PublishLinkChange(ctx, domain.NewLinkChange(url)); err != nil {
+		logger.Warn("Failed to announce link change", zap.String("short_code", url.ShortCode), zap.Error(err))
+	}
+}
+
+// checkLinkQuota refuses new links once a workspace owns as many as its quota
+func (s *URLService) checkLinkQuota(ctx context.Context, workspaceID int64) error {
+	if s.workspaces == nil || workspaceID == 0 {
+		return nil
+	}
+	ws, err := s.workspaces.GetWorkspace(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get workspace: %w", err)
+	}
+	if ws.MaxLinks == 0 {
+		return nil
+	}
+	count, err := s.workspaces.CountURLs(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to count links: %w", err)
+	}
+	if count >= ws.MaxLinks {
+		return ErrLinkQuotaExceeded
+	}
+	return nil
+}
+
+// GetOriginalURL resolves a short code on the default domain to its
+// destination. Before a link's activation time it returns ErrURLNotActive
+// together with the link's coming-soon URL, which is empty when no fallback
+// was configured.
 func (s *URLService) GetOriginalURL(ctx context.Context, shortCode string) (string, error) {
+	redirect, err := s.ResolveURL(ctx, shortCode, nil)
+	if redirect == nil {
+		return "", err
+	}
+	return redirect.Location, err
+}
+
+// ResolveURL resolves a short code to the redirect a visitor should receive
+// and records the click. Like GetOriginalURL, a link that is not active yet
+// yields ErrURLNotActive with a redirect to its coming-soon page, if any.
+// The visit may be nil when there is no incoming request to pass through;
+// the short code is then looked up on the default domain.
+func (s *URLService) ResolveURL(ctx context.Context, shortCode string, visit *domain.Visit) (*domain.Redirect, error) {
+	var host string
+	if visit != nil {
+		host = visit.Domain
+	}
+	url, err := s.lookupURL(ctx, host, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if url.SafetyStatus == domain.SafetyUnsafe {
+		return nil, ErrUnsafeURL
+	}
+	if url.Disabled {
+		return nil, ErrURLDisabled
+	}
+
+	// Trailing path segments only resolve on links that forward them
+	if visit != nil && strings.Trim(visit.Path, "/") != "" && !url.ForwardPath {
+		return nil, ErrURLNotFound
+	}
+
+	if !s.isActive(url) {
+		if url.ComingSoonURL == "" {
+			return nil, ErrURLNotActive
+		}
+		// Always temporary so clients never cache the coming-soon page past launch
+		return &domain.Redirect{Location: url.ComingSoonURL, StatusCode: http.StatusFound}, ErrURLNotActive
+	}
+
+	redirect := &domain.Redirect{
+		StatusCode: s.redirectStatus(url),
+		MaxAge:     s.permanentMaxAge(),
+	}
+	country := s.lookupCountry(visit)
+	if err := s.destination(redirect, url, visit, country); err != nil {
+		return nil, fmt.Errorf("failed to build destination: %w", err)
+	}
+	if varies(url) {
+		redirect.MaxAge = 0
+	}
+
+	// The warning page is not a click; the visit counts once the visitor continues
+	if url.Interstitial && visit != nil && !visit.Confirmed {
+		redirect.Interstitial = true
+		if redirect.Variant != "" {
+			redirect.VariantToken = s.variantToken(url, redirect.Variant)
+		}
+		return redirect, nil
+	}
+
+	// Increment click count asynchronously
+	go s.incrementClickCount(context.Background(), url)
+	if s.analyticsRepo != nil {
+		click := newClick(url, visit, country)
+		click.Variant = redirect.Variant
+		go s.recordClick(context.Background(), click)
+	}
+
+	return redirect, nil
+}
+
+// GetURL returns the stored settings and short URL of a link
+func (s *URLService) GetURL(ctx context.Context, host, shortCode string) (*domain.ShortenResponse, error) {
+	url, err := s.lookupURL(ctx, s.domainName(host), shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildResponse(url), nil
+}
+
+// GetLink returns a link's settings together with its latest health check.
+// It reads the repository directly since cached copies predate recent checks.
+func (s *URLService) GetLink(ctx context.Context, host, shortCode string) (*domain.ShortenResponse, error) {
+	url, err := s.urlRepo.GetURLByShortCode(ctx, s.domainName(host), shortCode)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrURLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL: %w", err)
+	}
+	return s.buildResponse(url), nil
+}
+
+// SocialCard returns the page shown to link-unfurling crawlers: the link's
+// Open Graph metadata, falling back to the destination as the title. Links
+// that would not redirect return the same errors as ResolveURL, and no
+// click is counted.
+func (s *URLService) SocialCard(ctx context.Context, host, shortCode string) (*domain.SocialCard, error) {
+	url, err := s.lookupURL(ctx, s.domainName(host), shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRedirects(url); err != nil {
+		return nil, err
+	}
+
+	card := &domain.SocialCard{
+		ShortURL:    s.shortURL(url),
+		Destination: url.OriginalURL,
+		OpenGraph: domain.OpenGraph{
+			Title:       url.OGTitle,
+			Description: url.OGDescription,
+			Image:       url.OGImage,
+		},
+	}
+	if card.Title == "" {
+		card.Title = url.OriginalURL
+	}
+	return card, nil
+}
+
+// PreviewURL describes where a short link leads without redirecting or
+// counting a click. Links that would not redirect return the same errors as
+// ResolveURL, so a preview never reveals a destination before launch or
+// after the link was blocked.
+func (s *URLService) PreviewURL(ctx context.Context, host, shortCode string) (*domain.Preview, error) {
+	url, err := s.lookupURL(ctx, s.domainName(host), shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRedirects(url); err != nil {
+		return nil, err
+	}
+
+	safety := url.SafetyStatus
+	if !utils.IsValidURL(url.OriginalURL) {
+		safety = domain.SafetyUnsafe
+	} else if safety == "" {
+		safety = domain.SafetyUnchecked
+	}
+
+	return &domain.Preview{
+		ShortURL:     s.shortURL(url),
+		ShortCode:    url.ShortCode,
+		OriginalURL:  url.OriginalURL,
+		CreatedAt:    url.CreatedAt,
+		ExpiresAt:    url.ExpiresAt,
+		ActivatesAt:  url.ActivatesAt,
+		Safety:       safety,
+		Interstitial: url.Interstitial,
+		ForwardQuery: url.ForwardQuery,
+	}, nil
+}
+
+// checkRedirects returns the error ResolveURL gives for a link that does not
+// redirect to its destination: flagged, disabled or not launched yet
+func (s *URLService) checkRedirects(url *domain.URL) error {
+	switch {
+	case url.SafetyStatus == domain.SafetyUnsafe:
+		return ErrUnsafeURL
+	case url.Disabled:
+		return ErrURLDisabled
+	case !s.isActive(url):
+		return ErrURLNotActive
+	}
+	return nil
+}
+
+// varies reports whether a link's destination depends on who follows it or
+// how. Such redirects are never cached publicly: a cached one would pin
+// browsers to one variant and hide their clicks, and a shared cache would
+// send every visitor where the first one's country, client or query led.
+func varies(url *domain.URL) bool {
+	return len(url.Variants) > 0 || len(url.GeoRules) > 0 || len(url.DeviceRules) > 0 || url.ForwardQuery
+}
+
+// destination fills in the redirect's location. Device rules win over geo
+// rules, which win over A/B variants, which win over the original URL; the
+// passthrough options are applied to whichever target was chosen.
+func (s *URLService) destination(redirect *domain.Redirect, url *domain.URL, visit *domain.Visit, country string) error {
+	redirect.Location = url.OriginalURL
+	var confirmed string
+	if url.Interstitial && visit != nil && visit.Confirmed {
+		confirmed = s.confirmedVariant(url, visit.Variant)
+	}
+	if variant, ok := pickVariant(redirect, url, visit, confirmed); ok {
+		redirect.Location, redirect.Variant = variant.URL, variant.Name
+	}
+	if visit == nil {
+		return nil
+	}
+
+	if target, ok := url.GeoRules.Match(country); ok {
+		redirect.Location, redirect.Variant = target, ""
+	}
+	if len(url.DeviceRules) > 0 {
+		client := utils.ParseUserAgent(visit.UserAgent)
+		if target, ok := url.DeviceRules.Match(client.OS, client.Device, client.Browser); ok {
+			redirect.Location, redirect.Variant = target, ""
+		}
+	}
+
+	var err error
+	if url.ForwardPath && visit.Path != "" {
+		if redirect.Location, err = utils.AppendPath(redirect.Location, visit.Path); err != nil {
+			return err
+		}
+	}
+	if url.ForwardQuery && visit.RawQuery != "" {
+		// Keep whatever parsed; a malformed pair should not break the redirect
+		params, _ := neturl.ParseQuery(visit.RawQuery)
+		if redirect.Location, err = utils.AppendQuery(redirect.Location, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pickVariant chooses the A/B variant for a visit, or the confirmed one the
+// interstitial showed. Sticky links hash the visitor ID together with the
+// link's domain and short code; without a visitor cookie the ID is derived from the IP address
+// and User-Agent and handed back on the redirect so the client can keep it.
+func pickVariant(redirect *domain.Redirect, url *domain.URL, visit *domain.Visit, confirmed string) (domain.Variant, bool) {
+	if len(url.Variants) == 0 {
+		return domain.Variant{}, false
+	}
+	// Continuing past the interstitial goes where its page said
+	if confirmed != "" {
+		for _, variant := range url.Variants {
+			if variant.Name == confirmed {
+				return variant, true
+			}
+		}
+	}
+	if url.VariantMode != domain.VariantModeSticky || visit == nil {
+		return url.Variants.Pick(rand.Uint64())
+	}
+
+	visitorID := visit.VisitorID
+	if visitorID == "" {
+		sum := sha256.Sum256([]byte(visit.IPAddress + "|" + visit.UserAgent))
+		visitorID = hex.EncodeToString(sum[:16])
+		redirect.VisitorID = visitorID
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(url.Key() + ":" + visitorID))
+	return url.Variants.Pick(hash.Sum64())
+}
+
+// variantToken signs a variant of url for the interstitial's continue link.
+// Without a JWT secret to sign with no token is issued, and continuing picks
+// a variant again.
+func (s *URLService) variantToken(url *domain.URL, name string) string {
+	if s.cfg == nil || s.cfg.JWTSecret() == "" {
+		return ""
+	}
+	return name + "." + variantSignature(s.cfg.JWTSecret(), url, name)
+}
+
+// confirmedVariant returns the variant named by a token from variantToken,
+// or "" when the token was not signed for url
+func (s *URLService) confirmedVariant(url *domain.URL, token string) string {
+	if s.cfg == nil || s.cfg.JWTSecret() == "" {
+		return ""
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return ""
+	}
+	name, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(variantSignature(s.cfg.JWTSecret(), url, name))) {
+		return ""
+	}
+	return name
+}
+
+func variantSignature(secret string, url *domain.URL, name string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(url.Key() + "\x00" + name))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// lookupURL fetches an unexpired link from the cache, falling back to the database
+func (s *URLService) lookupURL(ctx context.Context, host, shortCode string) (*domain.URL, error) {
+	if s.filter != nil && !s.filter.MayExist(ctx, domain.LinkKey(host, shortCode)) {
+		return nil, ErrURLNotFound
+	}
+
 	// Try cache first
-	cacheKey := fmt.Sprintf("url:%s", shortCode)
+	cacheKey := fmt.Sprintf("url:%s", domain.LinkKey(host, shortCode))
 	var cachedURL domain.URL
 	if err := s.cacheRepo.Get(ctx, cacheKey, &cachedURL); err == nil {
 		if s.isExpired(&cachedURL) {
-			return "", ErrURLExpired
+			return nil, ErrURLExpired
 		}
+		return &cachedURL, nil
+	}
 
-		// Increment click count asynchronously
-		go s.incrementClickCount(context.Background(), shortCode)
+	// Concurrent misses for one link share a single database query, which
+	// outlives any one caller giving up
+	flight := domain.LinkKey(host, shortCode)
+	if workspaceID, ok := domain.WorkspaceFromContext(ctx); ok {
+		flight = fmt.Sprintf("ws:%d:%s", workspaceID, flight)
+	}
+	shared, err, _ := s.lookups.Do(flight, func() (interface{}, error) {
+		return s.loadURL(context.WithoutCancel(ctx), host, shortCode)
+	})
+	if err != nil {
+		return nil, err
+	}
+	url := *shared.(*domain.URL) // Each caller gets its own copy
+	return &url, nil
+}
 
-		return cachedURL.OriginalURL, nil
+// loadURL reads a link from the database into the cache. Short codes that
+// do not exist are remembered for cache.negative_ttl, so probing random
+// codes does not reach the database every time.
+func (s *URLService) loadURL(ctx context.Context, host, shortCode string) (*domain.URL, error) {
+	missingKey := fmt.Sprintf("nurl:%s", domain.LinkKey(host, shortCode))
+	var missing bool
+	if s.negativeTTL() > 0 && s.cacheRepo.Get(ctx, missingKey, &missing) == nil {
+		return nil, ErrURLNotFound
 	}
 
-	// Fallback to database
-	url, err := s.urlRepo.GetURLByShortCode(ctx, shortCode)
+	url, err := s.urlRepo.GetURLByShortCode(ctx, host, shortCode)
+	if errors.Is(err, domain.ErrNotFound) && s.negativeTTL() > 0 {
+		url, err = s.rememberMissing(ctx, host, shortCode, missingKey)
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrURLNotFound
+	}
 	if err != nil {
-		return "", ErrURLNotFound
+		return nil, fmt.Errorf("failed to get URL: %w", err)
 	}
 
 	if s.isExpired(url) {
-		return "", ErrURLExpired
+		return nil, ErrURLExpired
 	}
 
 	// Cache for future requests
-	if err := s.cacheRepo.Set(ctx, cacheKey, url, time.Hour); err != nil {
+	if err := s.cacheRepo.Set(domain.AsCacheFill(ctx), fmt.Sprintf("url:%s", url.Key()), url, time.Hour); err != nil {
 		s.logger.Warn("Failed to cache URL", zap.Error(err))
 	}
 
-	// Increment click count asynchronously
-	go s.incrementClickCount(context.Background(), shortCode)
+	return url, nil
+}
+
+// rememberMissing caches that a short code does not exist, then reads it
+// from the database again: a link created with the code meanwhile may have
+// forgotten it before the entry was written, and would stay hidden for the
+// negative TTL. The entry is dropped unless the code is still missing.
+func (s *URLService) rememberMissing(ctx context.Context, host, shortCode, missingKey string) (*domain.URL, error) {
+	if err := s.cacheRepo.Set(ctx, missingKey, true, s.negativeTTL()); err != nil {
+		s.logger.Warn("Failed to cache missing URL", zap.Error(err))
+		return nil, domain.ErrNotFound
+	}
+	url, err := s.urlRepo.GetURLByShortCode(ctx, host, shortCode)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if err := s.cacheRepo.Delete(ctx, missingKey); err != nil {
+		s.logger.Warn("Failed to evict missing URL", zap.String("key", missingKey), zap.Error(err))
+	}
+	return url, err
+}
 
-	return url.OriginalURL, nil
+// forgetMissing drops the negative cache entries of a new link's short
+// code, in the shared partition used by redirects and in its workspace's
+func (s *URLService) forgetMissing(ctx context.Context, url *domain.URL) {
+	if s.negativeTTL() == 0 {
+		return
+	}
+	key := fmt.Sprintf("nurl:%s", url.Key())
+	for _, scope := range []context.Context{domain.WithoutWorkspace(ctx), domain.WithWorkspace(ctx, url.WorkspaceID)} {
+		if err := s.cacheRepo.Delete(scope, key); err != nil {
+			s.logger.Warn("Failed to evict missing URL", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// lookupCountry resolves the visitor's country, or "" when it is unknown
+func (s *URLService) lookupCountry(visit *domain.Visit) string {
+	if s.geo == nil || visit == nil || visit.IPAddress == "" {
+		return ""
+	}
+	country, err := s.geo.Country(visit.IPAddress)
+	if err != nil {
+		s.logger.Debug("Failed to look up country", zap.String("ip", visit.IPAddress), zap.Error(err))
+		return ""
+	}
+	return country
 }
 
-func (s *URLService) incrementClickCount(ctx context.Context, shortCode string) {
+func newClick(url *domain.URL, visit *domain.Visit, country string) *domain.URLAnalytics {
+	click := &domain.URLAnalytics{
+		Domain:    url.Domain,
+		ShortCode: url.ShortCode,
+		ClickedAt: time.Now().UTC(),
+		Country:   country,
+	}
+	if visit != nil {
+		click.IPAddress = visit.IPAddress
+		click.UserAgent = visit.UserAgent
+		click.Referer = visit.Referer
+	}
+	return click
+}
+
+func (s *URLService) recordClick(ctx context.Context, click *domain.URLAnalytics) {
+	if err := s.analyticsRepo.RecordClick(ctx, click); err != nil {
+		s.logger.Error("Failed to record click",
+			zap.String("short_code", click.ShortCode),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *URLService) incrementClickCount(ctx context.Context, url *domain.URL) {
 	// Try to increment in cache first
-	cacheKey := fmt.Sprintf("clicks:%s", shortCode)
+	cacheKey := fmt.Sprintf("clicks:%s", url.Key())
 	if err := s.cacheRepo.Increment(ctx, cacheKey, 1); err == nil {
 		return
 	}
 
 	// Fallback to database
-	if err := s.urlRepo.UpdateClickCount(ctx, shortCode); err != nil {
+	if err := s.urlRepo.UpdateClickCount(ctx, url.Domain, url.ShortCode); err != nil {
 		s.logger.Error("Failed to increment click count",
-			zap.String("short_code", shortCode),
+			zap.String("short_code", url.Key()),
 			zap.Error(err),
 		)
 	}
@@ -173,12 +1083,81 @@ func (s *URLService) isExpired(url *domain.URL) bool {
 	return time.Now().After(*url.ExpiresAt)
 }
 
+// redirectStatus returns the link's redirect status, falling back to the configured default
+func (s *URLService) redirectStatus(url *domain.URL) int {
+	if url.RedirectStatus != 0 {
+		return url.RedirectStatus
+	}
+	if s.cfg != nil && s.cfg.Redirect.DefaultStatus != 0 {
+		return s.cfg.Redirect.DefaultStatus
+	}
+	return http.StatusMovedPermanently
+}
+
+// negativeTTL is how long a short code that does not exist is remembered
+func (s *URLService) negativeTTL() time.Duration {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.Cache.NegativeTTL
+}
+
+func (s *URLService) permanentMaxAge() time.Duration {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.Redirect.PermanentMaxAge
+}
+
+// isActive reports whether the link's activation time has been reached
+func (s *URLService) isActive(url *domain.URL) bool {
+	if url.ActivatesAt == nil {
+		return true
+	}
+	return !time.Now().Before(*url.ActivatesAt)
+}
+
+// shortURL is the public address of a link: its branded domain, served
+// over the base URL's scheme, or the base URL itself
+func (s *URLService) shortURL(url *domain.URL) string {
+	if url.Domain != "" {
+		scheme := "https"
+		if base, err := neturl.Parse(s.baseURL()); err == nil && base.Scheme != "" {
+			scheme = base.Scheme
+		}
+		return scheme + "://" + url.Domain + "/" + url.ShortCode
+	}
+	return s.baseURL() + "/" + url.ShortCode
+}
+
+func (s *URLService) baseURL() string {
+	if s.cfg == nil {
+		return ""
+	}
+	return s.cfg.BaseURL()
+}
+
 func (s *URLService) buildResponse(url *domain.URL) *domain.ShortenResponse {
 	return &domain.ShortenResponse{
-		ShortURL:    s.cfg.BaseURL() + "/" + url.ShortCode,
+		ShortURL:    s.shortURL(url),
 		ShortCode:   url.ShortCode,
+		Domain:      url.Domain,
 		OriginalURL: url.OriginalURL,
 		ExpiresAt:   url.ExpiresAt,
 		CreatedAt:   url.CreatedAt,
+
+		ActivatesAt:    url.ActivatesAt,
+		ComingSoonURL:  url.ComingSoonURL,
+		RedirectStatus: url.RedirectStatus,
+		ForwardQuery:   url.ForwardQuery,
+		ForwardPath:    url.ForwardPath,
+		GeoRules:       url.GeoRules,
+		DeviceRules:    url.DeviceRules,
+		Variants:       url.Variants,
+		VariantMode:    url.VariantMode,
+		Interstitial:   url.Interstitial,
+		Disabled:       url.Disabled,
+		Health:         url.Health(),
+		OpenGraph:      url.OpenGraph(),
 	}
 }
@@ -3,13 +3,19 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/memory"
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
 
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
@@ -21,6 +27,7 @@ func TestURLService_ShortenURL(t *testing.T) {
 		// Create fresh mocks for each test
 		mockRepo := new(mocks.MockURLRepository)
 		mockCache := new(mocks.MockCacheRepository)
+		mockLinks := new(mocks.MockLinkPublisher)
 		logger := zaptest.NewLogger(t)
 		urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{
 			Server: config.ServerConfig{
@@ -29,7 +36,7 @@ func TestURLService_ShortenURL(t *testing.T) {
 			Snowflake: config.SnowflakeConfig{
 				MachineID: 1,
 			},
-		})
+		}, WithLinkPublisher(mockLinks))
 
 		req := &domain.ShortenRequest{
 			URL: "https://example.com",
@@ -40,21 +47,28 @@ func TestURLService_ShortenURL(t *testing.T) {
 			Return(errors.New("not found"))
 
 		// Mock database lookup for existing URL (not found)
-		mockRepo.On("GetURLByOriginalURL", mock.Anything, "https://example.com").
-			Return(nil, errors.New("not found"))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").
+			Return(nil, domain.ErrNotFound)
 
 		// Mock URL creation
 		mockRepo.On("CreateURL", mock.Anything, mock.AnythingOfType("*domain.URL")).
 			Return(nil)
 
-		// Mock both cache Set calls (the service makes two Set calls)
-		mockCache.On("Set", mock.Anything, mock.MatchedBy(func(key string) bool {
+		// Mock both cache Set calls (the service makes two Set calls), which
+		// announce the new link rather than fill the cache
+		created := mock.MatchedBy(func(ctx context.Context) bool { return !domain.IsCacheFill(ctx) })
+		mockCache.On("Set", created, mock.MatchedBy(func(key string) bool {
 			return key != "lurl:https://example.com" // This matches the url:shortcode pattern
 		}), mock.Anything, time.Hour).Return(nil)
 
-		mockCache.On("Set", mock.Anything, "lurl:https://example.com", mock.Anything, time.Hour).
+		mockCache.On("Set", created, "lurl:https://example.com", mock.Anything, time.Hour).
 			Return(nil)
 
+		// Other instances hear about the new link
+		mockLinks.On("PublishLinkChange", mock.Anything, mock.MatchedBy(func(change *domain.LinkChange) bool {
+			return change.ShortCode != "" && change.OriginalURL == "https://example.com"
+		})).Return(nil)
+
 		response, err := urlService.ShortenURL(context.Background(), req)
 
 		assert.NoError(t, err)
@@ -63,6 +77,7 @@ func TestURLService_ShortenURL(t *testing.T) {
 		assert.Contains(t, response.ShortURL, response.ShortCode)
 		mockRepo.AssertExpectations(t)
 		mockCache.AssertExpectations(t)
+		mockLinks.AssertExpectations(t)
 	})
 
 	t.Run("InvalidURL", func(t *testing.T) {
@@ -113,17 +128,16 @@ func TestURLService_ShortenURL(t *testing.T) {
 			Return(errors.New("not found"))
 
 		// Mock database lookup for existing URL (not found)
-		mockRepo.On("GetURLByOriginalURL", mock.Anything, "https://example.com").
-			Return(nil, errors.New("not found"))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").
+			Return(nil, domain.ErrNotFound)
 
-		// Mock custom alias check - this should find the existing alias
-		mockRepo.On("IsShortCodeExists", mock.Anything, "taken").
-			Return(true, nil)
+		// The insert finds the alias in use
+		mockRepo.On("CreateURL", mock.Anything, mock.MatchedBy(func(url *domain.URL) bool { return url.ShortCode == "taken" })).
+			Return(fmt.Errorf("failed to create URL: %w", domain.ErrConflict)).Once()
 
 		response, err := urlService.ShortenURL(context.Background(), req)
 
-		assert.Error(t, err)
-		assert.EqualError(t, err, "custom alias already exists") // Updated this line
+		assert.Equal(t, ErrCustomAliasTaken, err)
 		assert.Nil(t, response)
 		mockRepo.AssertExpectations(t)
 		mockCache.AssertExpectations(t)
@@ -198,7 +212,7 @@ func TestURLService_ShortenURL(t *testing.T) {
 			Return(errors.New("not found"))
 
 		// Database hit
-		mockRepo.On("GetURLByOriginalURL", mock.Anything, "https://db-example.com").
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://db-example.com").
 			Return(existingURL, nil)
 
 		// Cache the found URL
@@ -216,6 +230,73 @@ func TestURLService_ShortenURL(t *testing.T) {
 	})
 }
 
+func TestURLService_ShortenURL_ShortCodeConflicts(t *testing.T) {
+	cfg := &config.Config{
+		Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+	}
+	conflict := fmt.Errorf("failed to insert URL: %w", domain.ErrConflict)
+
+	t.Run("AliasTakenConcurrently", func(t *testing.T) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), cfg)
+
+		mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("key %w", domain.ErrNotFound))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").Return(nil, domain.ErrNotFound)
+		// Taken by another request; the insert is what finds out
+		mockRepo.On("CreateURL", mock.Anything, mock.AnythingOfType("*domain.URL")).Return(conflict).Once()
+
+		response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{URL: "https://example.com", CustomAlias: "summer"})
+
+		assert.Equal(t, ErrCustomAliasTaken, err)
+		assert.Nil(t, response)
+		mockRepo.AssertNumberOfCalls(t, "CreateURL", 1)
+		mockCache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("GeneratedCodeRetried", func(t *testing.T) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), cfg)
+
+		var codes []string
+		mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("key %w", domain.ErrNotFound))
+		mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, time.Hour).Return(nil)
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").Return(nil, domain.ErrNotFound)
+		mockRepo.On("CreateURL", mock.Anything, mock.AnythingOfType("*domain.URL")).
+			Run(func(args mock.Arguments) { codes = append(codes, args.Get(1).(*domain.URL).ShortCode) }).
+			Return(conflict).Once()
+		mockRepo.On("CreateURL", mock.Anything, mock.AnythingOfType("*domain.URL")).
+			Run(func(args mock.Arguments) { codes = append(codes, args.Get(1).(*domain.URL).ShortCode) }).
+			Return(nil).Once()
+
+		response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{URL: "https://example.com"})
+
+		require.NoError(t, err)
+		require.Len(t, codes, 2)
+		assert.NotEqual(t, codes[0], codes[1], "a taken code is replaced")
+		assert.Equal(t, codes[1], response.ShortCode)
+		mockRepo.AssertNotCalled(t, "IsShortCodeExists", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("GeneratedCodeGivesUp", func(t *testing.T) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), cfg)
+
+		mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("key %w", domain.ErrNotFound))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").Return(nil, domain.ErrNotFound)
+		mockRepo.On("CreateURL", mock.Anything, mock.AnythingOfType("*domain.URL")).Return(conflict)
+
+		_, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{URL: "https://example.com"})
+
+		assert.ErrorIs(t, err, ErrCodesExhausted)
+		assert.NotErrorIs(t, err, domain.ErrConflict, "not reported as a taken alias")
+		mockRepo.AssertNumberOfCalls(t, "CreateURL", maxShortCodeAttempts)
+	})
+}
+
 func TestURLService_GetOriginalURL(t *testing.T) {
 	t.Run("CacheHit", func(t *testing.T) {
 		mockRepo := new(mocks.MockURLRepository)
@@ -264,15 +345,16 @@ func TestURLService_GetOriginalURL(t *testing.T) {
 
 		mockCache.On("Get", mock.Anything, "url:def456", mock.AnythingOfType("*domain.URL")).
 			Return(errors.New("not found"))
-		mockRepo.On("GetURLByShortCode", mock.Anything, "def456").
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "def456").
 			Return(dbURL, nil)
-		mockCache.On("Set", mock.Anything, "url:def456", dbURL, time.Hour).
+		// Filling the cache from the database is not announced as a change
+		mockCache.On("Set", mock.MatchedBy(domain.IsCacheFill), "url:def456", dbURL, time.Hour).
 			Return(nil)
 
 		// Mock the increment call - first try cache, then fallback to database
 		mockCache.On("Increment", mock.Anything, "clicks:def456", int64(1)).
 			Return(errors.New("cache error"))
-		mockRepo.On("UpdateClickCount", mock.Anything, "def456").Return(nil)
+		mockRepo.On("UpdateClickCount", mock.Anything, "", "def456").Return(nil)
 
 		originalURL, err := urlService.GetOriginalURL(context.Background(), "def456")
 
@@ -293,8 +375,8 @@ func TestURLService_GetOriginalURL(t *testing.T) {
 
 		mockCache.On("Get", mock.Anything, "url:notfound", mock.AnythingOfType("*domain.URL")).
 			Return(errors.New("not found"))
-		mockRepo.On("GetURLByShortCode", mock.Anything, "notfound").
-			Return(nil, errors.New("not found"))
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "notfound").
+			Return(nil, domain.ErrNotFound)
 
 		originalURL, err := urlService.GetOriginalURL(context.Background(), "notfound")
 
@@ -333,4 +415,1221 @@ func TestURLService_GetOriginalURL(t *testing.T) {
 		assert.Empty(t, originalURL)
 		mockCache.AssertExpectations(t)
 	})
+
+	t.Run("NotYetActiveWithFallback", func(t *testing.T) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		logger := zaptest.NewLogger(t)
+		urlService := NewURLService(mockRepo, mockCache, logger, nil)
+
+		activatesAt := time.Now().Add(time.Hour)
+		scheduledURL := &domain.URL{
+			ShortCode:     "launch",
+			OriginalURL:   "https://example.com/campaign",
+			CreatedAt:     time.Now(),
+			ActivatesAt:   &activatesAt,
+			ComingSoonURL: "https://example.com/coming-soon",
+		}
+
+		mockCache.On("Get", mock.Anything, "url:launch", mock.AnythingOfType("*domain.URL")).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *scheduledURL
+			}).
+			Return(nil)
+
+		originalURL, err := urlService.GetOriginalURL(context.Background(), "launch")
+
+		assert.Equal(t, ErrURLNotActive, err)
+		assert.Equal(t, "https://example.com/coming-soon", originalURL)
+		mockCache.AssertExpectations(t)
+		mockCache.AssertNotCalled(t, "Increment", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("NotYetActiveFromDatabase", func(t *testing.T) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		logger := zaptest.NewLogger(t)
+		urlService := NewURLService(mockRepo, mockCache, logger, nil)
+
+		activatesAt := time.Now().Add(time.Hour)
+		scheduledURL := &domain.URL{
+			ShortCode:   "launch",
+			OriginalURL: "https://example.com/campaign",
+			CreatedAt:   time.Now(),
+			ActivatesAt: &activatesAt,
+		}
+
+		mockCache.On("Get", mock.Anything, "url:launch", mock.AnythingOfType("*domain.URL")).
+			Return(errors.New("not found"))
+		mockRepo.On("GetURLByShortCode", mock.Anything, "", "launch").
+			Return(scheduledURL, nil)
+		mockCache.On("Set", mock.Anything, "url:launch", scheduledURL, time.Hour).
+			Return(nil)
+
+		originalURL, err := urlService.GetOriginalURL(context.Background(), "launch")
+
+		assert.Equal(t, ErrURLNotActive, err)
+		assert.Empty(t, originalURL)
+		mockCache.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ActivatedURL", func(t *testing.T) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		logger := zaptest.NewLogger(t)
+		urlService := NewURLService(mockRepo, mockCache, logger, nil)
+
+		activatesAt := time.Now().Add(-time.Minute)
+		activeURL := &domain.URL{
+			ShortCode:     "live",
+			OriginalURL:   "https://example.com/campaign",
+			CreatedAt:     time.Now().Add(-time.Hour),
+			ActivatesAt:   &activatesAt,
+			ComingSoonURL: "https://example.com/coming-soon",
+		}
+
+		mockCache.On("Get", mock.Anything, "url:live", mock.AnythingOfType("*domain.URL")).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *activeURL
+			}).
+			Return(nil)
+		mockCache.On("Increment", mock.Anything, "clicks:live", int64(1)).
+			Return(nil)
+
+		originalURL, err := urlService.GetOriginalURL(context.Background(), "live")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/campaign", originalURL)
+
+		// Wait a bit for the goroutine to complete
+		time.Sleep(100 * time.Millisecond)
+		mockCache.AssertExpectations(t)
+	})
+}
+
+func TestURLService_ShortenURL_InvalidSchedule(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+	urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{
+		Server: config.ServerConfig{
+			BaseURL: "http://localhost:8080",
+		},
+	})
+
+	activatesAt := time.Now().Add(2 * time.Hour)
+	expiresAt := time.Now().Add(time.Hour)
+	req := &domain.ShortenRequest{
+		URL:         "https://example.com",
+		ActivatesAt: &activatesAt,
+		ExpiresAt:   &expiresAt,
+	}
+
+	response, err := urlService.ShortenURL(context.Background(), req)
+
+	assert.Equal(t, ErrInvalidSchedule, err)
+	assert.Nil(t, response)
+}
+
+func TestURLService_ResolveURL_RedirectStatus(t *testing.T) {
+	cfg := &config.Config{
+		Redirect: config.RedirectConfig{
+			DefaultStatus:   http.StatusFound,
+			PermanentMaxAge: time.Hour,
+		},
+	}
+
+	cases := []struct {
+		name       string
+		linkStatus int
+		want       int
+		maxAge     time.Duration
+		configure  func(url *domain.URL)
+	}{
+		{"ConfiguredDefault", 0, http.StatusFound, time.Hour, nil},
+		{"PerLinkPermanent", http.StatusPermanentRedirect, http.StatusPermanentRedirect, time.Hour, nil},
+		{"GeoRulesUncached", http.StatusPermanentRedirect, http.StatusPermanentRedirect, 0, func(url *domain.URL) {
+			url.GeoRules = domain.GeoRules{{Country: "DE", URL: "https://example.de"}}
+		}},
+		{"DeviceRulesUncached", http.StatusPermanentRedirect, http.StatusPermanentRedirect, 0, func(url *domain.URL) {
+			url.DeviceRules = domain.DeviceRules{{OS: "ios", URL: "https://apps.apple.com/app/id1"}}
+		}},
+		{"ForwardQueryUncached", http.StatusPermanentRedirect, http.StatusPermanentRedirect, 0, func(url *domain.URL) {
+			url.ForwardQuery = true
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockURLRepository)
+			mockCache := new(mocks.MockCacheRepository)
+			logger := zaptest.NewLogger(t)
+			urlService := NewURLService(mockRepo, mockCache, logger, cfg)
+
+			cachedURL := &domain.URL{
+				ShortCode:      "status",
+				OriginalURL:    "https://example.com",
+				CreatedAt:      time.Now(),
+				RedirectStatus: tc.linkStatus,
+			}
+			if tc.configure != nil {
+				tc.configure(cachedURL)
+			}
+
+			mockCache.On("Get", mock.Anything, "url:status", mock.AnythingOfType("*domain.URL")).
+				Run(func(args mock.Arguments) {
+					arg := args.Get(2).(*domain.URL)
+					*arg = *cachedURL
+				}).
+				Return(nil)
+			mockCache.On("Increment", mock.Anything, "clicks:status", int64(1)).
+				Return(nil)
+
+			redirect, err := urlService.ResolveURL(context.Background(), "status", nil)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, redirect.StatusCode)
+			assert.Equal(t, tc.maxAge, redirect.MaxAge)
+
+			// Wait a bit for the goroutine to complete
+			time.Sleep(100 * time.Millisecond)
+		})
+	}
+}
+
+func TestURLService_ShortenURL_InvalidRedirectStatus(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+	urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{})
+
+	response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+		URL:            "https://example.com",
+		RedirectStatus: http.StatusOK,
+	})
+
+	assert.Equal(t, ErrInvalidRedirectStatus, err)
+	assert.Nil(t, response)
+}
+
+func TestURLService_ResolveURL_Passthrough(t *testing.T) {
+	cases := []struct {
+		name    string
+		link    domain.URL
+		visit   *domain.Visit
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "QueryMerged",
+			link:  domain.URL{OriginalURL: "https://example.com/landing?ref=short", ForwardQuery: true},
+			visit: &domain.Visit{RawQuery: "utm_source=x&ref=other"},
+			want:  "https://example.com/landing?ref=short&utm_source=x",
+		},
+		{
+			name:  "QueryDroppedWhenDisabled",
+			link:  domain.URL{OriginalURL: "https://example.com/landing"},
+			visit: &domain.Visit{RawQuery: "utm_source=x"},
+			want:  "https://example.com/landing",
+		},
+		{
+			name:  "PathForwarded",
+			link:  domain.URL{OriginalURL: "https://example.com/docs", ForwardPath: true},
+			visit: &domain.Visit{Path: "/extra/path"},
+			want:  "https://example.com/docs/extra/path",
+		},
+		{
+			name:    "PathRejectedWhenDisabled",
+			link:    domain.URL{OriginalURL: "https://example.com/docs"},
+			visit:   &domain.Visit{Path: "/extra/path"},
+			wantErr: ErrURLNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockURLRepository)
+			mockCache := new(mocks.MockCacheRepository)
+			logger := zaptest.NewLogger(t)
+			urlService := NewURLService(mockRepo, mockCache, logger, nil)
+
+			link := tc.link
+			link.ShortCode = "pass"
+			mockCache.On("Get", mock.Anything, "url:pass", mock.AnythingOfType("*domain.URL")).
+				Run(func(args mock.Arguments) {
+					arg := args.Get(2).(*domain.URL)
+					*arg = link
+				}).
+				Return(nil)
+			mockCache.On("Increment", mock.Anything, "clicks:pass", int64(1)).
+				Return(nil)
+
+			redirect, err := urlService.ResolveURL(context.Background(), "pass", tc.visit)
+
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				assert.Nil(t, redirect)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, redirect.Location)
+
+			// Wait a bit for the goroutine to complete
+			time.Sleep(100 * time.Millisecond)
+		})
+	}
+}
+
+func TestURLService_ShortenURL_UTMBuilder(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+	urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{
+		Server: config.ServerConfig{
+			BaseURL: "http://localhost:8080",
+		},
+		Snowflake: config.SnowflakeConfig{
+			MachineID: 1,
+		},
+	})
+
+	composed := "https://example.com/sale?lang=en&ref=x&utm_campaign=spring+sale&utm_medium=email&utm_source=newsletter"
+	existingURL := &domain.URL{
+		ShortCode:   "utm123",
+		OriginalURL: composed,
+		CreatedAt:   time.Now(),
+	}
+
+	// Deduplication is keyed on the composed URL, not the raw request URL
+	mockCache.On("Get", mock.Anything, "lurl:"+composed, mock.AnythingOfType("*domain.URL")).
+		Return(errors.New("not found"))
+	mockRepo.On("GetURLByOriginalURL", mock.Anything, "", composed).
+		Return(existingURL, nil)
+	mockCache.On("Set", mock.Anything, "lurl:"+composed, existingURL, time.Hour).
+		Return(nil)
+
+	response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+		URL: "https://example.com/sale?ref=x&utm_source=old",
+		UTM: &domain.UTMParams{
+			Source:   "newsletter",
+			Medium:   "email",
+			Campaign: "spring sale",
+		},
+		QueryParams: map[string]string{"lang": "en"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "utm123", response.ShortCode)
+	assert.Equal(t, composed, response.OriginalURL)
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestURLService_ResolveURL_GeoRules(t *testing.T) {
+	cases := []struct {
+		name    string
+		ip      string
+		country string
+		want    string
+	}{
+		{"MatchingCountry", "81.2.69.160", "DE", "https://example.de"},
+		{"SecondRule", "81.2.69.161", "FR", "https://example.fr"},
+		{"FallbackToOriginal", "8.8.8.8", "US", "https://example.com"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockURLRepository)
+			mockCache := new(mocks.MockCacheRepository)
+			mockAnalytics := new(mocks.MockAnalyticsRepository)
+			mockGeo := new(mocks.MockGeoLocator)
+			logger := zaptest.NewLogger(t)
+			urlService := NewURLService(mockRepo, mockCache, logger, nil,
+				WithAnalyticsRepository(mockAnalytics),
+				WithGeoLocator(mockGeo),
+			)
+
+			cachedURL := &domain.URL{
+				ShortCode:   "geo",
+				OriginalURL: "https://example.com",
+				CreatedAt:   time.Now(),
+				GeoRules: domain.GeoRules{
+					{Country: "DE", URL: "https://example.de"},
+					{Country: "FR", URL: "https://example.fr"},
+				},
+			}
+
+			mockCache.On("Get", mock.Anything, "url:geo", mock.AnythingOfType("*domain.URL")).
+				Run(func(args mock.Arguments) {
+					arg := args.Get(2).(*domain.URL)
+					*arg = *cachedURL
+				}).
+				Return(nil)
+			mockCache.On("Increment", mock.Anything, "clicks:geo", int64(1)).
+				Return(nil)
+			mockGeo.On("Country", tc.ip).Return(tc.country, nil)
+
+			// The same lookup fills in the recorded click's country
+			mockAnalytics.On("RecordClick", mock.Anything, mock.MatchedBy(func(click *domain.URLAnalytics) bool {
+				return click.ShortCode == "geo" && click.Country == tc.country && click.IPAddress == tc.ip
+			})).Return(nil)
+
+			redirect, err := urlService.ResolveURL(context.Background(), "geo", &domain.Visit{IPAddress: tc.ip})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, redirect.Location)
+
+			// Wait a bit for the goroutines to complete
+			time.Sleep(100 * time.Millisecond)
+			mockGeo.AssertExpectations(t)
+			mockAnalytics.AssertExpectations(t)
+		})
+	}
+}
+
+func TestURLService_ShortenURL_InvalidGeoRule(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+	urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{})
+
+	response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+		URL:      "https://example.com",
+		GeoRules: []domain.GeoRule{{Country: "Germany", URL: "https://example.de"}},
+	})
+
+	assert.Equal(t, ErrInvalidGeoRule, err)
+	assert.Nil(t, response)
+}
+
+func TestURLService_ResolveURL_DeviceRules(t *testing.T) {
+	const (
+		iPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+		android = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36"
+		desktop = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	)
+
+	cases := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{"IOSToAppStore", iPhone, "https://apps.apple.com/app/id123"},
+		{"AndroidToDeepLink", android, "myapp://product/42"},
+		{"DesktopToWeb", desktop, "https://example.com/product/42"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockURLRepository)
+			mockCache := new(mocks.MockCacheRepository)
+			logger := zaptest.NewLogger(t)
+			urlService := NewURLService(mockRepo, mockCache, logger, nil)
+
+			cachedURL := &domain.URL{
+				ShortCode:   "app",
+				OriginalURL: "https://example.com/product/42",
+				CreatedAt:   time.Now(),
+				DeviceRules: domain.DeviceRules{
+					{OS: "ios", URL: "https://apps.apple.com/app/id123"},
+					{OS: "android", Device: "mobile", URL: "myapp://product/42"},
+				},
+			}
+
+			mockCache.On("Get", mock.Anything, "url:app", mock.AnythingOfType("*domain.URL")).
+				Run(func(args mock.Arguments) {
+					arg := args.Get(2).(*domain.URL)
+					*arg = *cachedURL
+				}).
+				Return(nil)
+			mockCache.On("Increment", mock.Anything, "clicks:app", int64(1)).
+				Return(nil)
+
+			redirect, err := urlService.ResolveURL(context.Background(), "app", &domain.Visit{UserAgent: tc.userAgent})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, redirect.Location)
+
+			// Wait a bit for the goroutine to complete
+			time.Sleep(100 * time.Millisecond)
+		})
+	}
+}
+
+func TestURLService_ShortenURL_InvalidDeviceRule(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+	urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{
+		Validation: config.ValidationConfig{DeepLinkSchemes: []string{"myapp"}},
+	})
+
+	for _, rule := range []domain.DeviceRule{
+		{URL: "myapp://home"},                       // matches nothing specific
+		{OS: "symbian", URL: "https://example.com"}, // unknown OS
+		{OS: "ios", URL: "javascript://alert(1)"},   // unsafe destination
+		{OS: "ios", URL: "otherapp://home"},         // app scheme not allowed
+	} {
+		response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+			URL:         "https://example.com",
+			DeviceRules: domain.DeviceRules{rule},
+		})
+
+		assert.Equal(t, ErrInvalidDeviceRule, err)
+		assert.Nil(t, response)
+	}
+}
+
+func TestURLService_ShortenURL_DeepLinks(t *testing.T) {
+	ctx := context.Background()
+	urlService := NewURLService(memory.NewStore(nil), memory.NewCache(), zaptest.NewLogger(t), &config.Config{
+		Server:     config.ServerConfig{BaseURL: "http://localhost:8080"},
+		Snowflake:  config.SnowflakeConfig{MachineID: 1},
+		Validation: config.ValidationConfig{DeepLinkSchemes: []string{"myapp"}},
+	})
+
+	_, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{
+		URL:         "https://example.com",
+		DeviceRules: domain.DeviceRules{{OS: "ios", URL: "myapp://product/42"}},
+	})
+	assert.NoError(t, err, "device rules may open allowed apps")
+
+	for _, req := range []*domain.ShortenRequest{
+		{URL: "myapp://product/42"},
+		{URL: "https://example.com", ComingSoonURL: "myapp://soon"},
+		{URL: "https://example.com", GeoRules: domain.GeoRules{{Country: "DE", URL: "myapp://de"}}},
+		{URL: "https://example.com", Variants: domain.Variants{{Name: "a", URL: "myapp://a", Weight: 1}}},
+	} {
+		_, err := urlService.ShortenURL(ctx, req)
+		assert.Error(t, err, "deep links are only allowed in device rules")
+	}
+}
+
+func TestURLService_ResolveURL_Variants(t *testing.T) {
+	split := &domain.URL{
+		ShortCode:   "split",
+		OriginalURL: "https://example.com",
+		CreatedAt:   time.Now(),
+		Variants: domain.Variants{
+			{Name: "a", URL: "https://a.example.com", Weight: 3},
+			{Name: "b", URL: "https://b.example.com", Weight: 1},
+		},
+	}
+
+	newService := func(t *testing.T, url *domain.URL) (*URLService, *mocks.MockAnalyticsRepository) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		mockAnalytics := new(mocks.MockAnalyticsRepository)
+		urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), nil,
+			WithAnalyticsRepository(mockAnalytics),
+		)
+
+		mockCache.On("Get", mock.Anything, "url:split", mock.AnythingOfType("*domain.URL")).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(2).(*domain.URL)
+				*arg = *url
+			}).
+			Return(nil)
+		mockCache.On("Increment", mock.Anything, "clicks:split", int64(1)).Return(nil)
+		return urlService, mockAnalytics
+	}
+
+	t.Run("StickyPerVisitor", func(t *testing.T) {
+		sticky := *split
+		sticky.VariantMode = domain.VariantModeSticky
+		urlService, mockAnalytics := newService(t, &sticky)
+		mockAnalytics.On("RecordClick", mock.Anything, mock.Anything).Return(nil)
+
+		visit := &domain.Visit{IPAddress: "203.0.113.7", UserAgent: "Mozilla/5.0"}
+		first, err := urlService.ResolveURL(context.Background(), "split", visit)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, first.Variant)
+		assert.NotEmpty(t, first.VisitorID) // Derived from IP and User-Agent, to be stored in a cookie
+		assert.Zero(t, first.MaxAge)
+
+		// The cookie keeps the assignment even when the network changes
+		for range 20 {
+			again, err := urlService.ResolveURL(context.Background(), "split", &domain.Visit{
+				IPAddress: "198.51.100.1",
+				VisitorID: first.VisitorID,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, first.Location, again.Location)
+			assert.Equal(t, first.Variant, again.Variant)
+			assert.Empty(t, again.VisitorID)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	t.Run("StickyPerDomain", func(t *testing.T) {
+		sticky := *split
+		sticky.VariantMode = domain.VariantModeSticky
+		branded := sticky
+		branded.Domain = "go.brand.com"
+
+		differ := 0
+		for i := range 50 {
+			visit := &domain.Visit{VisitorID: fmt.Sprintf("visitor-%d", i)}
+			a, _ := pickVariant(&domain.Redirect{}, &sticky, visit, "")
+			b, _ := pickVariant(&domain.Redirect{}, &branded, visit, "")
+			if a.Name != b.Name {
+				differ++
+			}
+		}
+		assert.Positive(t, differ, "the same code on another domain is a split of its own")
+	})
+
+	t.Run("RandomRespectsWeights", func(t *testing.T) {
+		urlService, mockAnalytics := newService(t, split)
+		mockAnalytics.On("RecordClick", mock.Anything, mock.MatchedBy(func(click *domain.URLAnalytics) bool {
+			return click.Variant == "a" || click.Variant == "b"
+		})).Return(nil)
+
+		served := map[string]int{}
+		for range 400 {
+			redirect, err := urlService.ResolveURL(context.Background(), "split", &domain.Visit{})
+			assert.NoError(t, err)
+			served[redirect.Variant]++
+			assert.Equal(t, "https://"+redirect.Variant+".example.com", redirect.Location)
+		}
+		assert.Greater(t, served["a"], served["b"])
+		assert.Positive(t, served["b"])
+
+		time.Sleep(100 * time.Millisecond)
+		mockAnalytics.AssertExpectations(t)
+	})
+
+	t.Run("RulesOverrideVariant", func(t *testing.T) {
+		routed := *split
+		routed.DeviceRules = domain.DeviceRules{{OS: "ios", URL: "myapp://home"}}
+		urlService, mockAnalytics := newService(t, &routed)
+		mockAnalytics.On("RecordClick", mock.Anything, mock.MatchedBy(func(click *domain.URLAnalytics) bool {
+			return click.Variant == ""
+		})).Return(nil)
+
+		redirect, err := urlService.ResolveURL(context.Background(), "split", &domain.Visit{
+			UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148 Safari/604.1",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "myapp://home", redirect.Location)
+		assert.Empty(t, redirect.Variant)
+
+		time.Sleep(100 * time.Millisecond)
+		mockAnalytics.AssertExpectations(t)
+	})
+}
+
+func TestURLService_ShortenURL_InvalidVariants(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+	urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{})
+
+	valid := domain.Variant{Name: "a", URL: "https://a.example.com", Weight: 1}
+	var heavy domain.Variants
+	for i := 0; i <= domain.MaxVariantTotalWeight/domain.MaxVariantWeight; i++ {
+		heavy = append(heavy, domain.Variant{Name: fmt.Sprintf("v%d", i), URL: "https://a.example.com", Weight: domain.MaxVariantWeight})
+	}
+	for _, req := range []domain.ShortenRequest{
+		{Variants: domain.Variants{{URL: "https://a.example.com", Weight: 1}}},            // missing name
+		{Variants: domain.Variants{valid, valid}},                                         // duplicate name
+		{Variants: domain.Variants{{Name: "a", URL: "https://a.example.com"}}},            // no weight
+		{Variants: domain.Variants{{Name: "a", URL: "javascript://alert(1)", Weight: 1}}}, // unsafe destination
+		{Variants: domain.Variants{valid}, VariantMode: "round-robin"},                    // unknown mode
+		{Variants: domain.Variants{{Name: "a", URL: "https://a.example.com", Weight: domain.MaxVariantWeight + 1}}},
+		{Variants: heavy}, // Each weight is allowed, their total is not
+	} {
+		req.URL = "https://example.com"
+		response, err := urlService.ShortenURL(context.Background(), &req)
+
+		assert.Equal(t, ErrInvalidVariants, err)
+		assert.Nil(t, response)
+	}
+}
+
+func TestURLService_PreviewURL(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+	urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{
+		Server: config.ServerConfig{BaseURL: "http://sho.rt"},
+	})
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	mockRepo.On("GetURLByShortCode", mock.Anything, "", "peek").Return(&domain.URL{
+		ShortCode:   "peek",
+		OriginalURL: "https://example.com",
+		CreatedAt:   time.Now(),
+		ExpiresAt:   &expiresAt,
+	}, nil)
+	mockCache.On("Get", mock.Anything, "url:peek", mock.Anything).Return(errors.New("cache miss"))
+	mockCache.On("Set", mock.Anything, "url:peek", mock.Anything, time.Hour).Return(nil)
+
+	preview, err := urlService.PreviewURL(context.Background(), "", "peek")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://sho.rt/peek", preview.ShortURL)
+	assert.Equal(t, &expiresAt, preview.ExpiresAt)
+	assert.Equal(t, domain.SafetyUnchecked, preview.Safety) // Never scanned
+
+	// Previews are not clicks
+	time.Sleep(100 * time.Millisecond)
+	mockCache.AssertNotCalled(t, "Increment", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "IncrementClickCount", mock.Anything, mock.Anything)
+}
+
+func TestURLService_PreviewURL_NotRedirecting(t *testing.T) {
+	launch := time.Now().Add(time.Hour)
+	cases := []struct {
+		name string
+		url  domain.URL
+		want error
+	}{
+		{"NotActive", domain.URL{ActivatesAt: &launch, ComingSoonURL: "https://example.com/soon"}, ErrURLNotActive},
+		{"Disabled", domain.URL{Disabled: true}, ErrURLDisabled},
+		{"Unsafe", domain.URL{SafetyStatus: domain.SafetyUnsafe}, ErrUnsafeURL},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCache := new(mocks.MockCacheRepository)
+			urlService := NewURLService(new(mocks.MockURLRepository), mockCache, zaptest.NewLogger(t), &config.Config{})
+			link := tc.url
+			link.ShortCode, link.OriginalURL, link.CreatedAt = "hidden", "https://example.com/secret", time.Now()
+			mockCache.On("Get", mock.Anything, "url:hidden", mock.Anything).
+				Run(func(args mock.Arguments) { *args.Get(2).(*domain.URL) = link }).
+				Return(nil)
+
+			preview, err := urlService.PreviewURL(context.Background(), "", "hidden")
+
+			assert.ErrorIs(t, err, tc.want)
+			assert.Nil(t, preview, "the destination is not revealed")
+		})
+	}
+}
+
+func TestURLService_ShortenURL_ReservedAliasSuffix(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	logger := zaptest.NewLogger(t)
+	urlService := NewURLService(mockRepo, mockCache, logger, &config.Config{})
+
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache miss"))
+	mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").Return(nil, domain.ErrNotFound)
+
+	response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+		URL:         "https://example.com",
+		CustomAlias: "promo+",
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, response)
+	mockRepo.AssertNotCalled(t, "IsShortCodeExists", mock.Anything, "", mock.Anything)
+}
+
+func TestURLService_ShortenURL_Scanning(t *testing.T) {
+	newService := func(t *testing.T) (*URLService, *mocks.MockURLRepository, *mocks.MockCacheRepository, *mocks.MockURLScanner) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		mockScanner := new(mocks.MockURLScanner)
+		urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), &config.Config{
+			Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+			Snowflake: config.SnowflakeConfig{MachineID: 1},
+		}, WithURLScanner(mockScanner))
+
+		mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", mock.Anything).Return(nil, domain.ErrNotFound)
+		return urlService, mockRepo, mockCache, mockScanner
+	}
+	clean := func(rawURL string) *domain.ScanResult { return &domain.ScanResult{URL: rawURL} }
+
+	t.Run("FlaggedVariantRejected", func(t *testing.T) {
+		urlService, mockRepo, _, mockScanner := newService(t)
+		mockScanner.On("Scan", mock.Anything, "https://example.com").Return(clean("https://example.com"), nil)
+		mockScanner.On("Scan", mock.Anything, "https://phish.example").Return(&domain.ScanResult{
+			URL:     "https://phish.example",
+			Threats: []domain.Threat{{Provider: "safe_browsing", Category: domain.ThreatPhishing}},
+		}, nil)
+
+		response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+			URL:      "https://example.com",
+			Variants: domain.Variants{{Name: "b", URL: "https://phish.example", Weight: 1}},
+		})
+
+		assert.Equal(t, ErrUnsafeURL, err)
+		assert.Nil(t, response)
+		mockRepo.AssertNotCalled(t, "CreateURL", mock.Anything, mock.Anything)
+	})
+
+	t.Run("CleanScanRecorded", func(t *testing.T) {
+		urlService, mockRepo, mockCache, mockScanner := newService(t)
+		mockScanner.On("Scan", mock.Anything, "https://example.com").Return(clean("https://example.com"), nil)
+		mockRepo.On("CreateURL", mock.Anything, mock.MatchedBy(func(url *domain.URL) bool {
+			return url.SafetyStatus == domain.SafetySafe && url.SafetyCheckedAt != nil
+		})).Return(nil)
+		mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, time.Hour).Return(nil)
+
+		_, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{URL: "https://example.com"})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("IncompleteScanLeftForRescan", func(t *testing.T) {
+		urlService, mockRepo, mockCache, mockScanner := newService(t)
+		mockScanner.On("Scan", mock.Anything, "https://example.com").
+			Return(clean("https://example.com"), errors.New("safe browsing unavailable"))
+		mockRepo.On("CreateURL", mock.Anything, mock.MatchedBy(func(url *domain.URL) bool {
+			return url.SafetyStatus == "" && url.SafetyCheckedAt == nil
+		})).Return(nil)
+		mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, time.Hour).Return(nil)
+
+		_, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{URL: "https://example.com"})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestURLService_ResolveURL_UnsafeBlocked(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), nil)
+
+	mockCache.On("Get", mock.Anything, "url:flagged", mock.AnythingOfType("*domain.URL")).
+		Run(func(args mock.Arguments) {
+			arg := args.Get(2).(*domain.URL)
+			*arg = domain.URL{ShortCode: "flagged", OriginalURL: "https://example.com", CreatedAt: time.Now(), SafetyStatus: domain.SafetyUnsafe}
+		}).
+		Return(nil)
+
+	redirect, err := urlService.ResolveURL(context.Background(), "flagged", &domain.Visit{})
+
+	assert.Equal(t, ErrUnsafeURL, err)
+	assert.Nil(t, redirect)
+	time.Sleep(100 * time.Millisecond)
+	mockCache.AssertNotCalled(t, "Increment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_ResolveURL_StorageUnavailable(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), nil)
+
+	mockCache.On("Get", mock.Anything, "url:abc", mock.Anything).Return(fmt.Errorf("key %w", domain.ErrNotFound))
+	mockRepo.On("GetURLByShortCode", mock.Anything, "", "abc").Return(nil, fmt.Errorf("failed to get URL: %w", domain.ErrUnavailable))
+
+	_, err := urlService.ResolveURL(context.Background(), "abc", &domain.Visit{})
+
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.NotErrorIs(t, err, ErrURLNotFound, "an outage is not a missing link")
+	mockCache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_RescanURLs(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	mockScanner := new(mocks.MockURLScanner)
+	mockSafety := new(mocks.MockSafetyRepository)
+	mockLinks := new(mocks.MockLinkPublisher)
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), nil,
+		WithURLScanner(mockScanner),
+		WithSafetyRepository(mockSafety),
+		WithLinkPublisher(mockLinks),
+	)
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	mockSafety.On("ListURLsForRescan", mock.Anything, cutoff, 50).Return([]*domain.URL{
+		{ShortCode: "ok", OriginalURL: "https://example.com", SafetyStatus: domain.SafetySafe},
+		{ShortCode: "turned", OriginalURL: "https://turned.example", SafetyStatus: domain.SafetySafe},
+		{ShortCode: "flaky", OriginalURL: "https://flaky.example"},
+	}, nil)
+	mockScanner.On("Scan", mock.Anything, "https://example.com").
+		Return(&domain.ScanResult{URL: "https://example.com"}, nil)
+	mockScanner.On("Scan", mock.Anything, "https://turned.example").
+		Return(&domain.ScanResult{URL: "https://turned.example", Threats: []domain.Threat{{Provider: "blocklist", Category: domain.ThreatBlocklisted}}}, nil)
+	mockScanner.On("Scan", mock.Anything, "https://flaky.example").
+		Return(nil, errors.New("timeout"))
+	mockSafety.On("UpdateSafetyStatus", mock.Anything, "", "ok", domain.SafetySafe, mock.AnythingOfType("time.Time")).Return(nil)
+	mockSafety.On("UpdateSafetyStatus", mock.Anything, "", "turned", domain.SafetyUnsafe, mock.AnythingOfType("time.Time")).Return(nil)
+	// A failed scan keeps the verdict but still counts as a check, so the link
+	// does not stay at the front of the queue
+	mockSafety.On("UpdateSafetyStatus", mock.Anything, "", "flaky", "", mock.AnythingOfType("time.Time")).Return(nil)
+
+	// Only the link whose verdict changed is evicted
+	mockCache.On("Delete", mock.Anything, "url:turned").Return(nil)
+	mockCache.On("Delete", mock.Anything, "lurl:https://turned.example").Return(nil)
+	mockLinks.On("PublishLinkChange", mock.Anything, &domain.LinkChange{ShortCode: "turned", OriginalURL: "https://turned.example"}).Return(nil).Once()
+
+	flagged, err := urlService.RescanURLs(context.Background(), cutoff, 50)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, flagged)
+	mockSafety.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+	mockLinks.AssertExpectations(t)
+}
+
+func TestURLService_ShortenURL_DestinationPolicy(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	mockPolicy := new(mocks.MockDestinationPolicy)
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), &config.Config{
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+	}, WithDestinationPolicy(mockPolicy))
+
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("not found"))
+	mockRepo.On("GetURLByOriginalURL", mock.Anything, "", mock.Anything).Return(nil, domain.ErrNotFound)
+	mockPolicy.On("Allow", mock.Anything, "https://example.com").Return(nil)
+	mockPolicy.On("Allow", mock.Anything, "http://169.254.169.254/latest").Return(errors.New("private address"))
+
+	// Every destination is checked, not just the main URL
+	response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+		URL:      "https://example.com",
+		GeoRules: domain.GeoRules{{Country: "DE", URL: "http://169.254.169.254/latest"}},
+	})
+
+	assert.Equal(t, ErrDestinationNotAllowed, err)
+	assert.Nil(t, response)
+	mockPolicy.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CreateURL", mock.Anything, mock.Anything)
+}
+
+func TestURLService_ShortenURL_OpenGraph(t *testing.T) {
+	newService := func(t *testing.T) (*URLService, *mocks.MockURLRepository, *mocks.MockMetadataFetcher) {
+		mockRepo := new(mocks.MockURLRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		mockFetcher := new(mocks.MockMetadataFetcher)
+		urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), &config.Config{
+			Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+			Snowflake: config.SnowflakeConfig{MachineID: 1},
+		}, WithMetadataFetcher(mockFetcher))
+
+		mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("not found"))
+		mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, time.Hour).Return(nil)
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", mock.Anything).Return(nil, domain.ErrNotFound).Maybe()
+		return urlService, mockRepo, mockFetcher
+	}
+
+	t.Run("OverridesWinOverFetched", func(t *testing.T) {
+		urlService, mockRepo, mockFetcher := newService(t)
+		mockFetcher.On("FetchOpenGraph", mock.Anything, "https://example.com").Return(&domain.OpenGraph{
+			Title:       "Fetched title",
+			Description: "Fetched description",
+			Image:       "https://example.com/fetched.png",
+		}, nil)
+		mockRepo.On("CreateURL", mock.Anything, mock.MatchedBy(func(url *domain.URL) bool {
+			return url.OGTitle == "Launch" && url.OGDescription == "Fetched description" && url.OGImage == "https://example.com/fetched.png"
+		})).Return(nil)
+
+		response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+			URL:       "https://example.com",
+			OpenGraph: &domain.OpenGraph{Title: "Launch"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Launch", response.OpenGraph.Title)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("FetchFailureKeepsLink", func(t *testing.T) {
+		urlService, mockRepo, mockFetcher := newService(t)
+		mockFetcher.On("FetchOpenGraph", mock.Anything, "https://example.com").Return(nil, errors.New("timeout"))
+		mockRepo.On("CreateURL", mock.Anything, mock.MatchedBy(func(url *domain.URL) bool {
+			return url.OGTitle == "" && url.OGImage == ""
+		})).Return(nil)
+
+		response, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{URL: "https://example.com"})
+
+		assert.NoError(t, err)
+		assert.Nil(t, response.OpenGraph)
+	})
+
+	t.Run("CompleteOverridesSkipFetch", func(t *testing.T) {
+		urlService, mockRepo, mockFetcher := newService(t)
+		mockRepo.On("CreateURL", mock.Anything, mock.Anything).Return(nil)
+
+		_, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+			URL:       "https://example.com",
+			OpenGraph: &domain.OpenGraph{Title: "T", Description: "D", Image: "https://example.com/i.png"},
+		})
+
+		assert.NoError(t, err)
+		mockFetcher.AssertNotCalled(t, "FetchOpenGraph", mock.Anything, mock.Anything)
+	})
+
+	t.Run("InvalidImageRejected", func(t *testing.T) {
+		urlService, mockRepo, _ := newService(t)
+
+		_, err := urlService.ShortenURL(context.Background(), &domain.ShortenRequest{
+			URL:       "https://example.com",
+			OpenGraph: &domain.OpenGraph{Image: "not a url"},
+		})
+
+		assert.Equal(t, ErrInvalidOpenGraph, err)
+		mockRepo.AssertNotCalled(t, "CreateURL", mock.Anything, mock.Anything)
+	})
+}
+
+func TestURLService_SocialCard(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), &config.Config{
+		Server: config.ServerConfig{BaseURL: "http://sho.rt"},
+	})
+
+	links := map[string]domain.URL{
+		"card": {ShortCode: "card", OriginalURL: "https://example.com", CreatedAt: time.Now(), OGTitle: "Hello", OGImage: "https://example.com/i.png"},
+		"bare": {ShortCode: "bare", OriginalURL: "https://example.com/bare", CreatedAt: time.Now()},
+		"off":  {ShortCode: "off", OriginalURL: "https://example.com", CreatedAt: time.Now(), Disabled: true},
+	}
+	for code, link := range links {
+		mockCache.On("Get", mock.Anything, "url:"+code, mock.AnythingOfType("*domain.URL")).
+			Run(func(args mock.Arguments) { *args.Get(2).(*domain.URL) = link }).
+			Return(nil)
+	}
+
+	card, err := urlService.SocialCard(context.Background(), "", "card")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://sho.rt/card", card.ShortURL)
+	assert.Equal(t, "Hello", card.Title)
+	assert.Equal(t, "https://example.com/i.png", card.Image)
+
+	card, err = urlService.SocialCard(context.Background(), "", "bare")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/bare", card.Title) // Falls back to the destination
+
+	_, err = urlService.SocialCard(context.Background(), "", "off")
+	assert.Equal(t, ErrURLDisabled, err)
+
+	// Unfurling is not a click
+	time.Sleep(100 * time.Millisecond)
+	mockCache.AssertNotCalled(t, "Increment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_BrandedDomain(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	domainRepo := new(mocks.MockDomainRepository)
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), &config.Config{
+		Server:    config.ServerConfig{BaseURL: "https://sho.rt"},
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+	}, WithDomainRepository(domainRepo))
+	ctx := context.Background()
+
+	brand := &domain.Domain{Name: "go.brand.com"}
+	mockCache.On("Get", mock.Anything, "domain:go.brand.com", mock.Anything).
+		Run(func(args mock.Arguments) { *args.Get(2).(*domain.Domain) = *brand }).
+		Return(nil)
+	for _, host := range []string{"unknown.com", "example.com"} { // Cached misses
+		mockCache.On("Get", mock.Anything, "domain:"+host, mock.Anything).
+			Run(func(args mock.Arguments) { *args.Get(2).(*domain.Domain) = domain.Domain{} }).
+			Return(nil)
+	}
+
+	t.Run("UnknownDomain", func(t *testing.T) {
+		_, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com", Domain: "unknown.com"})
+		assert.Equal(t, ErrUnknownDomain, err)
+	})
+
+	t.Run("AliasPerDomain", func(t *testing.T) {
+		// "sale" is taken on the default domain but free on the branded one
+		mockCache.On("Get", mock.Anything, "lurl:go.brand.com/https://example.com", mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "go.brand.com", "https://example.com").Return(nil, domain.ErrNotFound)
+		mockRepo.On("CreateURL", mock.Anything, mock.MatchedBy(func(u *domain.URL) bool {
+			return u.Domain == "go.brand.com" && u.ShortCode == "sale"
+		})).Return(nil)
+		mockCache.On("Set", mock.Anything, "url:go.brand.com/sale", mock.Anything, time.Hour).Return(nil)
+		mockCache.On("Set", mock.Anything, "lurl:go.brand.com/https://example.com", mock.Anything, time.Hour).Return(nil)
+
+		response, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com", CustomAlias: "sale", Domain: "Go.Brand.com"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://go.brand.com/sale", response.ShortURL)
+		assert.Equal(t, "go.brand.com", response.Domain)
+	})
+
+	t.Run("BrandedDestinationRejected", func(t *testing.T) {
+		mockCache.On("Get", mock.Anything, "lurl:https://go.brand.com/other", mock.Anything).Return(errors.New("not found"))
+		mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://go.brand.com/other").Return(nil, domain.ErrNotFound)
+
+		_, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://go.brand.com/other"})
+		assert.Equal(t, ErrDestinationNotAllowed, err)
+	})
+
+	t.Run("ResolvesWithinDomain", func(t *testing.T) {
+		mockCache.On("Get", mock.Anything, "url:go.brand.com/sale", mock.AnythingOfType("*domain.URL")).Return(errors.New("not found"))
+		mockRepo.On("GetURLByShortCode", mock.Anything, "go.brand.com", "sale").
+			Return(&domain.URL{Domain: "go.brand.com", ShortCode: "sale", OriginalURL: "https://example.com/brand", CreatedAt: time.Now()}, nil)
+		mockCache.On("Increment", mock.Anything, "clicks:go.brand.com/sale", int64(1)).Return(nil)
+
+		redirect, err := urlService.ResolveURL(ctx, "sale", &domain.Visit{Domain: "go.brand.com"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/brand", redirect.Location)
+		time.Sleep(100 * time.Millisecond)
+		mockCache.AssertCalled(t, "Increment", mock.Anything, "clicks:go.brand.com/sale", int64(1))
+	})
+}
+
+func TestURLService_ShortenURL_LinkQuota(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	workspaceRepo := new(mocks.MockWorkspaceRepository)
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), &config.Config{
+		Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+	}, WithWorkspaceRepository(workspaceRepo))
+	ctx := domain.WithWorkspace(context.Background(), 5)
+
+	mockCache.On("Get", mock.Anything, "lurl:https://example.com/full", mock.Anything).Return(errors.New("not found"))
+	mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com/full").Return(nil, domain.ErrNotFound)
+	workspaceRepo.On("GetWorkspace", mock.Anything, int64(5)).Return(&domain.Workspace{ID: 5, MaxLinks: 2}, nil)
+	workspaceRepo.On("CountURLs", mock.Anything, int64(5)).Return(int64(2), nil)
+
+	_, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com/full"})
+
+	assert.Equal(t, ErrLinkQuotaExceeded, err)
+	mockRepo.AssertNotCalled(t, "CreateURL", mock.Anything, mock.Anything)
+}
+
+func TestURLService_ResolveURL_CoalescesMisses(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	urlService := NewURLService(mockRepo, mockCache, zaptest.NewLogger(t), nil)
+
+	var queries atomic.Int32
+	release := make(chan struct{})
+	mockCache.On("Get", mock.Anything, "url:viral", mock.Anything).Return(fmt.Errorf("key %w", domain.ErrNotFound))
+	mockCache.On("Set", mock.Anything, "url:viral", mock.Anything, time.Hour).Return(nil)
+	mockCache.On("Increment", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockRepo.On("GetURLByShortCode", mock.Anything, "", "viral").Run(func(mock.Arguments) {
+		queries.Add(1)
+		<-release
+	}).Return(&domain.URL{ShortCode: "viral", OriginalURL: "https://example.com/viral"}, nil)
+
+	const n = 20
+	var wg sync.WaitGroup
+	locations := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			location, err := urlService.GetOriginalURL(context.Background(), "viral")
+			assert.NoError(t, err)
+			locations[i] = location
+		}(i)
+	}
+	require.Eventually(t, func() bool { return queries.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond) // Let the other lookups queue up behind the first
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), queries.Load(), "concurrent misses share one query")
+	for _, location := range locations {
+		assert.Equal(t, "https://example.com/viral", location)
+	}
+}
+
+func TestURLService_ResolveURL_NegativeCache(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	cache := memory.NewCache()
+	urlService := NewURLService(mockRepo, cache, zaptest.NewLogger(t), &config.Config{
+		Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+		Cache:     config.CacheConfig{NegativeTTL: 30 * time.Second},
+	})
+	ctx := context.Background()
+
+	mockRepo.On("GetURLByShortCode", mock.Anything, "", "probe").Return(nil, domain.ErrNotFound).Twice()
+	for i := 0; i < 3; i++ {
+		_, err := urlService.GetOriginalURL(ctx, "probe")
+		assert.ErrorIs(t, err, ErrURLNotFound)
+	}
+	mockRepo.AssertNumberOfCalls(t, "GetURLByShortCode", 2) // The miss and its check once remembered
+
+	// Creating the code forgets that it was missing
+	mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").Return(nil, domain.ErrNotFound)
+	mockRepo.On("CreateURL", mock.Anything, mock.AnythingOfType("*domain.URL")).Return(nil)
+	_, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com", CustomAlias: "probe"})
+	require.NoError(t, err)
+
+	var missing bool
+	assert.ErrorIs(t, cache.Get(ctx, "nurl:probe", &missing), domain.ErrNotFound)
+	location, err := urlService.GetOriginalURL(ctx, "probe")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", location)
+}
+
+func TestURLService_ResolveURL_MissRacingACreate(t *testing.T) {
+	mockRepo := new(mocks.MockURLRepository)
+	cache := memory.NewCache()
+	urlService := NewURLService(mockRepo, cache, zaptest.NewLogger(t), &config.Config{
+		Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+		Cache:     config.CacheConfig{NegativeTTL: 30 * time.Second},
+	})
+	ctx := context.Background()
+
+	// The code is created, and its missing entry forgotten, after the lookup
+	// missed but before the miss is remembered
+	mockRepo.On("GetURLByOriginalURL", mock.Anything, "", "https://example.com").Return(nil, domain.ErrNotFound)
+	mockRepo.On("CreateURL", mock.Anything, mock.AnythingOfType("*domain.URL")).Return(nil)
+	mockRepo.On("GetURLByShortCode", mock.Anything, "", "probe").Run(func(mock.Arguments) {
+		_, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com", CustomAlias: "probe"})
+		require.NoError(t, err)
+	}).Return(nil, domain.ErrNotFound).Once()
+	mockRepo.On("GetURLByShortCode", mock.Anything, "", "probe").
+		Return(&domain.URL{ShortCode: "probe", OriginalURL: "https://example.com", CreatedAt: time.Now()}, nil).Once()
+
+	location, err := urlService.GetOriginalURL(ctx, "probe")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", location)
+
+	var missing bool
+	assert.ErrorIs(t, cache.Get(ctx, "nurl:probe", &missing), domain.ErrNotFound, "the created link is not hidden")
+}
+
+func TestURLService_ShortenURL_OpenGraphLinksAreNotReused(t *testing.T) {
+	ctx := context.Background()
+	urlService := NewURLService(memory.NewStore(nil), memory.NewCache(), zaptest.NewLogger(t), &config.Config{
+		Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+	})
+
+	preview, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com", OpenGraph: &domain.OpenGraph{Title: "Spring sale"}})
+	require.NoError(t, err)
+	plain, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.NotEqual(t, preview.ShortCode, plain.ShortCode, "plain requests do not get a link with a custom preview")
+	assert.True(t, (&domain.URL{OGImage: "https://example.com/og.png"}).HasLinkOptions())
+}
+
+func TestURLService_ShortenURL_OptionsAreNotDeduplicated(t *testing.T) {
+	ctx := context.Background()
+	urlService := NewURLService(memory.NewStore(nil), memory.NewCache(), zaptest.NewLogger(t), &config.Config{
+		Server:    config.ServerConfig{BaseURL: "http://localhost:8080"},
+		Snowflake: config.SnowflakeConfig{MachineID: 1},
+	})
+	launch := time.Now().Add(time.Hour)
+
+	plain, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	scheduled, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com", ActivatesAt: &launch})
+	require.NoError(t, err)
+	assert.NotEqual(t, plain.ShortCode, scheduled.ShortCode, "options get a link of their own")
+	temporary, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com", RedirectStatus: http.StatusFound})
+	require.NoError(t, err)
+	assert.NotEqual(t, scheduled.ShortCode, temporary.ShortCode, "different options get different links")
+
+	again, err := urlService.ShortenURL(ctx, &domain.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, plain.ShortCode, again.ShortCode, "plain requests still share the plain link")
+	_, err = urlService.GetOriginalURL(ctx, plain.ShortCode)
+	assert.NoError(t, err)
+	_, err = urlService.GetOriginalURL(ctx, scheduled.ShortCode)
+	assert.ErrorIs(t, err, ErrURLNotActive)
 }
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrInsufficientRole  = errors.New("your role in the workspace does not allow this")
+	ErrInvalidWorkspace  = errors.New("workspace name must be between 1 and 100 characters")
+	ErrInvalidMember     = errors.New("members need a user ID and a role of owner, editor or viewer")
+	ErrMemberNotFound    = errors.New("member not found")
+	ErrLastOwner         = errors.New("a workspace must keep at least one owner")
+	ErrInvalidAPIKey     = errors.New("invalid API key")
+	ErrAPIKeyNotFound    = errors.New("API key not found")
+	ErrAPIQuotaExceeded  = errors.New("daily API quota exceeded")
+)
+
+const (
+	apiKeyPrefix      = "usk_"
+	apiKeyBytes       = 24
+	apiQuotaRetention = 48 * time.Hour // Daily counters outlive their day across time zones
+	workspaceCacheTTL = 5 * time.Minute
+)
+
+type WorkspaceService struct {
+	repo      domain.WorkspaceRepository
+	cacheRepo domain.CacheRepository
+	logger    *zap.Logger
+	cfg       config.WorkspaceConfig
+}
+
+func NewWorkspaceService(repo domain.WorkspaceRepository, cacheRepo domain.CacheRepository, logger *zap.Logger, cfg config.WorkspaceConfig) *WorkspaceService {
+	return &WorkspaceService{
+		repo:      repo,
+		cacheRepo: cacheRepo,
+		logger:    logger,
+		cfg:       cfg,
+	}
+}
+
+// CreateWorkspace creates a workspace with the configured quotas and makes
+// the user its owner
+func (s *WorkspaceService) CreateWorkspace(ctx context.Context, userID, name string) (*domain.Workspace, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, ErrInvalidWorkspace
+	}
+
+	ws := &domain.Workspace{
+		Name:        name,
+		MaxLinks:    s.cfg.MaxLinks,
+		MaxAPICalls: s.cfg.MaxAPICalls,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.CreateWorkspace(ctx, ws, userID); err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	s.logger.Info("Workspace created", zap.Int64("workspace_id", ws.ID), zap.String("owner", userID))
+	return ws, nil
+}
+
+// ListWorkspaces returns the workspaces the user is a member of
+func (s *WorkspaceService) ListWorkspaces(ctx context.Context, userID string) ([]*domain.Workspace, error) {
+	workspaces, err := s.repo.ListWorkspaces(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	return workspaces, nil
+}
+
+// Membership returns the user's role in a workspace. Users outside the
+// workspace get ErrWorkspaceNotFound so they cannot probe for workspaces.
+func (s *WorkspaceService) Membership(ctx context.Context, workspaceID int64, userID string) (*domain.Member, error) {
+	if userID == "" {
+		return nil, ErrWorkspaceNotFound
+	}
+	member, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+	return member, nil
+}
+
+// authorize checks that the user holds at least the required role
+func (s *WorkspaceService) authorize(ctx context.Context, workspaceID int64, userID string, required domain.Role) error {
+	member, err := s.Membership(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if !member.Role.Allows(required) {
+		return ErrInsufficientRole
+	}
+	return nil
+}
+
+// ListMembers returns a workspace's members to any of them
+func (s *WorkspaceService) ListMembers(ctx context.Context, actorID string, workspaceID int64) ([]*domain.Member, error) {
+	if err := s.authorize(ctx, workspaceID, actorID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+	members, err := s.repo.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	return members, nil
+}
+
+// SaveMember adds a user to a workspace or changes their role; only owners
+// may do so
+func (s *WorkspaceService) SaveMember(ctx context.Context, actorID string, workspaceID int64, userID string, role domain.Role) (*domain.Member, error) {
+	if strings.TrimSpace(userID) == "" || !role.Valid() {
+		return nil, ErrInvalidMember
+	}
+	if err := s.authorize(ctx, workspaceID, actorID, domain.RoleOwner); err != nil {
+		return nil, err
+	}
+	if role != domain.RoleOwner {
+		if err := s.keepOwner(ctx, workspaceID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	member := &domain.Member{WorkspaceID: workspaceID, UserID: userID, Role: role, CreatedAt: time.Now()}
+	if err := s.repo.SaveMember(ctx, member); err != nil {
+		return nil, fmt.Errorf("failed to save member: %w", err)
+	}
+	return member, nil
+}
+
+// RemoveMember takes a user out of a workspace. Owners may remove anyone and
+// every member may leave.
+func (s *WorkspaceService) RemoveMember(ctx context.Context, actorID string, workspaceID int64, userID string) error {
+	required := domain.RoleOwner
+	if actorID == userID {
+		required = domain.RoleViewer
+	}
+	if err := s.authorize(ctx, workspaceID, actorID, required); err != nil {
+		return err
+	}
+	if err := s.keepOwner(ctx, workspaceID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveMember(ctx, workspaceID, userID); errors.Is(err, domain.ErrNotFound) {
+		return ErrMemberNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	return nil
+}
+
+// keepOwner refuses to demote or remove the user if they are the last owner
+func (s *WorkspaceService) keepOwner(ctx context.Context, workspaceID int64, userID string) error {
+	members, err := s.repo.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to list members: %w", err)
+	}
+	owners, isOwner := 0, false
+	for _, m := range members {
+		if m.Role == domain.RoleOwner {
+			owners++
+			isOwner = isOwner || m.UserID == userID
+		}
+	}
+	if isOwner && owners == 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// CreateAPIKey issues a key acting with the given role in the workspace. The
+// returned key is the only time its secret is available.
+func (s *WorkspaceService) CreateAPIKey(ctx context.Context, actorID string, workspaceID int64, name string, role domain.Role) (*domain.APIKey, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidMember
+	}
+	if err := s.authorize(ctx, workspaceID, actorID, domain.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	raw := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &domain.APIKey{
+		WorkspaceID: workspaceID,
+		Name:        strings.TrimSpace(name),
+		Prefix:      raw[:len(apiKeyPrefix)+6],
+		KeyHash:     hashAPIKey(raw),
+		Role:        role,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	key.Key = raw
+	s.logger.Info("API key created", zap.Int64("workspace_id", workspaceID), zap.String("prefix", key.Prefix))
+	return key, nil
+}
+
+// ListAPIKeys returns a workspace's keys, without their secrets, to its owners
+func (s *WorkspaceService) ListAPIKeys(ctx context.Context, actorID string, workspaceID int64) ([]*domain.APIKey, error) {
+	if err := s.authorize(ctx, workspaceID, actorID, domain.RoleOwner); err != nil {
+		return nil, err
+	}
+	keys, err := s.repo.ListAPIKeys(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey deletes a key so it stops authenticating
+func (s *WorkspaceService) RevokeAPIKey(ctx context.Context, actorID string, workspaceID, keyID int64) error {
+	if err := s.authorize(ctx, workspaceID, actorID, domain.RoleOwner); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteAPIKey(ctx, workspaceID, keyID); errors.Is(err, domain.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the stored key matching a raw API key
+func (s *WorkspaceService) AuthenticateAPIKey(ctx context.Context, raw string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(raw, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.repo.GetAPIKey(ctx, hashAPIKey(raw))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return key, nil
+}
+
+// CountAPICall records an API request against the workspace's daily quota,
+// returning ErrAPIQuotaExceeded once it is used up. Counting fails open when
+// the cache is unavailable.
+func (s *WorkspaceService) CountAPICall(ctx context.Context, workspaceID int64) error {
+	ws, err := s.limits(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if ws.MaxAPICalls == 0 {
+		return nil
+	}
+
+	scoped := domain.WithWorkspace(ctx, workspaceID)
+	key := fmt.Sprintf("quota:api:%s", time.Now().UTC().Format("2006-01-02"))
+	if err := s.cacheRepo.Increment(scoped, key, 1); err != nil {
+		s.logger.Warn("Failed to count API call", zap.Int64("workspace_id", workspaceID), zap.Error(err))
+		return nil
+	}
+	calls, err := s.cacheRepo.GetCounter(scoped, key)
+	if err != nil {
+		s.logger.Warn("Failed to read API call count", zap.Int64("workspace_id", workspaceID), zap.Error(err))
+		return nil
+	}
+	if calls == 1 {
+		if err := s.cacheRepo.Expire(scoped, key, apiQuotaRetention); err != nil {
+			s.logger.Warn("Failed to expire API call count", zap.Error(err))
+		}
+	}
+	if calls > ws.MaxAPICalls {
+		return ErrAPIQuotaExceeded
+	}
+	return nil
+}
+
+// limits returns a workspace with its quotas, cached since every API
+// request needs them
+func (s *WorkspaceService) limits(ctx context.Context, workspaceID int64) (*domain.Workspace, error) {
+	shared := domain.WithoutWorkspace(ctx)
+	cacheKey := fmt.Sprintf("workspace:%d", workspaceID)
+	var cached domain.Workspace
+	if err := s.cacheRepo.Get(shared, cacheKey, &cached); err == nil {
+		return &cached, nil
+	}
+
+	ws, err := s.repo.GetWorkspace(ctx, workspaceID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace: %w", err)
+	}
+	if err := s.cacheRepo.Set(shared, cacheKey, ws, workspaceCacheTTL); err != nil {
+		s.logger.Warn("Failed to cache workspace", zap.Int64("workspace_id", workspaceID), zap.Error(err))
+	}
+	return ws, nil
+}
+
+// SharedRole is the role of a caller in the shared workspace 0, which holds
+// the links created without a workspace. Owners are named in the
+// configuration, other signed-in users may create links, and callers
+// without credentials get the configured public role, by default viewer.
+func (s *WorkspaceService) SharedRole(userID string) domain.Role {
+	switch {
+	case userID != "" && slices.Contains(s.cfg.Admins, userID):
+		return domain.RoleOwner
+	case userID != "":
+		return domain.RoleEditor
+	case s.cfg.PublicRole != "":
+		return domain.Role(s.cfg.PublicRole)
+	default:
+		return domain.RoleViewer
+	}
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/config"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/mocks"
+)
+
+func TestWorkspaceService_CreateWorkspace(t *testing.T) {
+	repo := new(mocks.MockWorkspaceRepository)
+	svc := NewWorkspaceService(repo, nil, zaptest.NewLogger(t), config.WorkspaceConfig{MaxLinks: 500, MaxAPICalls: 10000})
+
+	repo.On("CreateWorkspace", mock.Anything, mock.MatchedBy(func(ws *domain.Workspace) bool {
+		return ws.Name == "Marketing" && ws.MaxLinks == 500 && ws.MaxAPICalls == 10000
+	}), "alice").Return(nil)
+
+	ws, err := svc.CreateWorkspace(context.Background(), "alice", "  Marketing ")
+	require.NoError(t, err)
+	assert.Equal(t, "Marketing", ws.Name)
+
+	_, err = svc.CreateWorkspace(context.Background(), "alice", " ")
+	assert.Equal(t, ErrInvalidWorkspace, err)
+}
+
+func TestWorkspaceService_Members(t *testing.T) {
+	repo := new(mocks.MockWorkspaceRepository)
+	svc := NewWorkspaceService(repo, nil, zaptest.NewLogger(t), config.WorkspaceConfig{})
+	ctx := context.Background()
+
+	repo.On("GetMember", mock.Anything, int64(1), "alice").Return(&domain.Member{WorkspaceID: 1, UserID: "alice", Role: domain.RoleOwner}, nil)
+	repo.On("GetMember", mock.Anything, int64(1), "bob").Return(&domain.Member{WorkspaceID: 1, UserID: "bob", Role: domain.RoleEditor}, nil)
+	repo.On("GetMember", mock.Anything, int64(1), "mallory").Return(nil, domain.ErrNotFound)
+	repo.On("ListMembers", mock.Anything, int64(1)).Return([]*domain.Member{
+		{WorkspaceID: 1, UserID: "alice", Role: domain.RoleOwner},
+		{WorkspaceID: 1, UserID: "bob", Role: domain.RoleEditor},
+	}, nil)
+	repo.On("SaveMember", mock.Anything, mock.Anything).Return(nil)
+
+	t.Run("OwnerAddsMember", func(t *testing.T) {
+		member, err := svc.SaveMember(ctx, "alice", 1, "carol", domain.RoleViewer)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RoleViewer, member.Role)
+	})
+
+	t.Run("EditorCannotAddMembers", func(t *testing.T) {
+		_, err := svc.SaveMember(ctx, "bob", 1, "carol", domain.RoleViewer)
+		assert.Equal(t, ErrInsufficientRole, err)
+	})
+
+	t.Run("OutsidersSeeNothing", func(t *testing.T) {
+		_, err := svc.ListMembers(ctx, "mallory", 1)
+		assert.Equal(t, ErrWorkspaceNotFound, err)
+	})
+
+	t.Run("InvalidRole", func(t *testing.T) {
+		_, err := svc.SaveMember(ctx, "alice", 1, "carol", "admin")
+		assert.Equal(t, ErrInvalidMember, err)
+	})
+
+	t.Run("KeepsLastOwner", func(t *testing.T) {
+		_, err := svc.SaveMember(ctx, "alice", 1, "alice", domain.RoleEditor)
+		assert.Equal(t, ErrLastOwner, err)
+
+		err = svc.RemoveMember(ctx, "alice", 1, "alice")
+		assert.Equal(t, ErrLastOwner, err)
+	})
+
+	t.Run("MembersMayLeave", func(t *testing.T) {
+		repo.On("RemoveMember", mock.Anything, int64(1), "bob").Return(nil)
+		assert.NoError(t, svc.RemoveMember(ctx, "bob", 1, "bob"))
+	})
+}
+
+func TestWorkspaceService_APIKeys(t *testing.T) {
+	repo := new(mocks.MockWorkspaceRepository)
+	svc := NewWorkspaceService(repo, nil, zaptest.NewLogger(t), config.WorkspaceConfig{})
+	ctx := context.Background()
+
+	repo.On("GetMember", mock.Anything, int64(1), "alice").Return(&domain.Member{WorkspaceID: 1, UserID: "alice", Role: domain.RoleOwner}, nil)
+	var stored *domain.APIKey
+	repo.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("*domain.APIKey")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*domain.APIKey) }).
+		Return(nil)
+
+	key, err := svc.CreateAPIKey(ctx, "alice", 1, "ci", domain.RoleEditor)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key.Key, "usk_"))
+	assert.True(t, strings.HasPrefix(key.Key, key.Prefix))
+	assert.NotContains(t, stored.KeyHash, key.Key[4:]) // Only a hash is stored
+
+	repo.On("GetAPIKey", mock.Anything, stored.KeyHash).Return(stored, nil)
+	repo.On("GetAPIKey", mock.Anything, mock.Anything).Return(nil, domain.ErrNotFound)
+
+	found, err := svc.AuthenticateAPIKey(ctx, key.Key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), found.WorkspaceID)
+	assert.Equal(t, domain.RoleEditor, found.Role)
+
+	_, err = svc.AuthenticateAPIKey(ctx, key.Key+"x")
+	assert.Equal(t, ErrInvalidAPIKey, err)
+}
+
+func TestWorkspaceService_CountAPICall(t *testing.T) {
+	repo := new(mocks.MockWorkspaceRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	svc := NewWorkspaceService(repo, mockCache, zaptest.NewLogger(t), config.WorkspaceConfig{})
+	ctx := context.Background()
+
+	repo.On("GetWorkspace", mock.Anything, int64(1)).Return(&domain.Workspace{ID: 1, MaxAPICalls: 2}, nil).Once()
+	repo.On("GetWorkspace", mock.Anything, int64(2)).Return(&domain.Workspace{ID: 2}, nil).Once()
+
+	// Quotas are read from the database once, then from the shared cache
+	shared := mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := domain.WorkspaceFromContext(ctx)
+		return !ok
+	})
+	mockCache.On("Get", shared, "workspace:1", mock.Anything).Return(domain.ErrNotFound).Once()
+	mockCache.On("Get", shared, "workspace:1", mock.Anything).
+		Run(func(args mock.Arguments) { *args.Get(2).(*domain.Workspace) = domain.Workspace{ID: 1, MaxAPICalls: 2} }).
+		Return(nil)
+	mockCache.On("Get", shared, "workspace:2", mock.Anything).Return(domain.ErrNotFound)
+	mockCache.On("Set", shared, "workspace:1", mock.Anything, workspaceCacheTTL).Return(nil).Once()
+	mockCache.On("Set", shared, "workspace:2", mock.Anything, workspaceCacheTTL).Return(nil)
+
+	// The counter lives in the workspace's cache partition
+	inWorkspace := mock.MatchedBy(func(ctx context.Context) bool {
+		id, ok := domain.WorkspaceFromContext(ctx)
+		return ok && id == 1
+	})
+	isQuotaKey := mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "quota:api:") })
+	mockCache.On("Increment", inWorkspace, isQuotaKey, int64(1)).Return(nil)
+	mockCache.On("GetCounter", inWorkspace, isQuotaKey).Return(int64(1), nil).Once()
+	mockCache.On("GetCounter", inWorkspace, isQuotaKey).Return(int64(3), nil).Once()
+	mockCache.On("Expire", inWorkspace, isQuotaKey, apiQuotaRetention).Return(nil).Once()
+
+	assert.NoError(t, svc.CountAPICall(ctx, 1))
+	assert.Equal(t, ErrAPIQuotaExceeded, svc.CountAPICall(ctx, 1))
+	assert.NoError(t, svc.CountAPICall(ctx, 2)) // Unlimited
+	mockCache.AssertExpectations(t)
+	mockCache.AssertNumberOfCalls(t, "Increment", 2)
+	repo.AssertExpectations(t)
+}
+
+func TestWorkspaceService_SharedRole(t *testing.T) {
+	svc := NewWorkspaceService(nil, nil, zaptest.NewLogger(t), config.WorkspaceConfig{Admins: []string{"root"}})
+	assert.Equal(t, domain.RoleOwner, svc.SharedRole("root"))
+	assert.Equal(t, domain.RoleEditor, svc.SharedRole("alice"))
+	assert.Equal(t, domain.RoleViewer, svc.SharedRole(""), "least privilege without credentials")
+
+	public := NewWorkspaceService(nil, nil, zaptest.NewLogger(t), config.WorkspaceConfig{PublicRole: "editor"})
+	assert.Equal(t, domain.RoleEditor, public.SharedRole(""))
+	assert.Equal(t, domain.RoleEditor, public.SharedRole("root"), "owners must be named")
+}
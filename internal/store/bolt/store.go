@@ -0,0 +1,110 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/memory"
+)
+
+// records creates an empty record for each table, to decode into
+var records = map[string]func() interface{}{
+	memory.TableURLs:       func() interface{} { return new(domain.URL) },
+	memory.TableClicks:     func() interface{} { return new(domain.URLAnalytics) },
+	memory.TableDomains:    func() interface{} { return new(domain.Domain) },
+	memory.TableWorkspaces: func() interface{} { return new(domain.Workspace) },
+	memory.TableMembers:    func() interface{} { return new(domain.Member) },
+	memory.TableAPIKeys:    func() interface{} { return new(domain.APIKey) },
+}
+
+// Store keeps everything in a single bbolt database file. Records are loaded
+// into a memory.Store when the file is opened and every change is written
+// back before it is applied, so reads never touch the disk. It suits single
+// instances whose data fits in memory.
+type Store struct {
+	*memory.Store
+	db *bbolt.DB
+}
+
+// Open opens the database file at path, creating it if needed, and loads
+// its records
+func Open(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database file: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for table := range records {
+			if _, err := tx.CreateBucketIfNotExists([]byte(table)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	s := &Store{Store: memory.NewStore(&journal{db: db}), db: db}
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load database file: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		for table, newRecord := range records {
+			err := tx.Bucket([]byte(table)).ForEach(func(k, v []byte) error {
+				record := newRecord()
+				if err := gob.NewDecoder(bytes.NewReader(v)).Decode(record); err != nil {
+					return fmt.Errorf("%s %q: %w", table, k, err)
+				}
+				return s.Restore(record)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) HealthCheck(ctx context.Context) error {
+	return s.db.View(func(tx *bbolt.Tx) error { return nil })
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// journal writes the changes of a memory.Store to the database, one
+// transaction per change
+type journal struct {
+	db *bbolt.DB
+}
+
+func (j *journal) Put(table, key string, record interface{}) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(record); err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	return j.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(table)).Put([]byte(key), buf.Bytes())
+	})
+}
+
+func (j *journal) Delete(table, key string) error {
+	return j.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(table)).Delete([]byte(key))
+	})
+}
@@ -0,0 +1,80 @@
+package bolt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/storetest"
+)
+
+func TestStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shortener.db")
+	ctx := context.Background()
+	created := time.Now().Truncate(time.Second)
+
+	s, err := Open(path)
+	require.NoError(t, err)
+	link := &domain.URL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com",
+		CreatedAt:   created,
+		GeoRules:    domain.GeoRules{{Country: "DE", URL: "https://example.de"}},
+	}
+	require.NoError(t, s.CreateURL(ctx, link))
+	require.NoError(t, s.CreateURL(ctx, &domain.URL{ShortCode: "gone", OriginalURL: "https://example.com/gone"}))
+	require.NoError(t, s.UpdateClickCount(ctx, "", "abc123"))
+	require.NoError(t, s.RecordClick(ctx, &domain.URLAnalytics{ShortCode: "abc123", ClickedAt: created, Variant: "a"}))
+	require.NoError(t, s.Cleanup(ctx))
+	require.NoError(t, s.CreateURL(ctx, link))
+
+	ws := &domain.Workspace{Name: "Marketing", CreatedAt: created}
+	require.NoError(t, s.CreateWorkspace(ctx, ws, "alice"))
+	require.NoError(t, s.CreateAPIKey(ctx, &domain.APIKey{WorkspaceID: ws.ID, KeyHash: "hash", Role: domain.RoleEditor}))
+	require.NoError(t, s.HealthCheck(ctx))
+	require.NoError(t, s.Close())
+
+	s, err = Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	got, err := s.GetURLByShortCode(ctx, "", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", got.OriginalURL)
+	assert.True(t, got.CreatedAt.Equal(created))
+	assert.Equal(t, link.GeoRules, got.GeoRules)
+	_, err = s.GetURLByShortCode(ctx, "", "gone")
+	assert.Error(t, err, "deletions persist")
+
+	count, err := s.GetClickCount(ctx, "", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	member, err := s.GetMember(ctx, ws.ID, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleOwner, member.Role)
+	key, err := s.GetAPIKey(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, ws.ID, key.WorkspaceID)
+
+	// New records continue after the restored IDs
+	next := &domain.URL{ShortCode: "next", OriginalURL: "https://example.com/next"}
+	require.NoError(t, s.CreateURL(ctx, next))
+	assert.Greater(t, next.ID, got.ID)
+}
+
+func TestStore_Contract(t *testing.T) {
+	open := func(t *testing.T) *Store {
+		s, err := Open(filepath.Join(t.TempDir(), "shortener.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+	storetest.TestURLRepository(t, func(t *testing.T) domain.URLRepository { return open(t) })
+	storetest.TestAnalyticsRepository(t, func(t *testing.T) domain.AnalyticsRepository { return open(t) })
+}
@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// sweepInterval is how often writes also drop expired entries
+const sweepInterval = time.Minute
+
+// Cache stores JSON values in memory the way the Redis cache stores them in
+// Redis, including the "ws:<id>:" partition of workspace-scoped keys. It is
+// meant for a single instance; entries are lost on restart.
+type Cache struct {
+	mu        sync.Mutex
+	entries   map[string]entry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type entry struct {
+	value     []byte
+	expiresAt time.Time // Zero never expires
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+// key partitions a cache key by the workspace of ctx, if any
+func key(ctx context.Context, k string) string {
+	if workspaceID, ok := domain.WorkspaceFromContext(ctx); ok {
+		return fmt.Sprintf("ws:%d:%s", workspaceID, k)
+	}
+	return k
+}
+
+// live returns the unexpired entry of a key; callers hold the lock
+func (c *Cache) live(k string) (entry, bool) {
+	e, ok := c.entries[k]
+	if ok && e.expired(c.now()) {
+		delete(c.entries, k)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+// store keeps an entry, sweeping expired ones now and then; callers hold
+// the lock
+func (c *Cache) store(k string, e entry) {
+	now := c.now()
+	if now.Sub(c.lastSweep) >= sweepInterval {
+		for key, old := range c.entries {
+			if old.expired(now) {
+				delete(c.entries, key)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[k] = e
+}
+
+func (c *Cache) Set(ctx context.Context, k string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := entry{value: data}
+	if ttl > 0 {
+		e.expiresAt = c.now().Add(ttl)
+	}
+	c.store(key(ctx, k), e)
+	return nil
+}
+
+func (c *Cache) Get(ctx context.Context, k string, dest interface{}) error {
+	c.mu.Lock()
+	e, ok := c.live(key(ctx, k))
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("key %w", domain.ErrNotFound)
+	}
+
+	return json.Unmarshal(e.value, dest)
+}
+
+func (c *Cache) Delete(ctx context.Context, k string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key(ctx, k))
+	return nil
+}
+
+// Increment adds value to a counter, creating it at zero without expiry
+func (c *Cache) Increment(ctx context.Context, k string, value int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k = key(ctx, k)
+	e, ok := c.live(k)
+	var count int64
+	if ok {
+		var err error
+		if count, err = strconv.ParseInt(string(e.value), 10, 64); err != nil {
+			return fmt.Errorf("value is not an integer")
+		}
+	}
+	e.value = strconv.AppendInt(nil, count+value, 10)
+	c.store(k, e)
+	return nil
+}
+
+// Expire sets the time to live of an existing key; a ttl of zero or less
+// deletes it
+func (c *Cache) Expire(ctx context.Context, k string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k = key(ctx, k)
+	e, ok := c.live(k)
+	if !ok {
+		return nil
+	}
+	if ttl <= 0 {
+		delete(c.entries, k)
+		return nil
+	}
+	e.expiresAt = c.now().Add(ttl)
+	c.entries[k] = e
+	return nil
+}
+
+func (c *Cache) HealthCheck(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (c *Cache) Close() error {
+	return nil
+}
+
+// Cleanup drops every entry
+func (c *Cache) Cleanup(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]entry)
+	return nil
+}
+
+func (c *Cache) GetCounter(ctx context.Context, k string) (int64, error) {
+	c.mu.Lock()
+	e, ok := c.live(key(ctx, k))
+	c.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("key %w", domain.ErrNotFound)
+	}
+
+	return strconv.ParseInt(string(e.value), 10, 64)
+}
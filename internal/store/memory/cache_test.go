@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/storetest"
+)
+
+func TestCache_SetGetExpire(t *testing.T) {
+	c := NewCache()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "url:abc", &domain.URL{ShortCode: "abc"}, time.Minute))
+	var got domain.URL
+	require.NoError(t, c.Get(ctx, "url:abc", &got))
+	assert.Equal(t, "abc", got.ShortCode)
+
+	now = now.Add(time.Minute)
+	assert.EqualError(t, c.Get(ctx, "url:abc", &got), "key not found")
+
+	require.NoError(t, c.Set(ctx, "forever", "v", 0))
+	now = now.Add(24 * time.Hour)
+	var s string
+	require.NoError(t, c.Get(ctx, "forever", &s))
+	require.NoError(t, c.Delete(ctx, "forever"))
+	assert.Error(t, c.Get(ctx, "forever", &s))
+}
+
+func TestCache_Counters(t *testing.T) {
+	c := NewCache()
+	ctx := context.Background()
+
+	_, err := c.GetCounter(ctx, "clicks:abc")
+	assert.Error(t, err)
+
+	require.NoError(t, c.Increment(ctx, "clicks:abc", 1))
+	require.NoError(t, c.Increment(ctx, "clicks:abc", 2))
+	count, err := c.GetCounter(ctx, "clicks:abc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	var n int64 // Counters read back like any other value
+	require.NoError(t, c.Get(ctx, "clicks:abc", &n))
+	assert.Equal(t, int64(3), n)
+
+	require.NoError(t, c.Set(ctx, "text", "abc", 0))
+	assert.Error(t, c.Increment(ctx, "text", 1))
+
+	require.NoError(t, c.Expire(ctx, "clicks:abc", -1))
+	_, err = c.GetCounter(ctx, "clicks:abc")
+	assert.Error(t, err)
+}
+
+func TestCache_WorkspaceScopedKeys(t *testing.T) {
+	c := NewCache()
+	ctx := context.Background()
+	scoped := domain.WithWorkspace(ctx, 3)
+
+	require.NoError(t, c.Set(scoped, "foo", "bar", time.Minute))
+	var got string
+	assert.Error(t, c.Get(ctx, "foo", &got))
+	require.NoError(t, c.Get(ctx, "ws:3:foo", &got))
+	require.NoError(t, c.Get(scoped, "foo", &got))
+	assert.Equal(t, "bar", got)
+
+	require.NoError(t, c.Cleanup(ctx))
+	assert.Error(t, c.Get(scoped, "foo", &got))
+}
+
+func TestCache_Contract(t *testing.T) {
+	storetest.TestCacheRepository(t, func(t *testing.T) storetest.Cache {
+		c := NewCache()
+		now := time.Now()
+		c.now = func() time.Time { return now }
+		return storetest.Cache{CacheRepository: c, Advance: func(d time.Duration) { now = now.Add(d) }}
+	})
+}
@@ -0,0 +1,790 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/utils"
+)
+
+// Tables a Journal records changes to; each holds one record type
+const (
+	TableURLs       = "urls"              // *domain.URL by domain.LinkKey
+	TableClicks     = "url_analytics"     // *domain.URLAnalytics by ID
+	TableDomains    = "domains"           // *domain.Domain by name
+	TableWorkspaces = "workspaces"        // *domain.Workspace by ID
+	TableMembers    = "workspace_members" // *domain.Member by workspace and user
+	TableAPIKeys    = "api_keys"          // *domain.APIKey by hash
+)
+
+// Journal persists the changes made to a Store so it can be rebuilt with
+// Restore. A change is applied only after the journal accepted it.
+type Journal interface {
+	Put(table, key string, record interface{}) error
+	Delete(table, key string) error
+}
+
+// Store keeps links, clicks, domains and workspaces in memory. It implements
+// every repository the services use, honours workspace scopes like the
+// PostgreSQL store, and is safe for concurrent use.
+type Store struct {
+	mu         sync.RWMutex
+	journal    Journal
+	urls       map[string]*domain.URL // By domain.LinkKey
+	clicks     []*domain.URLAnalytics
+	domains    map[string]*domain.Domain
+	workspaces map[int64]*domain.Workspace
+	members    map[int64]map[string]*domain.Member
+	apiKeys    map[string]*domain.APIKey // By hash
+	lastID     map[string]int64          // Last ID handed out per table
+}
+
+// NewStore returns an empty store. With a journal every change is also
+// written to it.
+func NewStore(journal Journal) *Store {
+	return &Store{
+		journal:    journal,
+		urls:       make(map[string]*domain.URL),
+		domains:    make(map[string]*domain.Domain),
+		workspaces: make(map[int64]*domain.Workspace),
+		members:    make(map[int64]map[string]*domain.Member),
+		apiKeys:    make(map[string]*domain.APIKey),
+		lastID:     make(map[string]int64),
+	}
+}
+
+// Restore loads a record read back from a journal without journaling it again
+func (s *Store) Restore(record interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r := record.(type) {
+	case *domain.URL:
+		s.urls[r.Key()] = r
+		s.seen(TableURLs, r.ID)
+	case *domain.URLAnalytics:
+		s.clicks = append(s.clicks, r)
+		s.seen(TableClicks, int64(r.ID))
+	case *domain.Domain:
+		s.domains[r.Name] = r
+		s.seen(TableDomains, r.ID)
+	case *domain.Workspace:
+		s.workspaces[r.ID] = r
+		s.seen(TableWorkspaces, r.ID)
+	case *domain.Member:
+		s.putMember(r)
+	case *domain.APIKey:
+		s.apiKeys[r.KeyHash] = r
+		s.seen(TableAPIKeys, r.ID)
+	default:
+		return fmt.Errorf("unknown record type %T", record)
+	}
+	return nil
+}
+
+// seen keeps new IDs of a table past those of restored records
+func (s *Store) seen(table string, id int64) {
+	if id > s.lastID[table] {
+		s.lastID[table] = id
+	}
+}
+
+func (s *Store) put(table, key string, record interface{}) error {
+	if s.journal == nil {
+		return nil
+	}
+	return s.journal.Put(table, key, record)
+}
+
+func (s *Store) delete(table, key string) error {
+	if s.journal == nil {
+		return nil
+	}
+	return s.journal.Delete(table, key)
+}
+
+// inScope reports whether a record owned by workspaceID is visible in ctx
+func inScope(ctx context.Context, workspaceID int64) bool {
+	scope, ok := domain.WorkspaceFromContext(ctx)
+	return !ok || scope == workspaceID
+}
+
+func isExpired(u *domain.URL, now time.Time) bool {
+	return u.ExpiresAt != nil && u.ExpiresAt.Before(now)
+}
+
+func copyURL(u *domain.URL) *domain.URL {
+	c := *u
+	return &c
+}
+
+func (s *Store) CreateURL(ctx context.Context, url *domain.URL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := url.Key()
+	if _, exists := s.urls[key]; exists {
+		return fmt.Errorf("failed to insert URL: short code %q %w", key, domain.ErrConflict)
+	}
+
+	stored := copyURL(url)
+	stored.ID = s.lastID[TableURLs] + 1
+	if err := s.put(TableURLs, key, stored); err != nil {
+		return fmt.Errorf("failed to insert URL: %w", err)
+	}
+	s.urls[key] = stored
+	s.lastID[TableURLs] = stored.ID
+	url.ID = stored.ID
+	return nil
+}
+
+// lookup returns the stored link of a domain and short code visible in ctx
+func (s *Store) lookup(ctx context.Context, host, shortCode string) (*domain.URL, bool) {
+	u, ok := s.urls[domain.LinkKey(host, shortCode)]
+	if !ok || !inScope(ctx, u.WorkspaceID) {
+		return nil, false
+	}
+	return u, true
+}
+
+func (s *Store) GetURLByShortCode(ctx context.Context, host, shortCode string) (*domain.URL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.lookup(ctx, host, shortCode)
+	if !ok {
+		return nil, fmt.Errorf("URL %w", domain.ErrNotFound)
+	}
+	return copyURL(u), nil
+}
+
+// ListLinkKeys calls fn with the key of every link, outside the lock
+func (s *Store) ListLinkKeys(ctx context.Context, fn func(key string) error) error {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.urls))
+	for key := range s.urls {
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetURLByOriginalURL(ctx context.Context, host, originalURL string) (*domain.URL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var newest *domain.URL
+	for _, u := range s.urls {
+		if u.Domain != host || u.OriginalURL != originalURL || !inScope(ctx, u.WorkspaceID) {
+			continue
+		}
+		if newest == nil || u.CreatedAt.After(newest.CreatedAt) {
+			newest = u
+		}
+	}
+	if newest == nil {
+		return nil, fmt.Errorf("URL %w", domain.ErrNotFound)
+	}
+	return copyURL(newest), nil
+}
+
+// update applies change to a copy of a stored link and keeps it once the
+// journal has it
+func (s *Store) update(u *domain.URL, change func(*domain.URL)) error {
+	updated := copyURL(u)
+	change(updated)
+	if err := s.put(TableURLs, updated.Key(), updated); err != nil {
+		return err
+	}
+	s.urls[updated.Key()] = updated
+	return nil
+}
+
+func (s *Store) UpdateClickCount(ctx context.Context, host, shortCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.lookup(ctx, host, shortCode)
+	if !ok {
+		return fmt.Errorf("URL %w", domain.ErrNotFound)
+	}
+	now := time.Now()
+	err := s.update(u, func(u *domain.URL) {
+		u.ClickCount++
+		u.LastAccess = &now
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update click count: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetAnalytics(ctx context.Context, host, shortCode string, days int) (*domain.AnalyticsResponse, error) {
+	// Get basic URL info; this also keeps other workspaces' links out of reach
+	url, err := s.GetURLByShortCode(ctx, host, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	dailyStats, err := s.GetDailyStats(ctx, host, shortCode, days)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	since := time.Now().AddDate(0, 0, -days)
+	variants := make(map[string]int64)
+	for _, click := range s.clicks {
+		if click.Domain == host && click.ShortCode == shortCode && click.Variant != "" && !click.ClickedAt.Before(since) {
+			variants[click.Variant]++
+		}
+	}
+	s.mu.RUnlock()
+
+	var variantStats []domain.VariantStat
+	for variant, clicks := range variants {
+		variantStats = append(variantStats, domain.VariantStat{Variant: variant, Clicks: clicks})
+	}
+	sort.Slice(variantStats, func(i, j int) bool {
+		if variantStats[i].Clicks != variantStats[j].Clicks {
+			return variantStats[i].Clicks > variantStats[j].Clicks
+		}
+		return variantStats[i].Variant < variantStats[j].Variant
+	})
+
+	return &domain.AnalyticsResponse{
+		ShortCode:    url.ShortCode,
+		Domain:       url.Domain,
+		OriginalURL:  url.OriginalURL,
+		ClickCount:   url.ClickCount,
+		CreatedAt:    url.CreatedAt,
+		LastAccessed: url.LastAccess,
+		DailyStats:   dailyStats,
+		VariantStats: variantStats,
+	}, nil
+}
+
+func (s *Store) DeleteExpiredURLs(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, u := range s.urls {
+		if !isExpired(u, now) || !inScope(ctx, u.WorkspaceID) {
+			continue
+		}
+		if err := s.delete(TableURLs, key); err != nil {
+			return fmt.Errorf("failed to delete expired URLs: %w", err)
+		}
+		delete(s.urls, key)
+	}
+	return nil
+}
+
+func (s *Store) HealthCheck(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// Cleanup deletes every link
+func (s *Store) Cleanup(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.urls {
+		if err := s.delete(TableURLs, key); err != nil {
+			return fmt.Errorf("failed to delete URLs: %w", err)
+		}
+		delete(s.urls, key)
+	}
+	return nil
+}
+
+// due returns the live links passing keep, least recently checked first
+// and never-checked links before all others
+func (s *Store) due(keep func(*domain.URL) bool, checkedAt func(*domain.URL) *time.Time, limit int) []*domain.URL {
+	now := time.Now()
+	var urls []*domain.URL
+	for _, u := range s.urls {
+		if !isExpired(u, now) && keep(u) {
+			urls = append(urls, u)
+		}
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		a, b := checkedAt(urls[i]), checkedAt(urls[j])
+		switch {
+		case a == nil || b == nil:
+			if a == nil && b == nil {
+				return urls[i].ID < urls[j].ID
+			}
+			return a == nil
+		case !a.Equal(*b):
+			return a.Before(*b)
+		default:
+			return urls[i].ID < urls[j].ID
+		}
+	})
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	for i, u := range urls {
+		urls[i] = copyURL(u)
+	}
+	return urls
+}
+
+func checkedBy(cutoff time.Time, checkedAt *time.Time) bool {
+	return checkedAt != nil && !checkedAt.Before(cutoff)
+}
+
+func (s *Store) ListURLsForRescan(ctx context.Context, checkedBefore time.Time, limit int) ([]*domain.URL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.due(
+		func(u *domain.URL) bool { return !checkedBy(checkedBefore, u.SafetyCheckedAt) },
+		func(u *domain.URL) *time.Time { return u.SafetyCheckedAt },
+		limit,
+	), nil
+}
+
+func (s *Store) UpdateSafetyStatus(ctx context.Context, host, shortCode, status string, checkedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[domain.LinkKey(host, shortCode)]
+	if !ok {
+		return nil
+	}
+	err := s.update(u, func(u *domain.URL) {
+		u.SafetyStatus = status
+		u.SafetyCheckedAt = &checkedAt
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update safety status: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListURLsForHealthCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]*domain.URL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.due(
+		func(u *domain.URL) bool {
+			return !u.Disabled && utils.IsWebURL(u.OriginalURL) && !checkedBy(checkedBefore, u.HealthCheckedAt)
+		},
+		func(u *domain.URL) *time.Time { return u.HealthCheckedAt },
+		limit,
+	), nil
+}
+
+func (s *Store) UpdateLinkHealth(ctx context.Context, host, shortCode string, health *domain.LinkHealth, disable bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[domain.LinkKey(host, shortCode)]
+	if !ok {
+		return nil
+	}
+	err := s.update(u, func(u *domain.URL) {
+		checkedAt := health.CheckedAt
+		u.HealthStatus = health.Status
+		u.HealthLatencyMs = health.LatencyMs
+		u.HealthFinalURL = health.FinalURL
+		u.HealthError = health.Error
+		u.HealthCheckedAt = &checkedAt
+		u.HealthFailures = health.ConsecutiveFailures
+		u.Disabled = u.Disabled || disable
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update link health: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RecordClick(ctx context.Context, analytics *domain.URLAnalytics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	click := *analytics
+	click.ID = uint64(s.lastID[TableClicks] + 1)
+	if err := s.put(TableClicks, clickKey(click.ID), &click); err != nil {
+		return fmt.Errorf("failed to record click: %w", err)
+	}
+	s.clicks = append(s.clicks, &click)
+	s.lastID[TableClicks] = int64(click.ID)
+	return nil
+}
+
+// clickKey pads click IDs so journals that sort keys keep clicks in order
+func clickKey(id uint64) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+func (s *Store) ListPopularURLs(ctx context.Context, since time.Time, order string, limit int) ([]*domain.URL, error) {
+	if order != domain.PopularByClicks && order != domain.PopularByRecent {
+		return nil, fmt.Errorf("unknown popular link order %q", order)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type hot struct {
+		url       *domain.URL
+		clicks    int
+		lastClick time.Time
+	}
+	byKey := make(map[string]*hot)
+	now := time.Now()
+	for _, click := range s.clicks {
+		if !click.ClickedAt.After(since) {
+			continue
+		}
+		key := domain.LinkKey(click.Domain, click.ShortCode)
+		h, ok := byKey[key]
+		if !ok {
+			u, stored := s.urls[key]
+			if !stored || u.Disabled || isExpired(u, now) {
+				continue
+			}
+			h = &hot{url: u}
+			byKey[key] = h
+		}
+		h.clicks++
+		if click.ClickedAt.After(h.lastClick) {
+			h.lastClick = click.ClickedAt
+		}
+	}
+
+	links := make([]*hot, 0, len(byKey))
+	for _, h := range byKey {
+		links = append(links, h)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if order == domain.PopularByClicks && links[i].clicks != links[j].clicks {
+			return links[i].clicks > links[j].clicks
+		}
+		return links[i].lastClick.After(links[j].lastClick)
+	})
+	if len(links) > limit {
+		links = links[:limit]
+	}
+
+	urls := make([]*domain.URL, len(links))
+	for i, h := range links {
+		urls[i] = copyURL(h.url)
+	}
+	return urls, nil
+}
+
+func (s *Store) GetClickCount(ctx context.Context, host, shortCode string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for _, click := range s.clicks {
+		if click.Domain == host && click.ShortCode == shortCode {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) GetDailyStats(ctx context.Context, host, shortCode string, days int) ([]domain.DailyStat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	since := time.Now().AddDate(0, 0, -days)
+	perDay := make(map[string]int64)
+	for _, click := range s.clicks {
+		if click.Domain == host && click.ShortCode == shortCode && !click.ClickedAt.Before(since) {
+			perDay[click.ClickedAt.Format("2006-01-02")]++
+		}
+	}
+
+	stats := make([]domain.DailyStat, 0, len(perDay))
+	for date, clicks := range perDay {
+		stats = append(stats, domain.DailyStat{Date: date, Clicks: clicks})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Date > stats[j].Date })
+	return stats, nil
+}
+
+func (s *Store) GetLastAccessed(ctx context.Context, host, shortCode string) (*time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var last *time.Time
+	for _, click := range s.clicks {
+		if click.Domain == host && click.ShortCode == shortCode && (last == nil || click.ClickedAt.After(*last)) {
+			clickedAt := click.ClickedAt
+			last = &clickedAt
+		}
+	}
+	return last, nil
+}
+
+// IsShortCodeExists checks every workspace: short codes share one namespace
+// per domain so that redirects can find them
+func (s *Store) IsShortCodeExists(ctx context.Context, host, shortCode string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.urls[domain.LinkKey(host, shortCode)]
+	return exists, nil
+}
+
+func (s *Store) CreateDomain(ctx context.Context, d *domain.Domain) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.domains[d.Name]; exists {
+		return fmt.Errorf("failed to insert domain: %q %w", d.Name, domain.ErrConflict)
+	}
+	stored := *d
+	stored.ID = s.lastID[TableDomains] + 1
+	if err := s.put(TableDomains, stored.Name, &stored); err != nil {
+		return fmt.Errorf("failed to insert domain: %w", err)
+	}
+	s.domains[stored.Name] = &stored
+	s.lastID[TableDomains] = stored.ID
+	d.ID = stored.ID
+	return nil
+}
+
+func (s *Store) GetDomain(ctx context.Context, name string) (*domain.Domain, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.domains[name]
+	if !ok || !inScope(ctx, d.WorkspaceID) {
+		return nil, fmt.Errorf("domain %w", domain.ErrNotFound)
+	}
+	found := *d
+	return &found, nil
+}
+
+func (s *Store) ListDomains(ctx context.Context) ([]*domain.Domain, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var domains []*domain.Domain
+	for _, d := range s.domains {
+		if inScope(ctx, d.WorkspaceID) {
+			found := *d
+			domains = append(domains, &found)
+		}
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Name < domains[j].Name })
+	return domains, nil
+}
+
+func (s *Store) CreateWorkspace(ctx context.Context, ws *domain.Workspace, ownerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *ws
+	stored.ID = s.lastID[TableWorkspaces] + 1
+	owner := &domain.Member{WorkspaceID: stored.ID, UserID: ownerID, Role: domain.RoleOwner, CreatedAt: ws.CreatedAt}
+	if err := s.put(TableWorkspaces, strconv.FormatInt(stored.ID, 10), &stored); err != nil {
+		return fmt.Errorf("failed to insert workspace: %w", err)
+	}
+	if err := s.put(TableMembers, memberKey(owner), owner); err != nil {
+		return fmt.Errorf("failed to insert workspace owner: %w", err)
+	}
+	s.workspaces[stored.ID] = &stored
+	s.putMember(owner)
+	s.lastID[TableWorkspaces] = stored.ID
+	ws.ID = stored.ID
+	return nil
+}
+
+func (s *Store) GetWorkspace(ctx context.Context, id int64) (*domain.Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ws, ok := s.workspaces[id]
+	if !ok {
+		return nil, fmt.Errorf("workspace %w", domain.ErrNotFound)
+	}
+	found := *ws
+	return &found, nil
+}
+
+func (s *Store) ListWorkspaces(ctx context.Context, userID string) ([]*domain.Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var workspaces []*domain.Workspace
+	for id, members := range s.members {
+		if _, ok := members[userID]; ok {
+			if ws, ok := s.workspaces[id]; ok {
+				found := *ws
+				workspaces = append(workspaces, &found)
+			}
+		}
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Name < workspaces[j].Name })
+	return workspaces, nil
+}
+
+func (s *Store) CountURLs(ctx context.Context, workspaceID int64) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for _, u := range s.urls {
+		if u.WorkspaceID == workspaceID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func memberKey(m *domain.Member) string {
+	return fmt.Sprintf("%d/%s", m.WorkspaceID, m.UserID)
+}
+
+func (s *Store) putMember(m *domain.Member) {
+	if s.members[m.WorkspaceID] == nil {
+		s.members[m.WorkspaceID] = make(map[string]*domain.Member)
+	}
+	s.members[m.WorkspaceID][m.UserID] = m
+}
+
+func (s *Store) SaveMember(ctx context.Context, m *domain.Member) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *m
+	if existing, ok := s.members[m.WorkspaceID][m.UserID]; ok {
+		stored.CreatedAt = existing.CreatedAt // Only the role changes
+	}
+	if err := s.put(TableMembers, memberKey(&stored), &stored); err != nil {
+		return fmt.Errorf("failed to save member: %w", err)
+	}
+	s.putMember(&stored)
+	return nil
+}
+
+func (s *Store) GetMember(ctx context.Context, workspaceID int64, userID string) (*domain.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.members[workspaceID][userID]
+	if !ok {
+		return nil, fmt.Errorf("member %w", domain.ErrNotFound)
+	}
+	found := *m
+	return &found, nil
+}
+
+func (s *Store) ListMembers(ctx context.Context, workspaceID int64) ([]*domain.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var members []*domain.Member
+	for _, m := range s.members[workspaceID] {
+		found := *m
+		members = append(members, &found)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].CreatedAt.Equal(members[j].CreatedAt) {
+			return members[i].CreatedAt.Before(members[j].CreatedAt)
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *Store) RemoveMember(ctx context.Context, workspaceID int64, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.members[workspaceID][userID]
+	if !ok {
+		return fmt.Errorf("member %w", domain.ErrNotFound)
+	}
+	if err := s.delete(TableMembers, memberKey(m)); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	delete(s.members[workspaceID], userID)
+	return nil
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.apiKeys[key.KeyHash]; exists {
+		return fmt.Errorf("failed to insert API key: key %w", domain.ErrConflict)
+	}
+	stored := *key
+	stored.ID = s.lastID[TableAPIKeys] + 1
+	stored.Key = "" // Only the hash is kept
+	if err := s.put(TableAPIKeys, stored.KeyHash, &stored); err != nil {
+		return fmt.Errorf("failed to insert API key: %w", err)
+	}
+	s.apiKeys[stored.KeyHash] = &stored
+	s.lastID[TableAPIKeys] = stored.ID
+	key.ID = stored.ID
+	return nil
+}
+
+func (s *Store) GetAPIKey(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.apiKeys[keyHash]
+	if !ok {
+		return nil, fmt.Errorf("API key %w", domain.ErrNotFound)
+	}
+	found := *key
+	return &found, nil
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context, workspaceID int64) ([]*domain.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []*domain.APIKey
+	for _, key := range s.apiKeys {
+		if key.WorkspaceID == workspaceID {
+			found := *key
+			keys = append(keys, &found)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (s *Store) DeleteAPIKey(ctx context.Context, workspaceID, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, key := range s.apiKeys {
+		if key.WorkspaceID != workspaceID || key.ID != id {
+			continue
+		}
+		if err := s.delete(TableAPIKeys, hash); err != nil {
+			return fmt.Errorf("failed to delete API key: %w", err)
+		}
+		delete(s.apiKeys, hash)
+		return nil
+	}
+	return fmt.Errorf("API key %w", domain.ErrNotFound)
+}
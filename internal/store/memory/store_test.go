@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/storetest"
+)
+
+func TestStore_URLs(t *testing.T) {
+	s := NewStore(nil)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+
+	link := &domain.URL{ShortCode: "abc123", OriginalURL: "https://example.com", CreatedAt: time.Now()}
+	require.NoError(t, s.CreateURL(ctx, link))
+	assert.Equal(t, int64(1), link.ID)
+	assert.Error(t, s.CreateURL(ctx, &domain.URL{ShortCode: "abc123"}), "short codes are unique per domain")
+	require.NoError(t, s.CreateURL(ctx, &domain.URL{Domain: "go.brand.com", ShortCode: "abc123", OriginalURL: "https://example.com/brand"}))
+	require.NoError(t, s.CreateURL(ctx, &domain.URL{ShortCode: "old", OriginalURL: "https://example.com/old", ExpiresAt: &past}))
+
+	got, err := s.GetURLByShortCode(ctx, "go.brand.com", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/brand", got.OriginalURL)
+
+	got, err = s.GetURLByOriginalURL(ctx, "", "https://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", got.ShortCode)
+
+	got.OriginalURL = "https://changed.example.com" // Callers get copies
+	require.NoError(t, s.UpdateClickCount(ctx, "", "abc123"))
+	got, err = s.GetURLByShortCode(ctx, "", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", got.OriginalURL)
+	assert.Equal(t, int64(1), got.ClickCount)
+	assert.NotNil(t, got.LastAccess)
+
+	require.NoError(t, s.DeleteExpiredURLs(ctx))
+	_, err = s.GetURLByShortCode(ctx, "", "old")
+	assert.EqualError(t, err, "URL not found")
+	assert.EqualError(t, s.UpdateClickCount(ctx, "", "old"), "URL not found")
+}
+
+func TestStore_WorkspaceScope(t *testing.T) {
+	s := NewStore(nil)
+	ctx := context.Background()
+	require.NoError(t, s.CreateURL(ctx, &domain.URL{WorkspaceID: 1, ShortCode: "team", OriginalURL: "https://example.com"}))
+	require.NoError(t, s.CreateDomain(ctx, &domain.Domain{WorkspaceID: 1, Name: "go.team.com"}))
+
+	other := domain.WithWorkspace(ctx, 2)
+	_, err := s.GetURLByShortCode(other, "", "team")
+	assert.Error(t, err)
+	_, err = s.GetDomain(other, "go.team.com")
+	assert.Error(t, err)
+	exists, err := s.IsShortCodeExists(other, "", "team")
+	require.NoError(t, err)
+	assert.True(t, exists, "short codes are checked across workspaces")
+
+	_, err = s.GetURLByShortCode(domain.WithWorkspace(ctx, 1), "", "team")
+	assert.NoError(t, err)
+	_, err = s.GetURLByShortCode(domain.WithoutWorkspace(other), "", "team")
+	assert.NoError(t, err)
+
+	count, err := s.CountURLs(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestStore_Analytics(t *testing.T) {
+	s := NewStore(nil)
+	ctx := context.Background()
+	require.NoError(t, s.CreateURL(ctx, &domain.URL{ShortCode: "ab", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+
+	now := time.Now()
+	for _, click := range []*domain.URLAnalytics{
+		{ShortCode: "ab", ClickedAt: now, Variant: "a"},
+		{ShortCode: "ab", ClickedAt: now, Variant: "b"},
+		{ShortCode: "ab", ClickedAt: now.AddDate(0, 0, -1), Variant: "a"},
+		{ShortCode: "ab", ClickedAt: now.AddDate(0, 0, -40)},
+		{Domain: "go.brand.com", ShortCode: "ab", ClickedAt: now},
+	} {
+		require.NoError(t, s.RecordClick(ctx, click))
+	}
+
+	count, err := s.GetClickCount(ctx, "", "ab")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	last, err := s.GetLastAccessed(ctx, "", "ab")
+	require.NoError(t, err)
+	assert.True(t, last.Equal(now))
+
+	analytics, err := s.GetAnalytics(ctx, "", "ab", 30)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.DailyStat{
+		{Date: now.Format("2006-01-02"), Clicks: 2},
+		{Date: now.AddDate(0, 0, -1).Format("2006-01-02"), Clicks: 1},
+	}, analytics.DailyStats)
+	assert.Equal(t, []domain.VariantStat{{Variant: "a", Clicks: 2}, {Variant: "b", Clicks: 1}}, analytics.VariantStats)
+}
+
+func TestStore_HealthCheckQueue(t *testing.T) {
+	s := NewStore(nil)
+	ctx := context.Background()
+	checked := time.Now().Add(-2 * time.Hour)
+	for _, u := range []*domain.URL{
+		{ShortCode: "checked", OriginalURL: "https://example.com/a", HealthCheckedAt: &checked},
+		{ShortCode: "fresh", OriginalURL: "https://example.com/b"},
+		{ShortCode: "off", Disabled: true},
+		{ShortCode: "app", OriginalURL: "myapp://product/42"},
+	} {
+		require.NoError(t, s.CreateURL(ctx, u))
+	}
+
+	due, err := s.ListURLsForHealthCheck(ctx, time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2, "disabled links and deep links are not checked")
+	assert.Equal(t, "fresh", due[0].ShortCode, "never-checked links come first")
+	assert.Equal(t, "checked", due[1].ShortCode)
+
+	health := &domain.LinkHealth{Status: 404, CheckedAt: time.Now(), ConsecutiveFailures: 3}
+	require.NoError(t, s.UpdateLinkHealth(ctx, "", "fresh", health, true))
+	due, err = s.ListURLsForHealthCheck(ctx, time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "checked", due[0].ShortCode)
+}
+
+func TestStore_Workspaces(t *testing.T) {
+	s := NewStore(nil)
+	ctx := context.Background()
+
+	ws := &domain.Workspace{Name: "Marketing", CreatedAt: time.Now()}
+	require.NoError(t, s.CreateWorkspace(ctx, ws, "alice"))
+	owner, err := s.GetMember(ctx, ws.ID, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleOwner, owner.Role)
+
+	require.NoError(t, s.SaveMember(ctx, &domain.Member{WorkspaceID: ws.ID, UserID: "alice", Role: domain.RoleEditor, CreatedAt: time.Now().Add(time.Hour)}))
+	member, err := s.GetMember(ctx, ws.ID, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleEditor, member.Role)
+	assert.True(t, member.CreatedAt.Equal(owner.CreatedAt), "saving a member only changes the role")
+
+	key := &domain.APIKey{WorkspaceID: ws.ID, KeyHash: "hash", Role: domain.RoleViewer, Key: "usk_secret"}
+	require.NoError(t, s.CreateAPIKey(ctx, key))
+	found, err := s.GetAPIKey(ctx, "hash")
+	require.NoError(t, err)
+	assert.Empty(t, found.Key, "secrets are not stored")
+	assert.Error(t, s.DeleteAPIKey(ctx, ws.ID+1, key.ID), "keys are revoked within their workspace")
+	require.NoError(t, s.DeleteAPIKey(ctx, ws.ID, key.ID))
+	_, err = s.GetAPIKey(ctx, "hash")
+	assert.Error(t, err)
+
+	require.NoError(t, s.RemoveMember(ctx, ws.ID, "alice"))
+	assert.EqualError(t, s.RemoveMember(ctx, ws.ID, "alice"), "member not found")
+}
+
+func TestStore_ConcurrentClicks(t *testing.T) {
+	s := NewStore(nil)
+	ctx := context.Background()
+	require.NoError(t, s.CreateURL(ctx, &domain.URL{ShortCode: "hot"}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.UpdateClickCount(ctx, "", "hot"))
+			assert.NoError(t, s.RecordClick(ctx, &domain.URLAnalytics{ShortCode: "hot", ClickedAt: time.Now()}))
+		}()
+	}
+	wg.Wait()
+
+	got, err := s.GetURLByShortCode(ctx, "", "hot")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), got.ClickCount)
+	count, err := s.GetClickCount(ctx, "", "hot")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), count)
+}
+
+func TestStore_Contract(t *testing.T) {
+	storetest.TestURLRepository(t, func(t *testing.T) domain.URLRepository { return NewStore(nil) })
+	storetest.TestAnalyticsRepository(t, func(t *testing.T) domain.AnalyticsRepository { return NewStore(nil) })
+}
@@ -18,29 +18,29 @@ func (m *MockURLRepository) CreateURL(ctx context.Context, url *domain.URL) erro
 	return args.Error(0)
 }
 
-func (m *MockURLRepository) GetURLByShortCode(ctx context.Context, shortCode string) (*domain.URL, error) {
-	args := m.Called(ctx, shortCode)
+func (m *MockURLRepository) GetURLByShortCode(ctx context.Context, host, shortCode string) (*domain.URL, error) {
+	args := m.Called(ctx, host, shortCode)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.URL), args.Error(1)
 }
 
-func (m *MockURLRepository) GetURLByOriginalURL(ctx context.Context, originalURL string) (*domain.URL, error) {
-	args := m.Called(ctx, originalURL)
+func (m *MockURLRepository) GetURLByOriginalURL(ctx context.Context, host, originalURL string) (*domain.URL, error) {
+	args := m.Called(ctx, host, originalURL)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.URL), args.Error(1)
 }
 
-func (m *MockURLRepository) UpdateClickCount(ctx context.Context, shortCode string) error {
-	args := m.Called(ctx, shortCode)
+func (m *MockURLRepository) UpdateClickCount(ctx context.Context, host, shortCode string) error {
+	args := m.Called(ctx, host, shortCode)
 	return args.Error(0)
 }
 
-func (m *MockURLRepository) GetAnalytics(ctx context.Context, shortCode string, days int) (*domain.AnalyticsResponse, error) {
-	args := m.Called(ctx, shortCode, days)
+func (m *MockURLRepository) GetAnalytics(ctx context.Context, host, shortCode string, days int) (*domain.AnalyticsResponse, error) {
+	args := m.Called(ctx, host, shortCode, days)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -56,8 +56,8 @@ func (m *MockURLRepository) HealthCheck(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
 }
-func (m *MockURLRepository) IsShortCodeExists(ctx context.Context, shortCode string) (bool, error) {
-	args := m.Called(ctx, shortCode)
+func (m *MockURLRepository) IsShortCodeExists(ctx context.Context, host, shortCode string) (bool, error) {
+	args := m.Called(ctx, host, shortCode)
 	return args.Bool(0), args.Error(1)
 }
 
@@ -85,6 +85,11 @@ func (m *MockCacheRepository) Increment(ctx context.Context, key string, value i
 	return args.Error(0)
 }
 
+func (m *MockCacheRepository) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	args := m.Called(ctx, key, ttl)
+	return args.Error(0)
+}
+
 func (m *MockCacheRepository) HealthCheck(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -99,3 +104,225 @@ func (m *MockCacheRepository) GetCounter(ctx context.Context, key string) (int64
 	args := m.Called(ctx, key)
 	return args.Get(0).(int64), args.Error(1)
 }
+
+type MockAnalyticsRepository struct {
+	mock.Mock
+}
+
+func (m *MockAnalyticsRepository) RecordClick(ctx context.Context, analytics *domain.URLAnalytics) error {
+	args := m.Called(ctx, analytics)
+	return args.Error(0)
+}
+
+func (m *MockAnalyticsRepository) GetClickCount(ctx context.Context, host, shortCode string) (int64, error) {
+	args := m.Called(ctx, host, shortCode)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAnalyticsRepository) GetDailyStats(ctx context.Context, host, shortCode string, days int) ([]domain.DailyStat, error) {
+	args := m.Called(ctx, host, shortCode, days)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.DailyStat), args.Error(1)
+}
+
+func (m *MockAnalyticsRepository) GetLastAccessed(ctx context.Context, host, shortCode string) (*time.Time, error) {
+	args := m.Called(ctx, host, shortCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+type MockGeoLocator struct {
+	mock.Mock
+}
+
+func (m *MockGeoLocator) Country(ipAddress string) (string, error) {
+	args := m.Called(ipAddress)
+	return args.String(0), args.Error(1)
+}
+
+type MockURLScanner struct {
+	mock.Mock
+}
+
+func (m *MockURLScanner) Scan(ctx context.Context, rawURL string) (*domain.ScanResult, error) {
+	args := m.Called(ctx, rawURL)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ScanResult), args.Error(1)
+}
+
+type MockSafetyRepository struct {
+	mock.Mock
+}
+
+func (m *MockSafetyRepository) ListURLsForRescan(ctx context.Context, checkedBefore time.Time, limit int) ([]*domain.URL, error) {
+	args := m.Called(ctx, checkedBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.URL), args.Error(1)
+}
+
+func (m *MockSafetyRepository) UpdateSafetyStatus(ctx context.Context, host, shortCode, status string, checkedAt time.Time) error {
+	args := m.Called(ctx, host, shortCode, status, checkedAt)
+	return args.Error(0)
+}
+
+type MockDestinationPolicy struct {
+	mock.Mock
+}
+
+func (m *MockDestinationPolicy) Allow(ctx context.Context, rawURL string) error {
+	args := m.Called(ctx, rawURL)
+	return args.Error(0)
+}
+
+type MockLinkHealthRepository struct {
+	mock.Mock
+}
+
+func (m *MockLinkHealthRepository) ListURLsForHealthCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]*domain.URL, error) {
+	args := m.Called(ctx, checkedBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.URL), args.Error(1)
+}
+
+func (m *MockLinkHealthRepository) UpdateLinkHealth(ctx context.Context, host, shortCode string, health *domain.LinkHealth, disable bool) error {
+	args := m.Called(ctx, host, shortCode, health, disable)
+	return args.Error(0)
+}
+
+type MockMetadataFetcher struct {
+	mock.Mock
+}
+
+func (m *MockMetadataFetcher) FetchOpenGraph(ctx context.Context, rawURL string) (*domain.OpenGraph, error) {
+	args := m.Called(ctx, rawURL)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OpenGraph), args.Error(1)
+}
+
+type MockDomainRepository struct {
+	mock.Mock
+}
+
+func (m *MockDomainRepository) CreateDomain(ctx context.Context, d *domain.Domain) error {
+	args := m.Called(ctx, d)
+	return args.Error(0)
+}
+
+func (m *MockDomainRepository) GetDomain(ctx context.Context, name string) (*domain.Domain, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Domain), args.Error(1)
+}
+
+func (m *MockDomainRepository) ListDomains(ctx context.Context) ([]*domain.Domain, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Domain), args.Error(1)
+}
+
+type MockWorkspaceRepository struct {
+	mock.Mock
+}
+
+func (m *MockWorkspaceRepository) CreateWorkspace(ctx context.Context, ws *domain.Workspace, ownerID string) error {
+	args := m.Called(ctx, ws, ownerID)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) GetWorkspace(ctx context.Context, id int64) (*domain.Workspace, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Workspace), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) ListWorkspaces(ctx context.Context, userID string) ([]*domain.Workspace, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Workspace), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) CountURLs(ctx context.Context, workspaceID int64) (int64, error) {
+	args := m.Called(ctx, workspaceID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) SaveMember(ctx context.Context, member *domain.Member) error {
+	args := m.Called(ctx, member)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) GetMember(ctx context.Context, workspaceID int64, userID string) (*domain.Member, error) {
+	args := m.Called(ctx, workspaceID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Member), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) ListMembers(ctx context.Context, workspaceID int64) ([]*domain.Member, error) {
+	args := m.Called(ctx, workspaceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Member), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) RemoveMember(ctx context.Context, workspaceID int64, userID string) error {
+	args := m.Called(ctx, workspaceID, userID)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) GetAPIKey(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) ListAPIKeys(ctx context.Context, workspaceID int64) ([]*domain.APIKey, error) {
+	args := m.Called(ctx, workspaceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.APIKey), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) DeleteAPIKey(ctx context.Context, workspaceID, id int64) error {
+	args := m.Called(ctx, workspaceID, id)
+	return args.Error(0)
+}
+
+type MockLinkPublisher struct {
+	mock.Mock
+}
+
+func (m *MockLinkPublisher) PublishLinkChange(ctx context.Context, change *domain.LinkChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
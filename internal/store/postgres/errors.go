@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// uniqueViolation is the SQLSTATE of an insert that breaks a unique index,
+// see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const uniqueViolation = "23505"
+
+// unavailableCodes are the SQLSTATE classes and codes of a server that cannot
+// take queries right now: connection failures, exhausted resources and
+// shutdowns
+var unavailableCodes = map[string]bool{
+	"08":    true, // connection_exception
+	"53":    true, // insufficient_resources
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// storeError marks err with the domain error it stands for, so callers can
+// branch on domain.ErrConflict and domain.ErrUnavailable without knowing
+// about pq. Other errors are returned unchanged.
+func storeError(err error) error {
+	var pqErr *pq.Error
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &pqErr):
+		if pqErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: %w", domain.ErrConflict, err)
+		}
+		if unavailableCodes[string(pqErr.Code.Class())] || unavailableCodes[string(pqErr.Code)] {
+			return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+		}
+		return err
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+	}
+	return err
+}
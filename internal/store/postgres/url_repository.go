@@ -12,6 +12,13 @@ import (
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
 )
 
+// urlColumns lists the urls columns scanned into domain.URL
+const urlColumns = `id, workspace_id, domain, short_code, original_url, click_count, created_at, expires_at, last_access,
+	activates_at, coming_soon_url, redirect_status, forward_query, forward_path, geo_rules,
+	device_rules, variants, variant_mode, interstitial, safety_status, safety_checked_at,
+	health_status, health_latency_ms, health_final_url, health_error, health_checked_at, health_failures, disabled,
+	og_title, og_description, og_image`
+
 type URLRepository struct {
 	db *sqlx.DB
 }
@@ -19,7 +26,7 @@ type URLRepository struct {
 func NewURLRepository(databaseURL string) (*URLRepository, error) {
 	db, err := sqlx.Connect("postgres", databaseURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", storeError(err))
 	}
 
 	// Configure connection pool
@@ -31,7 +38,7 @@ func NewURLRepository(databaseURL string) (*URLRepository, error) {
 
 	// Run migrations
 	if err := repo.migrate(); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+		return nil, fmt.Errorf("failed to run migrations: %w", storeError(err))
 	}
 
 	return repo, nil
@@ -49,9 +56,77 @@ func (r *URLRepository) migrate() error {
 		last_access TIMESTAMP WITH TIME ZONE
 	);
 
+	-- Short codes are unique per domain; '' is the default domain
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+	ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_short_code_key;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_domain_short_code ON urls(domain, short_code);
+
+	CREATE TABLE IF NOT EXISTS domains (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(253) UNIQUE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	-- Workspaces own links and domains; 0 holds those created without one
+	CREATE TABLE IF NOT EXISTS workspaces (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		max_links BIGINT NOT NULL DEFAULT 0,
+		max_api_calls BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+	CREATE TABLE IF NOT EXISTS workspace_members (
+		workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+		user_id VARCHAR(255) NOT NULL,
+		role VARCHAR(16) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		PRIMARY KEY (workspace_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		prefix VARCHAR(16) NOT NULL,
+		key_hash CHAR(64) UNIQUE NOT NULL,
+		role VARCHAR(16) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS workspace_id BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE domains ADD COLUMN IF NOT EXISTS workspace_id BIGINT NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_urls_workspace_id ON urls(workspace_id);
+
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS activates_at TIMESTAMP WITH TIME ZONE;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS coming_soon_url TEXT NOT NULL DEFAULT '';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_status SMALLINT NOT NULL DEFAULT 0;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS forward_query BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS forward_path BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS geo_rules JSONB NOT NULL DEFAULT '[]';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS device_rules JSONB NOT NULL DEFAULT '[]';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '[]';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS variant_mode VARCHAR(16) NOT NULL DEFAULT '';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS safety_status VARCHAR(16) NOT NULL DEFAULT '';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS safety_checked_at TIMESTAMP WITH TIME ZONE;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_status SMALLINT NOT NULL DEFAULT 0;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_latency_ms INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_final_url TEXT NOT NULL DEFAULT '';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_error TEXT NOT NULL DEFAULT '';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_checked_at TIMESTAMP WITH TIME ZONE;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_failures INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS og_title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS og_description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE urls ADD COLUMN IF NOT EXISTS og_image TEXT NOT NULL DEFAULT '';
+	-- Columns first created without a time zone, before 014_timestamptz.sql
+	ALTER TABLE urls ALTER COLUMN safety_checked_at TYPE TIMESTAMP WITH TIME ZONE;
+	ALTER TABLE urls ALTER COLUMN health_checked_at TYPE TIMESTAMP WITH TIME ZONE;
+
 	CREATE INDEX IF NOT EXISTS idx_urls_short_code ON urls(short_code);
 	CREATE INDEX IF NOT EXISTS idx_urls_original_url ON urls(original_url);
 	CREATE INDEX IF NOT EXISTS idx_urls_expires_at ON urls(expires_at) WHERE expires_at IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_urls_safety_checked_at ON urls(safety_checked_at NULLS FIRST);
+	CREATE INDEX IF NOT EXISTS idx_urls_health_checked_at ON urls(health_checked_at NULLS FIRST) WHERE NOT disabled;
 
 	CREATE TABLE IF NOT EXISTS url_analytics (
 		id SERIAL PRIMARY KEY,
@@ -62,7 +137,13 @@ func (r *URLRepository) migrate() error {
 		referrer TEXT
 	);
 
+	ALTER TABLE url_analytics ADD COLUMN IF NOT EXISTS referer TEXT;
+	ALTER TABLE url_analytics ADD COLUMN IF NOT EXISTS country VARCHAR(10);
+	ALTER TABLE url_analytics ADD COLUMN IF NOT EXISTS variant VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE url_analytics ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+
 	CREATE INDEX IF NOT EXISTS idx_analytics_short_code ON url_analytics(short_code);
+	CREATE INDEX IF NOT EXISTS idx_analytics_domain_short_code ON url_analytics(domain, short_code);
 	CREATE INDEX IF NOT EXISTS idx_analytics_clicked_at ON url_analytics(clicked_at);
 	`
 
@@ -72,91 +153,101 @@ func (r *URLRepository) migrate() error {
 
 func (r *URLRepository) CreateURL(ctx context.Context, url *domain.URL) error {
 	query := `
-	INSERT INTO urls (short_code, original_url, created_at, expires_at)
-	VALUES (:short_code, :original_url, :created_at, :expires_at)
+	INSERT INTO urls (workspace_id, domain, short_code, original_url, created_at, expires_at, activates_at, coming_soon_url, redirect_status,
+		forward_query, forward_path, geo_rules, device_rules, variants, variant_mode, interstitial,
+		safety_status, safety_checked_at, og_title, og_description, og_image)
+	VALUES (:workspace_id, :domain, :short_code, :original_url, :created_at, :expires_at, :activates_at, :coming_soon_url, :redirect_status,
+		:forward_query, :forward_path, :geo_rules, :device_rules, :variants, :variant_mode, :interstitial,
+		:safety_status, :safety_checked_at, :og_title, :og_description, :og_image)
+	ON CONFLICT (domain, short_code) DO NOTHING
 	RETURNING id
 	`
 
 	rows, err := r.db.NamedQueryContext(ctx, query, url)
 	if err != nil {
-		return fmt.Errorf("failed to insert URL: %w", err)
+		return fmt.Errorf("failed to insert URL: %w", storeError(err))
 	}
 	defer rows.Close()
 
 	if rows.Next() {
-		return rows.Scan(&url.ID)
+		return storeError(rows.Scan(&url.ID))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to insert URL: %w", storeError(err))
 	}
 
-	return fmt.Errorf("failed to get inserted ID")
+	// No row was inserted: the short code is taken on this domain
+	return fmt.Errorf("failed to insert URL: short code %q %w", url.ShortCode, domain.ErrConflict)
 }
 
-func (r *URLRepository) GetURLByShortCode(ctx context.Context, shortCode string) (*domain.URL, error) {
+func (r *URLRepository) GetURLByShortCode(ctx context.Context, host, shortCode string) (*domain.URL, error) {
 	var url domain.URL
+	where, args := scoped(ctx, "domain = $1 AND short_code = $2", host, shortCode)
 	query := `
-	SELECT id, short_code, original_url, click_count, created_at, expires_at, last_access
+	SELECT ` + urlColumns + `
 	FROM urls
-	WHERE short_code = $1
-	`
+	WHERE ` + where
 
-	err := r.db.GetContext(ctx, &url, query, shortCode)
+	err := r.db.GetContext(ctx, &url, query, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("URL not found")
+			return nil, fmt.Errorf("URL %w", domain.ErrNotFound)
 		}
-		return nil, fmt.Errorf("failed to get URL: %w", err)
+		return nil, fmt.Errorf("failed to get URL: %w", storeError(err))
 	}
 
 	return &url, nil
 }
 
-func (r *URLRepository) GetURLByOriginalURL(ctx context.Context, originalURL string) (*domain.URL, error) {
+func (r *URLRepository) GetURLByOriginalURL(ctx context.Context, host, originalURL string) (*domain.URL, error) {
 	var url domain.URL
+	where, args := scoped(ctx, "domain = $1 AND original_url = $2", host, originalURL)
 	query := `
-	SELECT id, short_code, original_url, click_count, created_at, expires_at, last_access
+	SELECT ` + urlColumns + `
 	FROM urls
-	WHERE original_url = $1
+	WHERE ` + where + `
 	ORDER BY created_at DESC
 	LIMIT 1
 	`
 
-	err := r.db.GetContext(ctx, &url, query, originalURL)
+	err := r.db.GetContext(ctx, &url, query, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("URL not found")
+			return nil, fmt.Errorf("URL %w", domain.ErrNotFound)
 		}
-		return nil, fmt.Errorf("failed to get URL: %w", err)
+		return nil, fmt.Errorf("failed to get URL: %w", storeError(err))
 	}
 
 	return &url, nil
 }
 
-func (r *URLRepository) UpdateClickCount(ctx context.Context, shortCode string) error {
+func (r *URLRepository) UpdateClickCount(ctx context.Context, host, shortCode string) error {
+	where, args := scoped(ctx, "domain = $1 AND short_code = $2", host, shortCode)
 	query := `
 	UPDATE urls
 	SET click_count = click_count + 1, last_access = NOW()
-	WHERE short_code = $1
-	`
+	WHERE ` + where
 
-	result, err := r.db.ExecContext(ctx, query, shortCode)
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to update click count: %w", err)
+		return fmt.Errorf("failed to update click count: %w", storeError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", storeError(err))
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("URL not found")
+		return fmt.Errorf("URL %w", domain.ErrNotFound)
 	}
 
 	return nil
 }
 
-func (r *URLRepository) GetAnalytics(ctx context.Context, shortCode string, days int) (*domain.AnalyticsResponse, error) {
-	// Get basic URL info
-	url, err := r.GetURLByShortCode(ctx, shortCode)
+func (r *URLRepository) GetAnalytics(ctx context.Context, host, shortCode string, days int) (*domain.AnalyticsResponse, error) {
+	// Get basic URL info; this also keeps other workspaces' links out of reach
+	url, err := r.GetURLByShortCode(ctx, host, shortCode)
 	if err != nil {
 		return nil, err
 	}
@@ -165,33 +256,49 @@ func (r *URLRepository) GetAnalytics(ctx context.Context, shortCode string, days
 	dailyQuery := `
 SELECT DATE(clicked_at) as date, COUNT(*) as clicks
 FROM url_analytics
-WHERE short_code = $1 AND clicked_at >= NOW() - ($2 * INTERVAL '1 day')
+WHERE domain = $1 AND short_code = $2 AND clicked_at >= NOW() - ($3 * INTERVAL '1 day')
 GROUP BY DATE(clicked_at)
 ORDER BY date DESC
 `
 	var dailyStats []domain.DailyStat
-	err = r.db.SelectContext(ctx, &dailyStats, dailyQuery, shortCode, days) // Pass days as parameter to prevent SQL injection
+	err = r.db.SelectContext(ctx, &dailyStats, dailyQuery, host, shortCode, days) // Pass days as parameter to prevent SQL injection
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get daily stats: %w", err)
+		return nil, fmt.Errorf("failed to get daily stats: %w", storeError(err))
+	}
+
+	// Get clicks per A/B variant
+	variantQuery := `
+SELECT variant, COUNT(*) as clicks
+FROM url_analytics
+WHERE domain = $1 AND short_code = $2 AND variant <> '' AND clicked_at >= NOW() - ($3 * INTERVAL '1 day')
+GROUP BY variant
+ORDER BY clicks DESC
+`
+	var variantStats []domain.VariantStat
+	if err := r.db.SelectContext(ctx, &variantStats, variantQuery, host, shortCode, days); err != nil {
+		return nil, fmt.Errorf("failed to get variant stats: %w", storeError(err))
 	}
 
 	return &domain.AnalyticsResponse{
 		ShortCode:    url.ShortCode,
+		Domain:       url.Domain,
 		OriginalURL:  url.OriginalURL,
 		ClickCount:   url.ClickCount,
 		CreatedAt:    url.CreatedAt,
 		LastAccessed: url.LastAccess,
 		DailyStats:   dailyStats,
+		VariantStats: variantStats,
 	}, nil
 }
 
 func (r *URLRepository) DeleteExpiredURLs(ctx context.Context) error {
-	query := `DELETE FROM urls WHERE expires_at IS NOT NULL AND expires_at < NOW()`
+	where, args := scoped(ctx, "expires_at IS NOT NULL AND expires_at < NOW()")
+	query := `DELETE FROM urls WHERE ` + where
 
-	result, err := r.db.ExecContext(ctx, query)
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete expired URLs: %w", err)
+		return fmt.Errorf("failed to delete expired URLs: %w", storeError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -204,7 +311,7 @@ func (r *URLRepository) DeleteExpiredURLs(ctx context.Context) error {
 }
 
 func (r *URLRepository) HealthCheck(ctx context.Context) error {
-	return r.db.PingContext(ctx)
+	return storeError(r.db.PingContext(ctx))
 }
 
 func (r *URLRepository) Close() error {
@@ -216,7 +323,7 @@ func (r *URLRepository) Cleanup(ctx context.Context) error {
 
 	result, err := r.db.ExecContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("failed to delete expired URLs: %w", err)
+		return fmt.Errorf("failed to delete expired URLs: %w", storeError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -228,58 +335,208 @@ func (r *URLRepository) Cleanup(ctx context.Context) error {
 	return nil
 }
 
+// ListLinkKeys streams the key of every link, so callers need not hold them
+// all in memory
+func (r *URLRepository) ListLinkKeys(ctx context.Context, fn func(key string) error) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT domain, short_code FROM urls`)
+	if err != nil {
+		return fmt.Errorf("failed to list link keys: %w", storeError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var host, shortCode string
+		if err := rows.Scan(&host, &shortCode); err != nil {
+			return fmt.Errorf("failed to scan link key: %w", storeError(err))
+		}
+		if err := fn(domain.LinkKey(host, shortCode)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list link keys: %w", storeError(err))
+	}
+	return nil
+}
+
+// popularOrders are the ORDER BY clauses of ListPopularURLs
+var popularOrders = map[string]string{
+	domain.PopularByClicks: "hot.clicks DESC, hot.last_click DESC",
+	domain.PopularByRecent: "hot.last_click DESC",
+}
+
+func (r *URLRepository) ListPopularURLs(ctx context.Context, since time.Time, order string, limit int) ([]*domain.URL, error) {
+	orderBy, ok := popularOrders[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown popular link order %q", order)
+	}
+	query := `
+	SELECT ` + urlColumns + `
+	FROM urls
+	JOIN (
+		SELECT domain, short_code, COUNT(*) AS clicks, MAX(clicked_at) AS last_click
+		FROM url_analytics
+		WHERE clicked_at > $1
+		GROUP BY domain, short_code
+	) hot USING (domain, short_code)
+	WHERE NOT disabled
+		AND (expires_at IS NULL OR expires_at > NOW())
+	ORDER BY ` + orderBy + `
+	LIMIT $2
+	`
+
+	var urls []*domain.URL
+	if err := r.db.SelectContext(ctx, &urls, query, since, limit); err != nil {
+		return nil, fmt.Errorf("failed to list popular URLs: %w", storeError(err))
+	}
+	return urls, nil
+}
+
+func (r *URLRepository) ListURLsForRescan(ctx context.Context, checkedBefore time.Time, limit int) ([]*domain.URL, error) {
+	query := `
+	SELECT ` + urlColumns + `
+	FROM urls
+	WHERE (safety_checked_at IS NULL OR safety_checked_at < $1)
+		AND (expires_at IS NULL OR expires_at > NOW())
+	ORDER BY safety_checked_at NULLS FIRST
+	LIMIT $2
+	`
+
+	var urls []*domain.URL
+	if err := r.db.SelectContext(ctx, &urls, query, checkedBefore, limit); err != nil {
+		return nil, fmt.Errorf("failed to list URLs for rescan: %w", storeError(err))
+	}
+	return urls, nil
+}
+
+func (r *URLRepository) UpdateSafetyStatus(ctx context.Context, host, shortCode, status string, checkedAt time.Time) error {
+	query := `UPDATE urls SET safety_status = $3, safety_checked_at = $4 WHERE domain = $1 AND short_code = $2`
+	if _, err := r.db.ExecContext(ctx, query, host, shortCode, status, checkedAt); err != nil {
+		return fmt.Errorf("failed to update safety status: %w", storeError(err))
+	}
+	return nil
+}
+
+func (r *URLRepository) ListURLsForHealthCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]*domain.URL, error) {
+	query := `
+	SELECT ` + urlColumns + `
+	FROM urls
+	WHERE NOT disabled
+		AND original_url ~* '^https?://'
+		AND (health_checked_at IS NULL OR health_checked_at < $1)
+		AND (expires_at IS NULL OR expires_at > NOW())
+	ORDER BY health_checked_at NULLS FIRST
+	LIMIT $2
+	`
+
+	var urls []*domain.URL
+	if err := r.db.SelectContext(ctx, &urls, query, checkedBefore, limit); err != nil {
+		return nil, fmt.Errorf("failed to list URLs for health check: %w", storeError(err))
+	}
+	return urls, nil
+}
+
+func (r *URLRepository) UpdateLinkHealth(ctx context.Context, host, shortCode string, health *domain.LinkHealth, disable bool) error {
+	query := `
+	UPDATE urls
+	SET health_status = $3, health_latency_ms = $4, health_final_url = $5, health_error = $6,
+		health_checked_at = $7, health_failures = $8, disabled = disabled OR $9
+	WHERE domain = $1 AND short_code = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, host, shortCode, health.Status, health.LatencyMs, health.FinalURL,
+		health.Error, health.CheckedAt, health.ConsecutiveFailures, disable)
+	if err != nil {
+		return fmt.Errorf("failed to update link health: %w", storeError(err))
+	}
+	return nil
+}
+
 func (r *URLRepository) RecordClick(ctx context.Context, analytics *domain.URLAnalytics) error {
 	query := `
-		INSERT INTO url_analytics (short_code, clicked_at, user_agent, ip_address, referer, country)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO url_analytics (short_code, clicked_at, user_agent, ip_address, referer, country, variant, domain)
+		VALUES ($1, $2, $3, NULLIF($4, '')::inet, $5, NULLIF($6, ''), $7, $8)
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		analytics.ShortCode, analytics.ClickedAt, analytics.UserAgent,
-		analytics.IPAddress, analytics.Referer, analytics.Country)
-	return err
+		analytics.IPAddress, analytics.Referer, analytics.Country, analytics.Variant, analytics.Domain)
+	return storeError(err)
 }
 
-func (r *URLRepository) GetClickCount(ctx context.Context, shortCode string) (int64, error) {
+func (r *URLRepository) GetClickCount(ctx context.Context, host, shortCode string) (int64, error) {
 	var count int64
-	query := `SELECT COUNT(*) FROM url_analytics WHERE short_code = $1`
-	err := r.db.GetContext(ctx, &count, query, shortCode)
-	return count, err
+	query := `SELECT COUNT(*) FROM url_analytics WHERE domain = $1 AND short_code = $2`
+	err := r.db.GetContext(ctx, &count, query, host, shortCode)
+	return count, storeError(err)
 }
 
-func (r *URLRepository) GetDailyStats(ctx context.Context, shortCode string, days int) ([]domain.DailyStat, error) {
+func (r *URLRepository) GetDailyStats(ctx context.Context, host, shortCode string, days int) ([]domain.DailyStat, error) {
 	var stats []domain.DailyStat
 	query := `
 		SELECT 
 			DATE(clicked_at) as date,
 			COUNT(*) as clicks
 		FROM url_analytics 
-		WHERE short_code = $1 AND clicked_at >= NOW() - INTERVAL '%d days'
+		WHERE domain = $1 AND short_code = $2 AND clicked_at >= NOW() - INTERVAL '%d days'
 		GROUP BY DATE(clicked_at)
 		ORDER BY date DESC
 	`
-	err := r.db.SelectContext(ctx, &stats, fmt.Sprintf(query, days), shortCode)
-	return stats, err
+	err := r.db.SelectContext(ctx, &stats, fmt.Sprintf(query, days), host, shortCode)
+	return stats, storeError(err)
 }
 
-func (r *URLRepository) GetLastAccessed(ctx context.Context, shortCode string) (*time.Time, error) {
+func (r *URLRepository) GetLastAccessed(ctx context.Context, host, shortCode string) (*time.Time, error) {
 	var lastAccessed sql.NullTime
 	query := `
 		SELECT MAX(clicked_at) 
 		FROM url_analytics 
-		WHERE short_code = $1
+		WHERE domain = $1 AND short_code = $2
 	`
-	err := r.db.GetContext(ctx, &lastAccessed, query, shortCode)
+	err := r.db.GetContext(ctx, &lastAccessed, query, host, shortCode)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err)
 	}
 	if !lastAccessed.Valid {
 		return nil, nil
 	}
 	return &lastAccessed.Time, nil
 }
-func (r *URLRepository) IsShortCodeExists(ctx context.Context, shortCode string) (bool, error) {
+
+// IsShortCodeExists checks every workspace: short codes share one namespace
+// per domain so that redirects can find them
+func (r *URLRepository) IsShortCodeExists(ctx context.Context, host, shortCode string) (bool, error) {
 	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM urls WHERE short_code = $1)`
-	err := r.db.GetContext(ctx, &exists, query, shortCode)
-	return exists, err
+	query := `SELECT EXISTS(SELECT 1 FROM urls WHERE domain = $1 AND short_code = $2)`
+	err := r.db.GetContext(ctx, &exists, query, host, shortCode)
+	return exists, storeError(err)
+}
+
+func (r *URLRepository) CreateDomain(ctx context.Context, d *domain.Domain) error {
+	query := `INSERT INTO domains (workspace_id, name, created_at) VALUES ($1, $2, $3) RETURNING id`
+	if err := r.db.GetContext(ctx, &d.ID, query, d.WorkspaceID, d.Name, d.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert domain: %w", storeError(err))
+	}
+	return nil
+}
+
+func (r *URLRepository) GetDomain(ctx context.Context, name string) (*domain.Domain, error) {
+	var d domain.Domain
+	where, args := scoped(ctx, "name = $1", name)
+	query := `SELECT id, workspace_id, name, created_at FROM domains WHERE ` + where
+	if err := r.db.GetContext(ctx, &d, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("domain %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get domain: %w", storeError(err))
+	}
+	return &d, nil
+}
+
+func (r *URLRepository) ListDomains(ctx context.Context) ([]*domain.Domain, error) {
+	var domains []*domain.Domain
+	where, args := scoped(ctx, "TRUE")
+	query := `SELECT id, workspace_id, name, created_at FROM domains WHERE ` + where + ` ORDER BY name`
+	if err := r.db.SelectContext(ctx, &domains, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", storeError(err))
+	}
+	return domains, nil
 }
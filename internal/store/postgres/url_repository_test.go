@@ -3,18 +3,22 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
 	"github.com/stretchr/testify/require"
 
 	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/storetest"
 )
 
 func setupTestPostgres(t *testing.T) (databaseURL string, pool *dockertest.Pool, resource *dockertest.Resource, cleanup func()) {
@@ -116,7 +120,7 @@ func TestURLRepository_Integration(t *testing.T) {
 	}
 
 	// Get by short code
-	got, err := repo.GetURLByShortCode(ctx, "abc123")
+	got, err := repo.GetURLByShortCode(ctx, "", "abc123")
 	if err != nil {
 		t.Fatalf("GetURLByShortCode error: %v", err)
 	}
@@ -125,10 +129,10 @@ func TestURLRepository_Integration(t *testing.T) {
 	}
 
 	// Update click count
-	if err := repo.UpdateClickCount(ctx, "abc123"); err != nil {
+	if err := repo.UpdateClickCount(ctx, "", "abc123"); err != nil {
 		t.Fatalf("UpdateClickCount error: %v", err)
 	}
-	again, err := repo.GetURLByShortCode(ctx, "abc123")
+	again, err := repo.GetURLByShortCode(ctx, "", "abc123")
 	if err != nil {
 		t.Fatalf("GetURLByShortCode after update error: %v", err)
 	}
@@ -149,7 +153,7 @@ func TestURLRepository_Integration(t *testing.T) {
 	}
 
 	// Get analytics for last 7 days
-	analytics, err := repo.GetAnalytics(ctx, "abc123", 7)
+	analytics, err := repo.GetAnalytics(ctx, "", "abc123", 7)
 	if err != nil {
 		t.Fatalf("GetAnalytics error: %v", err)
 	}
@@ -178,7 +182,7 @@ func TestURLRepository_Integration(t *testing.T) {
 		t.Fatalf("DeleteExpiredURLs error: %v", err)
 	}
 	// Try to fetch expired URL — should return not found
-	if _, err := repo.GetURLByShortCode(ctx, "expired1"); err == nil {
+	if _, err := repo.GetURLByShortCode(ctx, "", "expired1"); err == nil {
 		t.Fatalf("expected error for expired url after delete, got nil")
 	}
 
@@ -187,11 +191,36 @@ func TestURLRepository_Integration(t *testing.T) {
 		t.Fatalf("Cleanup error: %v", err)
 	}
 	// After cleanup, attempt to get main url -> expect not found
-	if _, err := repo.GetURLByShortCode(ctx, "abc123"); err == nil {
+	if _, err := repo.GetURLByShortCode(ctx, "", "abc123"); err == nil {
 		t.Fatalf("expected not found after cleanup for abc123, got nil")
 	}
 }
 
+func TestURLRepository_Contract(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	dbURL, _, _, cleanup := setupTestPostgres(t)
+	defer cleanup()
+
+	repo, err := NewURLRepository(dbURL)
+	if err != nil {
+		t.Fatalf("NewURLRepository error: %v", err)
+	}
+	defer repo.Close()
+
+	// One database serves every case, so each starts from empty tables
+	empty := func(t *testing.T) *URLRepository {
+		if _, err := repo.db.Exec(`TRUNCATE urls, url_analytics RESTART IDENTITY`); err != nil {
+			t.Fatalf("truncate error: %v", err)
+		}
+		return repo
+	}
+	storetest.TestURLRepository(t, func(t *testing.T) domain.URLRepository { return empty(t) })
+	storetest.TestAnalyticsRepository(t, func(t *testing.T) domain.AnalyticsRepository { return empty(t) })
+}
+
 func TestGetURLByID_NotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -200,13 +229,134 @@ func TestGetURLByID_NotFound(t *testing.T) {
 	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
 
 	// simulate no rows
-	mock.ExpectQuery(`SELECT (.+) FROM urls WHERE short_code = \$1`).
-		WithArgs("nonexistent").
+	mock.ExpectQuery(`SELECT (.+) FROM urls WHERE domain = \$1 AND short_code = \$2`).
+		WithArgs("", "nonexistent").
 		WillReturnError(sql.ErrNoRows)
 
-	_, err = repo.GetURLByShortCode(context.Background(), "nonexistent")
+	_, err = repo.GetURLByShortCode(context.Background(), "", "nonexistent")
 	require.Error(t, err)
 	require.Equal(t, "URL not found", err.Error())
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateURL_Conflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
+
+	mock.ExpectQuery(`INSERT INTO urls`).
+		WillReturnError(&pq.Error{Code: "23505", Message: `duplicate key value violates unique constraint "urls_domain_short_code_key"`})
+
+	err = repo.CreateURL(context.Background(), &domain.URL{ShortCode: "taken", OriginalURL: "https://example.com"})
+	require.ErrorIs(t, err, domain.ErrConflict)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateURL_ShortCodeTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
+
+	// The insert skips taken short codes instead of failing
+	mock.ExpectQuery(`INSERT INTO urls (.+) ON CONFLICT \(domain, short_code\) DO NOTHING RETURNING id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	err = repo.CreateURL(context.Background(), &domain.URL{ShortCode: "taken", OriginalURL: "https://example.com"})
+	require.ErrorIs(t, err, domain.ErrConflict)
+	require.EqualError(t, err, `failed to insert URL: short code "taken" already exists`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListLinkKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
+
+	mock.ExpectQuery(`SELECT domain, short_code FROM urls`).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "short_code"}).
+			AddRow("", "abc").
+			AddRow("go.brand.com", "sale"))
+
+	var keys []string
+	require.NoError(t, repo.ListLinkKeys(context.Background(), func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}))
+	require.Equal(t, []string{"abc", "go.brand.com/sale"}, keys)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListPopularURLs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
+	since := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery(`ORDER BY hot.last_click DESC\s+LIMIT \$2`).
+		WithArgs(since, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"short_code", "original_url"}).
+			AddRow("abc", "https://example.com"))
+
+	urls, err := repo.ListPopularURLs(context.Background(), since, domain.PopularByRecent, 5)
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	require.Equal(t, "abc", urls[0].ShortCode)
+
+	_, err = repo.ListPopularURLs(context.Background(), since, "random", 5)
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStoreError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"UniqueViolation", &pq.Error{Code: "23505"}, domain.ErrConflict},
+		{"ConnectionFailure", &pq.Error{Code: "08006"}, domain.ErrUnavailable},
+		{"TooManyConnections", &pq.Error{Code: "53300"}, domain.ErrUnavailable},
+		{"AdminShutdown", &pq.Error{Code: "57P01"}, domain.ErrUnavailable},
+		{"BadConnection", driver.ErrBadConn, domain.ErrUnavailable},
+		{"Timeout", fmt.Errorf("query: %w", context.DeadlineExceeded), domain.ErrUnavailable},
+		{"Dial", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, domain.ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storeError(tt.err)
+			require.ErrorIs(t, err, tt.want)
+			require.ErrorIs(t, err, tt.err, "the driver error is kept")
+		})
+	}
+
+	syntax := &pq.Error{Code: "42601"}
+	require.Equal(t, error(syntax), storeError(syntax), "other errors are unchanged")
+	require.NoError(t, storeError(nil))
+}
+
+func TestGetURLByShortCode_WorkspaceScoped(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
+
+	// A workspace-scoped context only sees the workspace's own links
+	mock.ExpectQuery(`SELECT (.+) FROM urls WHERE domain = \$1 AND short_code = \$2 AND workspace_id = \$3`).
+		WithArgs("", "abc", int64(4)).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetURLByShortCode(domain.WithWorkspace(context.Background(), 4), "", "abc")
+	require.Error(t, err)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -239,3 +389,74 @@ func TestHealthCheck_Error(t *testing.T) {
 	require.Error(t, err)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestGetAnalytics_VariantStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM urls WHERE domain = \$1 AND short_code = \$2`).
+		WithArgs("go.brand.com", "split1").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "domain", "short_code", "original_url", "click_count", "created_at", "expires_at", "last_access",
+			"activates_at", "coming_soon_url", "redirect_status", "forward_query", "forward_path",
+			"geo_rules", "device_rules", "variants", "variant_mode", "interstitial",
+			"safety_status", "safety_checked_at",
+			"health_status", "health_latency_ms", "health_final_url", "health_error", "health_checked_at", "health_failures", "disabled",
+			"og_title", "og_description", "og_image",
+		}).AddRow(
+			1, "go.brand.com", "split1", "https://example.com", 3, now, nil, nil,
+			nil, "", 0, false, false,
+			[]byte(`[]`), []byte(`[]`), []byte(`[{"name":"a","url":"https://a.example.com","weight":1}]`), "sticky", false,
+			"safe", now,
+			200, 42, "https://example.com/", "", now, 0, false,
+			"Example", "", "",
+		))
+	mock.ExpectQuery(`SELECT DATE\(clicked_at\)`).
+		WithArgs("go.brand.com", "split1", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "clicks"}).AddRow("2024-01-02", 3))
+	mock.ExpectQuery(`SELECT variant, COUNT\(\*\) as clicks`).
+		WithArgs("go.brand.com", "split1", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"variant", "clicks"}).AddRow("a", 2).AddRow("b", 1))
+
+	analytics, err := repo.GetAnalytics(context.Background(), "go.brand.com", "split1", 7)
+	require.NoError(t, err)
+	require.Equal(t, "go.brand.com", analytics.Domain)
+	require.Equal(t, []domain.VariantStat{{Variant: "a", Clicks: 2}, {Variant: "b", Clicks: 1}}, analytics.VariantStats)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateSafetyStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
+	checkedAt := time.Now()
+
+	mock.ExpectExec(`UPDATE urls SET safety_status = \$3, safety_checked_at = \$4 WHERE domain = \$1 AND short_code = \$2`).
+		WithArgs("", "abc123", domain.SafetyUnsafe, checkedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.UpdateSafetyStatus(context.Background(), "", "abc123", domain.SafetyUnsafe, checkedAt))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateLinkHealth(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := &URLRepository{db: sqlx.NewDb(db, "postgres")}
+	health := &domain.LinkHealth{Status: 404, LatencyMs: 120, FinalURL: "https://example.com/gone", CheckedAt: time.Now(), ConsecutiveFailures: 3}
+
+	mock.ExpectExec(`UPDATE urls SET health_status = \$3`).
+		WithArgs("", "abc123", 404, int64(120), "https://example.com/gone", "", health.CheckedAt, 3, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.UpdateLinkHealth(context.Background(), "", "abc123", health, true))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
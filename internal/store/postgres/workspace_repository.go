@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// scoped appends the workspace filter of ctx, if it carries one, to a WHERE
+// condition whose placeholders are numbered by args
+func scoped(ctx context.Context, where string, args ...interface{}) (string, []interface{}) {
+	if workspaceID, ok := domain.WorkspaceFromContext(ctx); ok {
+		args = append(args, workspaceID)
+		where += fmt.Sprintf(" AND workspace_id = $%d", len(args))
+	}
+	return where, args
+}
+
+func (r *URLRepository) CreateWorkspace(ctx context.Context, ws *domain.Workspace, ownerID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", storeError(err))
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO workspaces (name, max_links, max_api_calls, created_at) VALUES ($1, $2, $3, $4) RETURNING id`
+	if err := tx.GetContext(ctx, &ws.ID, query, ws.Name, ws.MaxLinks, ws.MaxAPICalls, ws.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert workspace: %w", storeError(err))
+	}
+	memberQuery := `INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, memberQuery, ws.ID, ownerID, domain.RoleOwner, ws.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert workspace owner: %w", storeError(err))
+	}
+
+	return storeError(tx.Commit())
+}
+
+func (r *URLRepository) GetWorkspace(ctx context.Context, id int64) (*domain.Workspace, error) {
+	var ws domain.Workspace
+	query := `SELECT id, name, max_links, max_api_calls, created_at FROM workspaces WHERE id = $1`
+	if err := r.db.GetContext(ctx, &ws, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("workspace %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get workspace: %w", storeError(err))
+	}
+	return &ws, nil
+}
+
+func (r *URLRepository) ListWorkspaces(ctx context.Context, userID string) ([]*domain.Workspace, error) {
+	var workspaces []*domain.Workspace
+	query := `
+	SELECT w.id, w.name, w.max_links, w.max_api_calls, w.created_at
+	FROM workspaces w
+	JOIN workspace_members m ON m.workspace_id = w.id
+	WHERE m.user_id = $1
+	ORDER BY w.name
+	`
+	if err := r.db.SelectContext(ctx, &workspaces, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", storeError(err))
+	}
+	return workspaces, nil
+}
+
+func (r *URLRepository) CountURLs(ctx context.Context, workspaceID int64) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM urls WHERE workspace_id = $1`
+	if err := r.db.GetContext(ctx, &count, query, workspaceID); err != nil {
+		return 0, fmt.Errorf("failed to count URLs: %w", storeError(err))
+	}
+	return count, nil
+}
+
+func (r *URLRepository) SaveMember(ctx context.Context, m *domain.Member) error {
+	query := `
+	INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`
+	if _, err := r.db.ExecContext(ctx, query, m.WorkspaceID, m.UserID, m.Role, m.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save member: %w", storeError(err))
+	}
+	return nil
+}
+
+func (r *URLRepository) GetMember(ctx context.Context, workspaceID int64, userID string) (*domain.Member, error) {
+	var m domain.Member
+	query := `SELECT workspace_id, user_id, role, created_at FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+	if err := r.db.GetContext(ctx, &m, query, workspaceID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("member %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get member: %w", storeError(err))
+	}
+	return &m, nil
+}
+
+func (r *URLRepository) ListMembers(ctx context.Context, workspaceID int64) ([]*domain.Member, error) {
+	var members []*domain.Member
+	query := `SELECT workspace_id, user_id, role, created_at FROM workspace_members WHERE workspace_id = $1 ORDER BY created_at`
+	if err := r.db.SelectContext(ctx, &members, query, workspaceID); err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", storeError(err))
+	}
+	return members, nil
+}
+
+func (r *URLRepository) RemoveMember(ctx context.Context, workspaceID int64, userID string) error {
+	query := `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", storeError(err))
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("member %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *URLRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	query := `
+	INSERT INTO api_keys (workspace_id, name, prefix, key_hash, role, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+	`
+	if err := r.db.GetContext(ctx, &key.ID, query, key.WorkspaceID, key.Name, key.Prefix, key.KeyHash, key.Role, key.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert API key: %w", storeError(err))
+	}
+	return nil
+}
+
+func (r *URLRepository) GetAPIKey(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	query := `SELECT id, workspace_id, name, prefix, key_hash, role, created_at FROM api_keys WHERE key_hash = $1`
+	if err := r.db.GetContext(ctx, &key, query, keyHash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API key %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", storeError(err))
+	}
+	return &key, nil
+}
+
+func (r *URLRepository) ListAPIKeys(ctx context.Context, workspaceID int64) ([]*domain.APIKey, error) {
+	var keys []*domain.APIKey
+	query := `SELECT id, workspace_id, name, prefix, key_hash, role, created_at FROM api_keys WHERE workspace_id = $1 ORDER BY created_at`
+	if err := r.db.SelectContext(ctx, &keys, query, workspaceID); err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", storeError(err))
+	}
+	return keys, nil
+}
+
+func (r *URLRepository) DeleteAPIKey(ctx context.Context, workspaceID, id int64) error {
+	query := `DELETE FROM api_keys WHERE workspace_id = $1 AND id = $2`
+	result, err := r.db.ExecContext(ctx, query, workspaceID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", storeError(err))
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("API key %w", domain.ErrNotFound)
+	}
+	return nil
+}
@@ -2,73 +2,303 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
 )
 
+// CacheRepository stores JSON values in Redis. Keys used with a context
+// scoped to a workspace are partitioned under "ws:<id>:", so workspaces
+// never read each other's entries.
 type CacheRepository struct {
-	client *redis.Client
+	client redis.UniversalClient
+}
+
+// key partitions a cache key by the workspace of ctx, if any
+func key(ctx context.Context, k string) string {
+	if workspaceID, ok := domain.WorkspaceFromContext(ctx); ok {
+		return fmt.Sprintf("ws:%d:%s", workspaceID, k)
+	}
+	return k
+}
+
+// cacheError marks failures to reach Redis with domain.ErrUnavailable. Error
+// replies from the server, such as incrementing a string, are returned
+// unchanged.
+func cacheError(err error) error {
+	var reply redis.Error
+	if err == nil || errors.As(err, &reply) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+}
+
+// Resubscribing after a lost connection waits this long at first, doubling
+// up to the maximum while Redis stays unreachable
+const (
+	minResubscribeBackoff = 100 * time.Millisecond
+	maxResubscribeBackoff = 5 * time.Second
+)
+
+// Options describe how to reach Redis: through Sentinel when MasterName is
+// set, as a Cluster when ClusterAddrs is, and as a single server otherwise.
+// Zero sizes and timeouts keep the client's defaults.
+type Options struct {
+	Addr string // host:port of a single server
+
+	MasterName       string   // Master monitored by Sentinel
+	SentinelAddrs    []string // host:port of the Sentinels
+	SentinelUsername string
+	SentinelPassword string
+
+	ClusterAddrs []string // host:port of Cluster nodes to discover the others from
+
+	Username string // ACL user; empty is the default user
+	Password string
+	DB       int // Not supported by Cluster
+	TLS      *tls.Config
+
+	PoolSize     int // Connections per server
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration // Longest a command waits for a free connection
 }
 
+// NewCacheRepository connects to the single Redis server at redisURL
 func NewCacheRepository(redisURL string) (*CacheRepository, error) {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
 	}
+	return connect(redis.NewClient(opt))
+}
 
-	client := redis.NewClient(opt)
+// NewCacheRepositoryWithOptions connects to the server, Sentinel group or
+// Cluster described by opts
+func NewCacheRepositoryWithOptions(opts Options) (*CacheRepository, error) {
+	if opts.MasterName != "" && len(opts.ClusterAddrs) > 0 {
+		return nil, fmt.Errorf("redis cannot use Sentinel and Cluster at once")
+	}
+	universal := &redis.UniversalOptions{
+		Addrs:            []string{opts.Addr},
+		DB:               opts.DB,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		SentinelUsername: opts.SentinelUsername,
+		SentinelPassword: opts.SentinelPassword,
+		MasterName:       opts.MasterName,
+		TLSConfig:        opts.TLS,
+		PoolSize:         opts.PoolSize,
+		MinIdleConns:     opts.MinIdleConns,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+		PoolTimeout:      opts.PoolTimeout,
+	}
+
+	// NewUniversalClient only picks Cluster for several addresses, so a
+	// Cluster with a single seed node is created explicitly
+	switch {
+	case opts.MasterName != "":
+		universal.Addrs = opts.SentinelAddrs
+		return connect(redis.NewFailoverClient(universal.Failover()))
+	case len(opts.ClusterAddrs) > 0:
+		universal.Addrs = opts.ClusterAddrs
+		return connect(redis.NewClusterClient(universal.Cluster()))
+	default:
+		return connect(redis.NewClient(universal.Simple()))
+	}
+}
 
-	// Test connection
+// connect checks that client can reach Redis, closing it if not
+func connect(client redis.UniversalClient) (*CacheRepository, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
 	return &CacheRepository{client: client}, nil
 }
 
-func (r *CacheRepository) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (r *CacheRepository) Set(ctx context.Context, k string, value interface{}, ttl time.Duration) error {
 	data, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
 
-	return r.client.Set(ctx, key, data, ttl).Err()
+	return cacheError(r.client.Set(ctx, key(ctx, k), data, ttl).Err())
 }
 
-func (r *CacheRepository) Get(ctx context.Context, key string, dest interface{}) error {
-	data, err := r.client.Get(ctx, key).Result()
+func (r *CacheRepository) Get(ctx context.Context, k string, dest interface{}) error {
+	data, err := r.client.Get(ctx, key(ctx, k)).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return fmt.Errorf("key not found")
+			return fmt.Errorf("key %w", domain.ErrNotFound)
 		}
-		return fmt.Errorf("failed to get value: %w", err)
+		return fmt.Errorf("failed to get value: %w", cacheError(err))
 	}
 
 	return json.Unmarshal([]byte(data), dest)
 }
 
-func (r *CacheRepository) Delete(ctx context.Context, key string) error {
-	return r.client.Del(ctx, key).Err()
+func (r *CacheRepository) Delete(ctx context.Context, k string) error {
+	return cacheError(r.client.Del(ctx, key(ctx, k)).Err())
 }
 
-func (r *CacheRepository) Increment(ctx context.Context, key string, value int64) error {
-	return r.client.IncrBy(ctx, key, value).Err()
+func (r *CacheRepository) Increment(ctx context.Context, k string, value int64) error {
+	return cacheError(r.client.IncrBy(ctx, key(ctx, k), value).Err())
+}
+
+func (r *CacheRepository) Expire(ctx context.Context, k string, ttl time.Duration) error {
+	return cacheError(r.client.Expire(ctx, key(ctx, k), ttl).Err())
 }
 
 func (r *CacheRepository) HealthCheck(ctx context.Context) error {
-	return r.client.Ping(ctx).Err()
+	return cacheError(r.client.Ping(ctx).Err())
 }
 
 func (r *CacheRepository) Close() error {
 	return r.client.Close()
 }
 
+// Publish sends a message to every subscriber of channel
+func (r *CacheRepository) Publish(ctx context.Context, channel string, message []byte) error {
+	return cacheError(r.client.Publish(ctx, channel, message).Err())
+}
+
+// Subscribe calls handle with every message on channel until ctx is done.
+// It returns once Redis has confirmed the subscription. When the connection
+// is lost it resubscribes, backing off between attempts, and calls missed,
+// if not nil, once the subscription is back: messages published in between
+// are gone.
+func (r *CacheRepository) Subscribe(ctx context.Context, channel string, handle func(message []byte), missed func()) error {
+	pubsub := r.client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to %s: %w", channel, cacheError(err))
+	}
+
+	go r.listen(ctx, pubsub, channel, handle, missed)
+	return nil
+}
+
+// listen delivers the messages of a subscription, renewing it whenever the
+// connection fails, until ctx is done
+func (r *CacheRepository) listen(ctx context.Context, pubsub *redis.PubSub, channel string, handle func(message []byte), missed func()) {
+	// Receive does not watch ctx, so closing the subscription is what ends it
+	var mu sync.Mutex
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		pubsub.Close()
+	})
+	defer stop()
+
+	backoff := minResubscribeBackoff
+	lost := false
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			lost = true
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, maxResubscribeBackoff)
+
+			mu.Lock()
+			pubsub.Close()
+			pubsub = r.client.Subscribe(ctx, channel)
+			mu.Unlock()
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Message:
+			handle([]byte(msg.Payload))
+		case *redis.Subscription:
+			backoff = minResubscribeBackoff
+			if lost && missed != nil {
+				missed()
+			}
+			lost = false
+		}
+	}
+}
+
+// SetBits sets bits of a bitmap in one round trip
+func (r *CacheRepository) SetBits(ctx context.Context, k string, offsets []uint64) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, offset := range offsets {
+			pipe.SetBit(ctx, key(ctx, k), int64(offset), 1)
+		}
+		return nil
+	})
+	return cacheError(err)
+}
+
+// HasBits reports whether all the given bits of a bitmap are set, in one
+// round trip
+func (r *CacheRepository) HasBits(ctx context.Context, k string, offsets []uint64) (bool, error) {
+	var bits []*redis.IntCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, offset := range offsets {
+			bits = append(bits, pipe.GetBit(ctx, key(ctx, k), int64(offset)))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, cacheError(err)
+	}
+	for _, bit := range bits {
+		if bit.Val() == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// MergeBitmap ORs bitmap into the bitmap at k, through a temporary key so
+// bits set meanwhile by other instances are kept
+func (r *CacheRepository) MergeBitmap(ctx context.Context, k string, bitmap []byte) error {
+	k = key(ctx, k)
+	// The hash tag keeps tmp in the same Cluster slot as k, as BITOP requires
+	tmp := "{" + k + "}:merge:" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, tmp, bitmap, time.Minute)
+		pipe.BitOpOr(ctx, k, k, tmp)
+		pipe.Del(ctx, tmp)
+		return nil
+	})
+	return cacheError(err)
+}
+
+// GetBitmap returns the bitmap at k, or nothing if there is none
+func (r *CacheRepository) GetBitmap(ctx context.Context, k string) ([]byte, error) {
+	bitmap, err := r.client.Get(ctx, key(ctx, k)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return bitmap, cacheError(err)
+}
+
 func (r *CacheRepository) SetURLMapping(ctx context.Context, shortKey string, url string, ttl time.Duration) error {
 	// Store shortKey → URL
 	if err := r.Set(ctx, "shortKey:"+shortKey, url, ttl); err != nil {
@@ -97,10 +327,20 @@ func (r *CacheRepository) GetShortKeyByURL(ctx context.Context, url string) (str
 	return shortKey, nil
 }
 
+// Cleanup empties the database, on every master of a Cluster
 func (r *CacheRepository) Cleanup(ctx context.Context) error {
-	return r.client.FlushDB(ctx).Err()
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cacheError(cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			return master.FlushDB(ctx).Err()
+		}))
+	}
+	return cacheError(r.client.FlushDB(ctx).Err())
 }
 
-func (r *CacheRepository) GetCounter(ctx context.Context, key string) (int64, error) {
-	return r.client.Get(ctx, key).Int64()
+func (r *CacheRepository) GetCounter(ctx context.Context, k string) (int64, error) {
+	count, err := r.client.Get(ctx, key(ctx, k)).Int64()
+	if err == redis.Nil {
+		return 0, fmt.Errorf("key %w", domain.ErrNotFound)
+	}
+	return count, cacheError(err)
 }
@@ -2,11 +2,15 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
 
 	miniredis "github.com/alicebob/miniredis/v2"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/storetest"
 )
 
 func TestCacheRepository_BasicFlow(t *testing.T) {
@@ -171,3 +175,136 @@ func TestCacheRepository_URLMappingAndCleanup(t *testing.T) {
 		t.Fatalf("expected error after cleanup for GetShortKeyByURL, got nil")
 	}
 }
+
+func TestCacheRepository_WorkspaceScopedKeys(t *testing.T) {
+	srv, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer srv.Close()
+
+	r, err := NewCacheRepository("redis://" + srv.Addr())
+	if err != nil {
+		t.Fatalf("NewCacheRepository error: %v", err)
+	}
+	defer r.Close()
+
+	ctx := context.Background()
+	scoped := domain.WithWorkspace(ctx, 3)
+	if err := r.Set(scoped, "foo", "bar", time.Minute); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if !srv.Exists("ws:3:foo") {
+		t.Fatalf("expected key to be stored under the workspace prefix, keys: %v", srv.Keys())
+	}
+
+	var got string
+	if err := r.Get(ctx, "foo", &got); err == nil {
+		t.Fatalf("expected unscoped lookup to miss, got %q", got)
+	}
+	if err := r.Get(scoped, "foo", &got); err != nil || got != "bar" {
+		t.Fatalf("expected scoped lookup to hit, got %q, %v", got, err)
+	}
+}
+
+func TestCacheRepository_Contract(t *testing.T) {
+	storetest.TestCacheRepository(t, func(t *testing.T) storetest.Cache {
+		srv := miniredis.RunT(t)
+		r, err := NewCacheRepository("redis://" + srv.Addr())
+		if err != nil {
+			t.Fatalf("NewCacheRepository error: %v", err)
+		}
+		t.Cleanup(func() { r.Close() })
+		return storetest.Cache{CacheRepository: r, Advance: srv.FastForward}
+	})
+}
+
+func TestCacheRepository_Unavailable(t *testing.T) {
+	srv := miniredis.RunT(t)
+	r, err := NewCacheRepository("redis://" + srv.Addr())
+	if err != nil {
+		t.Fatalf("NewCacheRepository error: %v", err)
+	}
+	defer r.Close()
+	ctx := context.Background()
+
+	if err := r.Set(ctx, "text", "abc", 0); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	// Error replies mean Redis is up
+	if err := r.Increment(ctx, "text", 1); err == nil || errors.Is(err, domain.ErrUnavailable) {
+		t.Fatalf("expected an error reply, got %v", err)
+	}
+
+	srv.Close()
+	var v string
+	if err := r.Get(ctx, "text", &v); !errors.Is(err, domain.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable from Get, got %v", err)
+	}
+	if err := r.HealthCheck(ctx); !errors.Is(err, domain.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable from HealthCheck, got %v", err)
+	}
+}
+
+func TestCacheRepository_Cluster(t *testing.T) {
+	// miniredis answers CLUSTER SLOTS as a single node owning every slot
+	storetest.TestCacheRepository(t, func(t *testing.T) storetest.Cache {
+		srv := miniredis.RunT(t)
+		r, err := NewCacheRepositoryWithOptions(Options{ClusterAddrs: []string{srv.Addr()}})
+		if err != nil {
+			t.Fatalf("NewCacheRepositoryWithOptions error: %v", err)
+		}
+		t.Cleanup(func() { r.Close() })
+		return storetest.Cache{CacheRepository: r, Advance: srv.FastForward}
+	})
+
+	srv := miniredis.RunT(t)
+	r, err := NewCacheRepositoryWithOptions(Options{ClusterAddrs: []string{srv.Addr()}})
+	if err != nil {
+		t.Fatalf("NewCacheRepositoryWithOptions error: %v", err)
+	}
+	defer r.Close()
+	ctx := context.Background()
+
+	if err := r.MergeBitmap(ctx, "bits", []byte{0x80}); err != nil {
+		t.Fatalf("MergeBitmap error: %v", err)
+	}
+	if err := r.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup error: %v", err)
+	}
+	if keys := srv.Keys(); len(keys) != 0 {
+		t.Fatalf("expected every master to be flushed, keys: %v", keys)
+	}
+}
+
+func TestCacheRepository_UserAuth(t *testing.T) {
+	srv := miniredis.RunT(t)
+	srv.RequireUserAuth("shortener", "secret")
+
+	if _, err := NewCacheRepositoryWithOptions(Options{Addr: srv.Addr(), Username: "shortener", Password: "wrong"}); err == nil {
+		t.Fatalf("expected a wrong password to be refused")
+	}
+	r, err := NewCacheRepositoryWithOptions(Options{
+		Addr:        srv.Addr(),
+		Username:    "shortener",
+		Password:    "secret",
+		PoolSize:    2,
+		DialTimeout: time.Second,
+		ReadTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewCacheRepositoryWithOptions error: %v", err)
+	}
+	defer r.Close()
+
+	if err := r.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("health check failed: %v", err)
+	}
+}
+
+func TestNewCacheRepositoryWithOptions_SentinelAndCluster(t *testing.T) {
+	_, err := NewCacheRepositoryWithOptions(Options{MasterName: "mymaster", ClusterAddrs: []string{"localhost:7000"}})
+	if err == nil || !strings.Contains(err.Error(), "Sentinel and Cluster") {
+		t.Fatalf("expected Sentinel and Cluster to be refused together, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package storetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// Cache is a cache under test together with a way to move its clock
+type Cache struct {
+	domain.CacheRepository
+	Advance func(d time.Duration) // Moves the cache's clock forward by d
+}
+
+// TestCacheRepository runs the CacheRepository contract. newCache is called
+// once per test and must return an empty cache.
+func TestCacheRepository(t *testing.T, newCache func(t *testing.T) Cache) {
+	ctx := context.Background()
+
+	t.Run("SetGet", func(t *testing.T) {
+		c := newCache(t)
+		require.NoError(t, c.HealthCheck(ctx))
+
+		require.NoError(t, c.Set(ctx, "url:abc", &domain.URL{ShortCode: "abc", OriginalURL: "https://example.com"}, time.Minute))
+		var got domain.URL
+		require.NoError(t, c.Get(ctx, "url:abc", &got))
+		assert.Equal(t, "https://example.com", got.OriginalURL)
+
+		require.NoError(t, c.Set(ctx, "url:abc", &domain.URL{ShortCode: "abc", OriginalURL: "https://example.org"}, time.Minute))
+		require.NoError(t, c.Get(ctx, "url:abc", &got))
+		assert.Equal(t, "https://example.org", got.OriginalURL, "values are overwritten")
+
+		require.NoError(t, c.Delete(ctx, "url:abc"))
+		assert.EqualError(t, c.Get(ctx, "url:abc", &got), "key not found")
+		assert.NoError(t, c.Delete(ctx, "url:abc"), "deleting a missing key is not an error")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		c := newCache(t)
+
+		var s string
+		err := c.Get(ctx, "missing", &s)
+		assert.EqualError(t, err, "key not found")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = c.GetCounter(ctx, "clicks:missing")
+		assert.EqualError(t, err, "key not found")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		c := newCache(t)
+		require.NoError(t, c.Set(ctx, "short", "v", time.Second))
+		require.NoError(t, c.Set(ctx, "long", "v", time.Hour))
+		require.NoError(t, c.Set(ctx, "forever", "v", 0))
+
+		c.Advance(2 * time.Second)
+		var s string
+		assert.EqualError(t, c.Get(ctx, "short", &s), "key not found")
+		assert.NoError(t, c.Get(ctx, "long", &s))
+		assert.NoError(t, c.Get(ctx, "forever", &s))
+
+		require.NoError(t, c.Expire(ctx, "forever", time.Second))
+		c.Advance(2 * time.Second)
+		assert.EqualError(t, c.Get(ctx, "forever", &s), "key not found")
+		assert.NoError(t, c.Expire(ctx, "missing", time.Second), "expiring a missing key is not an error")
+	})
+
+	t.Run("Counters", func(t *testing.T) {
+		c := newCache(t)
+		require.NoError(t, c.Increment(ctx, "clicks:abc", 1))
+		require.NoError(t, c.Increment(ctx, "clicks:abc", 2))
+		count, err := c.GetCounter(ctx, "clicks:abc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+
+		var n int64 // Counters read back like any other value
+		require.NoError(t, c.Get(ctx, "clicks:abc", &n))
+		assert.Equal(t, int64(3), n)
+
+		require.NoError(t, c.Set(ctx, "text", "abc", 0))
+		assert.Error(t, c.Increment(ctx, "text", 1))
+
+		require.NoError(t, c.Expire(ctx, "clicks:abc", time.Second))
+		c.Advance(2 * time.Second)
+		_, err = c.GetCounter(ctx, "clicks:abc")
+		assert.EqualError(t, err, "key not found", "counters expire")
+	})
+
+	t.Run("ConcurrentIncrements", func(t *testing.T) {
+		c := newCache(t)
+		const n = 50
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, c.Increment(ctx, "clicks:hot", 1))
+			}()
+		}
+		wg.Wait()
+
+		count, err := c.GetCounter(ctx, "clicks:hot")
+		require.NoError(t, err)
+		assert.Equal(t, int64(n), count)
+	})
+
+	t.Run("WorkspaceScope", func(t *testing.T) {
+		c := newCache(t)
+		scoped := domain.WithWorkspace(ctx, 3)
+		require.NoError(t, c.Set(scoped, "foo", "bar", time.Minute))
+		require.NoError(t, c.Increment(scoped, "calls", 1))
+
+		var got string
+		assert.EqualError(t, c.Get(ctx, "foo", &got), "key not found")
+		assert.EqualError(t, c.Get(domain.WithWorkspace(ctx, 4), "foo", &got), "key not found")
+		require.NoError(t, c.Get(scoped, "foo", &got))
+		assert.Equal(t, "bar", got)
+		_, err := c.GetCounter(ctx, "calls")
+		assert.Error(t, err)
+
+		require.NoError(t, c.Cleanup(ctx))
+		assert.EqualError(t, c.Get(scoped, "foo", &got), "key not found", "cleanup empties every workspace")
+	})
+}
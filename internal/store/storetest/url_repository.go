@@ -0,0 +1,335 @@
+// Package storetest is a conformance suite for repository implementations.
+// Every backend runs the same tests, so that they agree on not-found errors,
+// expiry, click counting and collisions, and so that the in-memory backend
+// can stand in for the others.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// TestURLRepository runs the URLRepository contract. newRepo is called once
+// per test and must return an empty repository.
+func TestURLRepository(t *testing.T, newRepo func(t *testing.T) domain.URLRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.HealthCheck(ctx))
+
+		created := time.Now().UTC().Truncate(time.Millisecond)
+		expires := created.Add(time.Hour)
+		u := &domain.URL{ShortCode: "abc123", OriginalURL: "https://example.com/path", CreatedAt: created, ExpiresAt: &expires}
+		require.NoError(t, repo.CreateURL(ctx, u))
+		assert.NotZero(t, u.ID)
+
+		got, err := repo.GetURLByShortCode(ctx, "", "abc123")
+		require.NoError(t, err)
+		assert.Equal(t, u.ID, got.ID)
+		assert.Equal(t, "https://example.com/path", got.OriginalURL)
+		assert.WithinDuration(t, created, got.CreatedAt, time.Millisecond)
+		require.NotNil(t, got.ExpiresAt)
+		assert.WithinDuration(t, expires, *got.ExpiresAt, time.Millisecond)
+		assert.Zero(t, got.ClickCount)
+		assert.Nil(t, got.LastAccess)
+
+		exists, err := repo.IsShortCodeExists(ctx, "", "abc123")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetURLByShortCode(ctx, "", "missing")
+		assert.EqualError(t, err, "URL not found")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = repo.GetURLByOriginalURL(ctx, "", "https://missing.example.com")
+		assert.EqualError(t, err, "URL not found")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.UpdateClickCount(ctx, "", "missing"), domain.ErrNotFound)
+		_, err = repo.GetAnalytics(ctx, "", "missing", 7)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+
+		exists, err := repo.IsShortCodeExists(ctx, "", "missing")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("LatestByOriginalURL", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now().UTC()
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "older", OriginalURL: "https://example.com", CreatedAt: now.Add(-time.Hour)}))
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "newer", OriginalURL: "https://example.com", CreatedAt: now}))
+
+		got, err := repo.GetURLByOriginalURL(ctx, "", "https://example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "newer", got.ShortCode)
+	})
+
+	t.Run("DomainNamespaces", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "sale", OriginalURL: "https://example.com/default", CreatedAt: time.Now()}))
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{Domain: "go.brand.com", ShortCode: "sale", OriginalURL: "https://example.com/brand", CreatedAt: time.Now()}))
+
+		got, err := repo.GetURLByShortCode(ctx, "go.brand.com", "sale")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/brand", got.OriginalURL)
+		assert.Equal(t, "go.brand.com", got.Domain)
+
+		exists, err := repo.IsShortCodeExists(ctx, "other.com", "sale")
+		require.NoError(t, err)
+		assert.False(t, exists)
+		_, err = repo.GetURLByOriginalURL(ctx, "go.brand.com", "https://example.com/default")
+		assert.Error(t, err)
+	})
+
+	t.Run("AliasCollision", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "taken", OriginalURL: "https://example.com/first", CreatedAt: time.Now()}))
+		err := repo.CreateURL(ctx, &domain.URL{ShortCode: "taken", OriginalURL: "https://example.com/second", CreatedAt: time.Now()})
+		assert.ErrorIs(t, err, domain.ErrConflict)
+
+		got, err := repo.GetURLByShortCode(ctx, "", "taken")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/first", got.OriginalURL, "the first link is kept")
+	})
+
+	t.Run("ConcurrentInserts", func(t *testing.T) {
+		repo := newRepo(t)
+		const n = 20
+
+		var wg sync.WaitGroup
+		ids := make([]int64, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				u := &domain.URL{ShortCode: fmt.Sprintf("c%02d", i), OriginalURL: "https://example.com", CreatedAt: time.Now()}
+				assert.NoError(t, repo.CreateURL(ctx, u))
+				ids[i] = u.ID
+			}(i)
+		}
+		wg.Wait()
+
+		seen := make(map[int64]bool)
+		for _, id := range ids {
+			assert.False(t, seen[id], "IDs are unique")
+			seen[id] = true
+		}
+
+		// Racing for one short code, exactly one insert wins
+		var won atomic.Int32
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				u := &domain.URL{ShortCode: "race", OriginalURL: fmt.Sprintf("https://example.com/%d", i), CreatedAt: time.Now()}
+				err := repo.CreateURL(ctx, u)
+				if err == nil {
+					won.Add(1)
+				} else {
+					assert.ErrorIs(t, err, domain.ErrConflict)
+				}
+			}(i)
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), won.Load())
+	})
+
+	t.Run("ClickCounting", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "hot", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{Domain: "go.brand.com", ShortCode: "hot", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+
+		const n = 25
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, repo.UpdateClickCount(ctx, "", "hot"))
+			}()
+		}
+		wg.Wait()
+
+		got, err := repo.GetURLByShortCode(ctx, "", "hot")
+		require.NoError(t, err)
+		assert.Equal(t, int64(n), got.ClickCount)
+		require.NotNil(t, got.LastAccess)
+		assert.WithinDuration(t, time.Now(), *got.LastAccess, time.Minute)
+
+		other, err := repo.GetURLByShortCode(ctx, "go.brand.com", "hot")
+		require.NoError(t, err)
+		assert.Zero(t, other.ClickCount, "clicks count per domain")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		repo := newRepo(t)
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "expired", OriginalURL: "https://example.com/1", CreatedAt: time.Now(), ExpiresAt: &past}))
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "later", OriginalURL: "https://example.com/2", CreatedAt: time.Now(), ExpiresAt: &future}))
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "forever", OriginalURL: "https://example.com/3", CreatedAt: time.Now()}))
+
+		// Expired links are kept until they are deleted
+		_, err := repo.GetURLByShortCode(ctx, "", "expired")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.DeleteExpiredURLs(ctx))
+		_, err = repo.GetURLByShortCode(ctx, "", "expired")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		exists, err := repo.IsShortCodeExists(ctx, "", "expired")
+		require.NoError(t, err)
+		assert.False(t, exists, "deleted short codes are free again")
+		for _, code := range []string{"later", "forever"} {
+			_, err := repo.GetURLByShortCode(ctx, "", code)
+			assert.NoError(t, err, code)
+		}
+	})
+
+	t.Run("LinkKeys", func(t *testing.T) {
+		repo := newRepo(t)
+		lister, ok := repo.(domain.LinkKeyRepository)
+		if !ok {
+			t.Skip("repository does not list link keys")
+		}
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{ShortCode: "one", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{Domain: "go.brand.com", ShortCode: "two", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{WorkspaceID: 5, ShortCode: "three", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+
+		var keys []string
+		require.NoError(t, lister.ListLinkKeys(domain.WithWorkspace(ctx, 1), func(key string) error {
+			keys = append(keys, key)
+			return nil
+		}))
+		assert.ElementsMatch(t, []string{"one", "go.brand.com/two", "three"}, keys, "every workspace is listed")
+	})
+
+	t.Run("PopularURLs", func(t *testing.T) {
+		repo := newRepo(t)
+		popular, ok := repo.(domain.PopularURLRepository)
+		analytics, recorded := repo.(domain.AnalyticsRepository)
+		if !ok || !recorded {
+			t.Skip("repository does not rank links by clicks")
+		}
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		for _, url := range []*domain.URL{
+			{ShortCode: "hot", OriginalURL: "https://example.com/hot"},
+			{Domain: "go.brand.com", ShortCode: "new", OriginalURL: "https://example.com/new"},
+			{ShortCode: "old", OriginalURL: "https://example.com/old"},
+			{ShortCode: "off", OriginalURL: "https://example.com/off", Disabled: true},
+		} {
+			url.CreatedAt = now
+			require.NoError(t, repo.CreateURL(ctx, url))
+		}
+		for _, click := range []*domain.URLAnalytics{
+			{ShortCode: "hot", ClickedAt: now.Add(-2 * time.Minute)},
+			{ShortCode: "hot", ClickedAt: now.Add(-time.Minute)},
+			{Domain: "go.brand.com", ShortCode: "new", ClickedAt: now},
+			{ShortCode: "old", ClickedAt: now.AddDate(0, 0, -30)},
+			{ShortCode: "off", ClickedAt: now},
+			{ShortCode: "gone", ClickedAt: now},
+		} {
+			require.NoError(t, analytics.RecordClick(ctx, click))
+		}
+
+		since := now.Add(-time.Hour)
+		keys := func(urls []*domain.URL) []string {
+			var keys []string
+			for _, url := range urls {
+				keys = append(keys, url.Key())
+			}
+			return keys
+		}
+		urls, err := popular.ListPopularURLs(ctx, since, domain.PopularByClicks, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"hot", "go.brand.com/new"}, keys(urls), "old, disabled and deleted links are left out")
+
+		urls, err = popular.ListPopularURLs(ctx, since, domain.PopularByRecent, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"go.brand.com/new", "hot"}, keys(urls))
+
+		urls, err = popular.ListPopularURLs(ctx, since, domain.PopularByClicks, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"hot"}, keys(urls))
+	})
+
+	t.Run("WorkspaceScope", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateURL(ctx, &domain.URL{WorkspaceID: 1, ShortCode: "team", OriginalURL: "https://example.com", CreatedAt: time.Now()}))
+
+		_, err := repo.GetURLByShortCode(domain.WithWorkspace(ctx, 2), "", "team")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.UpdateClickCount(domain.WithWorkspace(ctx, 2), "", "team"), domain.ErrNotFound)
+		exists, err := repo.IsShortCodeExists(domain.WithWorkspace(ctx, 2), "", "team")
+		require.NoError(t, err)
+		assert.True(t, exists, "short codes are unique across workspaces")
+
+		got, err := repo.GetURLByShortCode(domain.WithWorkspace(ctx, 1), "", "team")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), got.WorkspaceID)
+		_, err = repo.GetURLByShortCode(ctx, "", "team")
+		assert.NoError(t, err, "unscoped lookups see every workspace")
+	})
+}
+
+// TestAnalyticsRepository runs the AnalyticsRepository contract. newRepo is
+// called once per test and must return an empty repository.
+func TestAnalyticsRepository(t *testing.T, newRepo func(t *testing.T) domain.AnalyticsRepository) {
+	ctx := context.Background()
+
+	t.Run("NoClicks", func(t *testing.T) {
+		repo := newRepo(t)
+
+		count, err := repo.GetClickCount(ctx, "", "quiet")
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		last, err := repo.GetLastAccessed(ctx, "", "quiet")
+		require.NoError(t, err)
+		assert.Nil(t, last)
+		stats, err := repo.GetDailyStats(ctx, "", "quiet", 7)
+		require.NoError(t, err)
+		assert.Empty(t, stats)
+	})
+
+	t.Run("RecordClicks", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		for _, click := range []*domain.URLAnalytics{
+			{ShortCode: "ab", ClickedAt: now, IPAddress: "203.0.113.7", UserAgent: "test"},
+			{ShortCode: "ab", ClickedAt: now.Add(-time.Minute)},
+			{ShortCode: "ab", ClickedAt: now.AddDate(0, 0, -30)},
+			{Domain: "go.brand.com", ShortCode: "ab", ClickedAt: now},
+		} {
+			require.NoError(t, repo.RecordClick(ctx, click))
+		}
+
+		count, err := repo.GetClickCount(ctx, "", "ab")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+
+		last, err := repo.GetLastAccessed(ctx, "", "ab")
+		require.NoError(t, err)
+		require.NotNil(t, last)
+		assert.WithinDuration(t, now, *last, time.Millisecond)
+
+		stats, err := repo.GetDailyStats(ctx, "", "ab", 7)
+		require.NoError(t, err)
+		var recent int64
+		for _, stat := range stats {
+			recent += stat.Clicks
+		}
+		assert.Equal(t, int64(2), recent, "clicks outside the window are left out")
+	})
+}
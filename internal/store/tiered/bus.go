@@ -0,0 +1,127 @@
+package tiered
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// InvalidationChannel is the channel instances announce changed keys and
+// links on
+const InvalidationChannel = "cache:invalidate"
+
+// Broker carries messages between the instances sharing a cache
+type Broker interface {
+	Publish(ctx context.Context, channel string, message []byte) error
+	// Subscribe calls handle with every message on channel until ctx is done.
+	// It returns once the subscription is in place, and calls missed when
+	// messages may have been lost.
+	Subscribe(ctx context.Context, channel string, handle func(message []byte), missed func()) error
+}
+
+// invalidation is what instances publish when keys or links change
+type invalidation struct {
+	Origin string             `json:"origin"`
+	Keys   []string           `json:"keys,omitempty"`
+	All    bool               `json:"all,omitempty"`  // The shared cache was emptied
+	Link   *domain.LinkChange `json:"link,omitempty"` // A link was created, edited or deleted
+}
+
+// Bus shares invalidations between instances on InvalidationChannel. Local
+// tiers on the bus drop keys other instances changed, and link listeners,
+// such as the link filter, hear about every link change, this instance's
+// included. It is a domain.LinkPublisher, and works without a local tier.
+type Bus struct {
+	broker Broker
+	origin string // Tells this instance's invalidations apart from others'
+
+	mu        sync.RWMutex
+	caches    []*Cache
+	listeners []domain.LinkListener
+}
+
+// NewBus returns a bus over broker. It carries nothing until Start.
+func NewBus(broker Broker) (*Bus, error) {
+	origin := make([]byte, 8)
+	if _, err := rand.Read(origin); err != nil {
+		return nil, fmt.Errorf("failed to generate cache origin: %w", err)
+	}
+	return &Bus{broker: broker, origin: hex.EncodeToString(origin)}, nil
+}
+
+// Listen adds a listener for link changes made on any instance
+func (b *Bus) Listen(listener domain.LinkListener) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listeners = append(b.listeners, listener)
+}
+
+// attach has c drop the keys other instances invalidate, and the links
+// that change anywhere
+func (b *Bus) attach(c *Cache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.caches = append(b.caches, c)
+	b.listeners = append(b.listeners, c)
+}
+
+// Start subscribes to invalidations until ctx is done. After a lost
+// connection the subscription is renewed, local tiers are emptied and
+// listeners are told changes were missed.
+func (b *Bus) Start(ctx context.Context) error {
+	if err := b.broker.Subscribe(ctx, InvalidationChannel, b.deliver, b.missed); err != nil {
+		return fmt.Errorf("failed to subscribe to cache invalidations: %w", err)
+	}
+	return nil
+}
+
+// PublishLinkChange announces a change to every instance
+func (b *Bus) PublishLinkChange(ctx context.Context, change *domain.LinkChange) error {
+	return b.publish(ctx, invalidation{Link: change})
+}
+
+func (b *Bus) publish(ctx context.Context, msg invalidation) error {
+	msg.Origin = b.origin
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invalidation: %w", err)
+	}
+	return b.broker.Publish(ctx, InvalidationChannel, data)
+}
+
+func (b *Bus) deliver(message []byte) {
+	var msg invalidation
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if msg.Link != nil {
+		for _, listener := range b.listeners {
+			listener.LinkChanged(msg.Link)
+		}
+		return
+	}
+	if msg.Origin == b.origin {
+		return // Already applied when it was sent
+	}
+	for _, c := range b.caches {
+		c.receive(msg)
+	}
+}
+
+func (b *Bus) missed() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, listener := range b.listeners {
+		listener.LinkChangesMissed()
+	}
+}
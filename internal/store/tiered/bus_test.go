@@ -0,0 +1,113 @@
+package tiered
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// recorder is a LinkListener that remembers what it was told
+type recorder struct {
+	mu      sync.Mutex
+	changes []domain.LinkChange
+	missed  int
+}
+
+func (r *recorder) LinkChanged(change *domain.LinkChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, *change)
+}
+
+func (r *recorder) LinkChangesMissed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.missed++
+}
+
+func (r *recorder) counts() (int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.changes), r.missed
+}
+
+// listen starts a bus over srv with a recorder listening on it
+func listen(t *testing.T, srv *miniredis.Miniredis) (*Bus, *recorder) {
+	remote := newRedis(t, srv)
+	t.Cleanup(func() { remote.Close() })
+	bus, err := NewBus(remote)
+	require.NoError(t, err)
+	listener := &recorder{}
+	bus.Listen(listener)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, bus.Start(ctx))
+	return bus, listener
+}
+
+func TestBus_DeliversLinkChangesToEveryInstance(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	a, fromA := listen(t, srv)
+	_, fromB := listen(t, srv)
+
+	change := &domain.LinkChange{WorkspaceID: 2, Domain: "go.brand.com", ShortCode: "sale", OriginalURL: "https://example.com/sale"}
+	require.NoError(t, a.PublishLinkChange(ctx, change))
+
+	for _, listener := range []*recorder{fromA, fromB} {
+		assert.Eventually(t, func() bool {
+			n, _ := listener.counts()
+			return n == 1
+		}, time.Second, 10*time.Millisecond)
+		listener.mu.Lock()
+		assert.Equal(t, *change, listener.changes[0])
+		listener.mu.Unlock()
+	}
+}
+
+func TestBus_SharesTheInvalidationChannel(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	a := newTiered(t, srv, 100)
+	_, listener := listen(t, srv)
+
+	require.NoError(t, a.Set(ctx, "url:abc", "https://example.com", time.Hour))
+	require.NoError(t, a.bus.PublishLinkChange(ctx, &domain.LinkChange{ShortCode: "abc", OriginalURL: "https://example.com"}))
+	assert.Eventually(t, func() bool {
+		n, _ := listener.counts()
+		return n == 1
+	}, time.Second, 10*time.Millisecond, "key invalidations are not link changes")
+	assert.Equal(t, []string{InvalidationChannel}, srv.PubSubChannels(""))
+	assert.Eventually(t, func() bool {
+		return a.Stats().Entries == 0
+	}, time.Second, 10*time.Millisecond, "the publishing instance drops the link too")
+}
+
+func TestBus_ResubscribesAfterConnectionLoss(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	bus, listener := listen(t, srv)
+
+	srv.Close()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, srv.Restart())
+
+	assert.Eventually(t, func() bool {
+		_, missed := listener.counts()
+		return missed == 1
+	}, 5*time.Second, 20*time.Millisecond, "listeners hear that changes may have been missed")
+
+	require.NoError(t, bus.PublishLinkChange(ctx, &domain.LinkChange{ShortCode: "abc", OriginalURL: "https://example.com"}))
+	assert.Eventually(t, func() bool {
+		n, _ := listener.counts()
+		return n == 1
+	}, time.Second, 10*time.Millisecond, "changes arrive again once resubscribed")
+}
@@ -0,0 +1,376 @@
+// Package tiered puts a small in-process cache in front of a shared one, so
+// hot links are served without a round trip to Redis.
+package tiered
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+)
+
+// localPrefixes are the keys kept in process: links and domains are read on
+// every redirect and rarely change. Counters, analytics and quotas change on
+// every request and always go to the shared cache, as do missing links,
+// which must be forgotten when their shorter time to live in the shared
+// cache runs out rather than the local one.
+var localPrefixes = []string{"url:", "lurl:", "domain:"}
+
+// Options size the in-process tier
+type Options struct {
+	Size int           // Entries kept in process; older ones are evicted first
+	TTL  time.Duration // Longest an entry is served from process
+	Bus  *Bus          // Shares invalidations with other instances; nil keeps them local
+}
+
+// Cache is a domain.CacheRepository that keeps recently read links in a
+// bounded LRU in process and everything in the shared cache behind it.
+// Writes go to the shared cache first, then evict the key here and, through
+// the bus, on every other instance, unless they only fill the cache with
+// what storage holds (see domain.AsCacheFill). Entries live in process for
+// at most Options.TTL, which bounds staleness if an invalidation is lost.
+type Cache struct {
+	remote domain.CacheRepository
+	size   int
+	ttl    time.Duration
+	bus    *Bus
+	now    func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // Most recently used first
+	entries map[string]*list.Element
+	reads   map[string]*read // Reads from the shared cache in flight, by key
+
+	hits, misses, evictions, invalidations atomic.Int64
+}
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// read tracks the reads of one key from the shared cache in flight. Its
+// generation moves on whenever the key is invalidated, so a read that
+// started before does not keep the value it got in process.
+type read struct {
+	readers    int
+	generation uint64
+}
+
+// NewCache wraps remote with an in-process tier. With a bus it hears about
+// invalidations from other instances once the bus is started.
+func NewCache(remote domain.CacheRepository, opts Options) (*Cache, error) {
+	if opts.Size <= 0 || opts.TTL <= 0 {
+		return nil, fmt.Errorf("local cache size and ttl must be positive")
+	}
+
+	c := &Cache{
+		remote:  remote,
+		size:    opts.Size,
+		ttl:     opts.TTL,
+		bus:     opts.Bus,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		reads:   make(map[string]*read),
+	}
+	if c.bus != nil {
+		c.bus.attach(c)
+	}
+	return c, nil
+}
+
+// key partitions a cache key by the workspace of ctx, if any, the way the
+// shared cache does
+func key(ctx context.Context, k string) string {
+	if workspaceID, ok := domain.WorkspaceFromContext(ctx); ok {
+		return fmt.Sprintf("ws:%d:%s", workspaceID, k)
+	}
+	return k
+}
+
+// local reports whether a key is kept in process
+func local(k string) bool {
+	for _, prefix := range localPrefixes {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the unexpired value of a key, marking it recently used
+func (c *Cache) lookup(k string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[k]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expiresAt) {
+		c.lru.Remove(el)
+		delete(c.entries, k)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e.value, true
+}
+
+// store keeps a value for at most ttl, evicting the least recently used
+// entries over the size limit
+func (c *Cache) store(k string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || ttl > c.ttl {
+		ttl = c.ttl
+	}
+	expiresAt := c.now().Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.put(k, value, expiresAt)
+}
+
+// put is store with c.mu held
+func (c *Cache) put(k string, value []byte, expiresAt time.Time) {
+	if el, ok := c.entries[k]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expiresAt = value, expiresAt
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[k] = c.lru.PushFront(&entry{key: k, value: value, expiresAt: expiresAt})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+		c.evictions.Add(1)
+	}
+}
+
+// evict drops keys from process
+func (c *Cache) evict(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, k := range keys {
+		if el, ok := c.entries[k]; ok {
+			c.lru.Remove(el)
+			delete(c.entries, k)
+		}
+		if r, ok := c.reads[k]; ok {
+			r.generation++
+		}
+	}
+}
+
+// flush drops every entry from process
+func (c *Cache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	for _, r := range c.reads {
+		r.generation++
+	}
+}
+
+// beginRead registers a read of k from the shared cache and returns the
+// key's generation
+func (c *Cache) beginRead(k string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.reads[k]
+	if !ok {
+		r = &read{}
+		c.reads[k] = r
+	}
+	r.readers++
+	return r.generation
+}
+
+// endRead ends a read of k begun at generation, keeping the value it got
+// unless the key was invalidated meanwhile. A nil value keeps nothing.
+func (c *Cache) endRead(k string, generation uint64, value []byte) {
+	expiresAt := c.now().Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.reads[k]
+	if r.readers--; r.readers == 0 {
+		delete(c.reads, k)
+	}
+	if value != nil && r.generation == generation {
+		c.put(k, value, expiresAt)
+	}
+}
+
+// invalidate evicts a key here and on every other instance. A failed
+// publish is not returned: the shared cache already holds the new value,
+// and other instances catch up within the local TTL.
+func (c *Cache) invalidate(ctx context.Context, msg invalidation) {
+	if msg.All {
+		c.flush()
+	} else {
+		c.evict(msg.Keys...)
+	}
+	if c.bus != nil {
+		_ = c.bus.publish(ctx, msg)
+	}
+}
+
+// receive applies an invalidation published by another instance
+func (c *Cache) receive(msg invalidation) {
+	if msg.All {
+		c.flush()
+	} else {
+		c.evict(msg.Keys...)
+	}
+	c.invalidations.Add(1)
+}
+
+// LinkChanged drops a changed link from process, in the shared partition
+// used by redirects and in its workspace's
+func (c *Cache) LinkChanged(change *domain.LinkChange) {
+	var keys []string
+	for _, k := range []string{
+		"url:" + domain.LinkKey(change.Domain, change.ShortCode),
+		"lurl:" + domain.LinkKey(change.Domain, change.OriginalURL),
+	} {
+		keys = append(keys, k, fmt.Sprintf("ws:%d:%s", change.WorkspaceID, k))
+	}
+	c.evict(keys...)
+	c.invalidations.Add(1)
+}
+
+// LinkChangesMissed empties the local tier, since any entry may be stale
+func (c *Cache) LinkChangesMissed() {
+	c.flush()
+}
+
+func (c *Cache) Set(ctx context.Context, k string, value interface{}, ttl time.Duration) error {
+	if err := c.remote.Set(ctx, k, value, ttl); err != nil {
+		return err
+	}
+	if !local(k) {
+		return nil
+	}
+
+	full := key(ctx, k)
+	if !domain.IsCacheFill(ctx) {
+		c.invalidate(ctx, invalidation{Keys: []string{full}})
+	}
+	if data, err := json.Marshal(value); err == nil {
+		c.store(full, data, ttl)
+	}
+	return nil
+}
+
+func (c *Cache) Get(ctx context.Context, k string, dest interface{}) error {
+	if !local(k) {
+		return c.remote.Get(ctx, k, dest)
+	}
+
+	full := key(ctx, k)
+	if data, ok := c.lookup(full); ok {
+		c.hits.Add(1)
+		return json.Unmarshal(data, dest)
+	}
+	c.misses.Add(1)
+
+	generation := c.beginRead(full)
+	if err := c.remote.Get(ctx, k, dest); err != nil {
+		c.endRead(full, generation, nil)
+		return err
+	}
+	data, err := json.Marshal(dest)
+	if err != nil {
+		data = nil
+	}
+	c.endRead(full, generation, data)
+	return nil
+}
+
+func (c *Cache) Delete(ctx context.Context, k string) error {
+	if err := c.remote.Delete(ctx, k); err != nil {
+		return err
+	}
+	if local(k) {
+		c.invalidate(ctx, invalidation{Keys: []string{key(ctx, k)}})
+	}
+	return nil
+}
+
+func (c *Cache) Increment(ctx context.Context, k string, value int64) error {
+	if err := c.remote.Increment(ctx, k, value); err != nil {
+		return err
+	}
+	if local(k) {
+		c.invalidate(ctx, invalidation{Keys: []string{key(ctx, k)}})
+	}
+	return nil
+}
+
+// Expire changes how long the shared cache keeps a key. A new time to live
+// leaves the value alone, so copies in process are kept; expiring a key now
+// evicts it everywhere.
+func (c *Cache) Expire(ctx context.Context, k string, ttl time.Duration) error {
+	if err := c.remote.Expire(ctx, k, ttl); err != nil {
+		return err
+	}
+	if local(k) && ttl <= 0 {
+		c.invalidate(ctx, invalidation{Keys: []string{key(ctx, k)}})
+	}
+	return nil
+}
+
+func (c *Cache) HealthCheck(ctx context.Context) error {
+	return c.remote.HealthCheck(ctx)
+}
+
+// Cleanup empties the shared cache and every instance's local tier
+func (c *Cache) Cleanup(ctx context.Context) error {
+	if err := c.remote.Cleanup(ctx); err != nil {
+		return err
+	}
+	c.invalidate(ctx, invalidation{All: true})
+	return nil
+}
+
+func (c *Cache) GetCounter(ctx context.Context, k string) (int64, error) {
+	return c.remote.GetCounter(ctx, k)
+}
+
+// Stats reports the size and hit rate of the local tier
+func (c *Cache) Stats() domain.CacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return domain.CacheStats{
+		Entries:       entries,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+}
+
+// Close closes the shared cache if it can be closed
+func (c *Cache) Close() error {
+	if closer, ok := c.remote.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package tiered
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/domain"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/redis"
+	"github.com/mohammedrefaat/Go-URL-Shortener-Service/internal/store/storetest"
+)
+
+// newRedis connects a new client to srv
+func newRedis(t *testing.T, srv *miniredis.Miniredis) *redis.CacheRepository {
+	r, err := redis.NewCacheRepository("redis://" + srv.Addr())
+	require.NoError(t, err)
+	return r
+}
+
+// newBus starts a bus over a new client of srv
+func newBus(t *testing.T, srv *miniredis.Miniredis) (*Bus, *redis.CacheRepository) {
+	remote := newRedis(t, srv)
+	bus, err := NewBus(remote)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, bus.Start(ctx))
+	return bus, remote
+}
+
+// newTiered fronts a new client of srv with a local tier that shares
+// invalidations through srv
+func newTiered(t *testing.T, srv *miniredis.Miniredis, size int) *Cache {
+	bus, remote := newBus(t, srv)
+	c, err := NewCache(remote, Options{Size: size, TTL: time.Minute, Bus: bus})
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCache_Contract(t *testing.T) {
+	storetest.TestCacheRepository(t, func(t *testing.T) storetest.Cache {
+		srv := miniredis.RunT(t)
+		c := newTiered(t, srv, 100)
+		now := time.Now()
+		c.now = func() time.Time { return now }
+		return storetest.Cache{CacheRepository: c, Advance: func(d time.Duration) {
+			now = now.Add(d)
+			srv.FastForward(d)
+		}}
+	})
+}
+
+func TestCache_ServesLinksFromProcess(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	c := newTiered(t, srv, 100)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	require.NoError(t, c.Set(ctx, "url:abc", &domain.URL{ShortCode: "abc", OriginalURL: "https://example.com"}, time.Hour))
+	srv.Del("url:abc") // Behind the cache's back
+
+	var got domain.URL
+	require.NoError(t, c.Get(ctx, "url:abc", &got), "served from process")
+	assert.Equal(t, "https://example.com", got.OriginalURL)
+
+	now = now.Add(2 * time.Minute)
+	assert.ErrorIs(t, c.Get(ctx, "url:abc", &got), domain.ErrNotFound, "local entries live for the local ttl at most")
+
+	// Counters always come from Redis
+	require.NoError(t, c.Increment(ctx, "clicks:abc", 1))
+	srv.Set("clicks:abc", "5")
+	count, err := c.GetCounter(ctx, "clicks:abc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	stats := c.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+}
+
+func TestCache_MissingLinksFollowRedis(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	c := newTiered(t, srv, 100)
+
+	require.NoError(t, c.Set(ctx, "nurl:gone", true, 30*time.Second))
+	var missing bool
+	require.NoError(t, c.Get(ctx, "nurl:gone", &missing))
+
+	srv.FastForward(30 * time.Second)
+	assert.ErrorIs(t, c.Get(ctx, "nurl:gone", &missing), domain.ErrNotFound, "not kept past the shared ttl")
+	assert.Zero(t, c.Stats().Entries)
+}
+
+func TestCache_SizeLimit(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	c := newTiered(t, srv, 2)
+
+	for _, k := range []string{"url:a", "url:b"} {
+		require.NoError(t, c.Set(ctx, k, k, time.Hour))
+	}
+	var s string
+	require.NoError(t, c.Get(ctx, "url:a", &s)) // url:b is now the least recently used
+	require.NoError(t, c.Set(ctx, "url:c", "url:c", time.Hour))
+
+	stats := c.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, int64(1), stats.Evictions)
+
+	require.NoError(t, c.Get(ctx, "url:b", &s), "evicted entries are read from Redis again")
+	assert.Equal(t, "url:b", s)
+	assert.Equal(t, int64(1), c.Stats().Misses)
+}
+
+func TestCache_InvalidatesOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	a, b := newTiered(t, srv, 100), newTiered(t, srv, 100)
+	scoped := domain.WithWorkspace(ctx, 7)
+
+	require.NoError(t, a.Set(scoped, "url:abc", "https://example.com/old", time.Hour))
+	var got string
+	require.NoError(t, b.Get(scoped, "url:abc", &got))
+	assert.Equal(t, "https://example.com/old", got)
+
+	require.NoError(t, a.Set(scoped, "url:abc", "https://example.com/new", time.Hour))
+	assert.Eventually(t, func() bool {
+		return b.Get(scoped, "url:abc", &got) == nil && got == "https://example.com/new"
+	}, time.Second, 10*time.Millisecond, "edits reach other instances")
+
+	require.NoError(t, a.Delete(scoped, "url:abc"))
+	assert.Eventually(t, func() bool {
+		return b.Get(scoped, "url:abc", &got) != nil
+	}, time.Second, 10*time.Millisecond, "deletes reach other instances")
+	assert.NotZero(t, b.Stats().Invalidations)
+	assert.Zero(t, a.Stats().Invalidations, "instances ignore their own invalidations")
+}
+
+func TestCache_LinkChanged(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	c := newTiered(t, srv, 100)
+	scoped := domain.WithWorkspace(ctx, 4)
+
+	link := &domain.URL{WorkspaceID: 4, Domain: "go.brand.com", ShortCode: "sale", OriginalURL: "https://example.com/sale"}
+	keys := []string{"url:go.brand.com/sale", "lurl:go.brand.com/https://example.com/sale"}
+	for _, k := range keys {
+		require.NoError(t, c.Set(ctx, k, link, time.Hour))
+		require.NoError(t, c.Set(scoped, k, link, time.Hour))
+	}
+	require.NoError(t, c.Set(ctx, "url:other", link, time.Hour))
+	srv.FlushAll() // Only the local copies are left
+
+	c.LinkChanged(domain.NewLinkChange(link))
+
+	var got domain.URL
+	for _, k := range keys {
+		assert.ErrorIs(t, c.Get(ctx, k, &got), domain.ErrNotFound, k)
+		assert.ErrorIs(t, c.Get(scoped, k, &got), domain.ErrNotFound, k)
+	}
+	assert.NoError(t, c.Get(ctx, "url:other", &got), "other links are kept")
+
+	c.LinkChangesMissed()
+	assert.Zero(t, c.Stats().Entries, "missed changes empty the local tier")
+}
+
+func TestCache_ExpireKeepsOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	a, b := newTiered(t, srv, 100), newTiered(t, srv, 100)
+	invalidated := func(n int64) func() bool {
+		return func() bool { return b.Stats().Invalidations == n }
+	}
+
+	require.NoError(t, a.Set(ctx, "url:abc", "https://example.com", time.Minute))
+	require.Eventually(t, invalidated(1), time.Second, 10*time.Millisecond)
+	var got string
+	require.NoError(t, b.Get(ctx, "url:abc", &got))
+
+	require.NoError(t, a.Expire(ctx, "url:abc", time.Hour))
+	assert.Equal(t, time.Hour, srv.TTL("url:abc"))
+	require.NoError(t, a.Delete(ctx, "url:other")) // b has seen everything a sent once this arrives
+	require.Eventually(t, invalidated(2), time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, b.Stats().Entries, "a new ttl keeps copies in process")
+}
+
+func TestCache_FillsAreNotAnnounced(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	a, b := newTiered(t, srv, 100), newTiered(t, srv, 100)
+
+	require.NoError(t, a.Set(ctx, "url:abc", "https://example.com", time.Hour))
+	require.Eventually(t, func() bool { return b.Stats().Invalidations == 1 }, time.Second, 10*time.Millisecond)
+	var got string
+	require.NoError(t, b.Get(ctx, "url:abc", &got))
+
+	require.NoError(t, a.Set(domain.AsCacheFill(ctx), "url:abc", "https://example.com", time.Hour))
+	require.NoError(t, a.Delete(ctx, "url:other")) // b has seen everything a sent once this arrives
+	require.Eventually(t, func() bool { return b.Stats().Invalidations == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, b.Stats().Entries, "filling the cache keeps copies on other instances")
+}
+
+// pausedGets holds each Get from the shared cache once it has read the
+// value, until released
+type pausedGets struct {
+	domain.CacheRepository
+	read, release chan struct{}
+}
+
+func (p *pausedGets) Get(ctx context.Context, k string, dest interface{}) error {
+	err := p.CacheRepository.Get(ctx, k, dest)
+	p.read <- struct{}{}
+	<-p.release
+	return err
+}
+
+func TestCache_InvalidatedReadsAreNotKept(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	remote := newRedis(t, srv)
+	paused := &pausedGets{CacheRepository: remote, read: make(chan struct{}), release: make(chan struct{})}
+	c, err := NewCache(paused, Options{Size: 100, TTL: time.Minute})
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+
+	link := &domain.URL{ShortCode: "abc", OriginalURL: "https://example.com/old"}
+	require.NoError(t, remote.Set(ctx, "url:abc", link, time.Hour))
+
+	done := make(chan error)
+	go func() {
+		var got domain.URL
+		done <- c.Get(ctx, "url:abc", &got)
+	}()
+	<-paused.read
+	c.LinkChanged(domain.NewLinkChange(link)) // Edited while the old value is on its way
+	close(paused.release)
+	require.NoError(t, <-done)
+
+	assert.Zero(t, c.Stats().Entries, "a value read before an invalidation is not kept")
+	assert.Empty(t, c.reads)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// AppendQuery merges params into the query string of rawURL. Keys that are
+// already present in rawURL keep their values so the destination's own
+// parameters always win over incoming ones.
+func AppendQuery(rawURL string, params url.Values) (string, error) {
+	return mergeQuery(rawURL, params, false)
+}
+
+// SetQuery merges params into the query string of rawURL, replacing the
+// values of keys that are already present.
+func SetQuery(rawURL string, params url.Values) (string, error) {
+	return mergeQuery(rawURL, params, true)
+}
+
+func mergeQuery(rawURL string, params url.Values, overwrite bool) (string, error) {
+	if len(params) == 0 {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		if _, exists := query[key]; exists && !overwrite {
+			continue
+		}
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// AppendPath appends extra path segments to the path of rawURL. Dot segments
+// in extra are resolved first so they can never climb above the base path.
+func AppendPath(rawURL, extra string) (string, error) {
+	extra = strings.TrimPrefix(path.Clean("/"+extra), "/")
+	if extra == "" {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + extra
+	u.RawPath = ""
+
+	return u.String(), nil
+}
@@ -0,0 +1,66 @@
+package utils
+
+import "strings"
+
+// UserAgent is the coarse client classification used by device rules
+type UserAgent struct {
+	OS      string // ios, android, windows, macos, linux, chromeos or other
+	Device  string // mobile, tablet or desktop
+	Browser string // chrome, safari, firefox, edge, opera, samsung or other
+}
+
+var (
+	knownOS       = map[string]bool{"ios": true, "android": true, "windows": true, "macos": true, "linux": true, "chromeos": true, "other": true}
+	knownDevices  = map[string]bool{"mobile": true, "tablet": true, "desktop": true}
+	knownBrowsers = map[string]bool{"chrome": true, "safari": true, "firefox": true, "edge": true, "opera": true, "samsung": true, "other": true}
+)
+
+func IsKnownOS(name string) bool      { return knownOS[name] }
+func IsKnownDevice(name string) bool  { return knownDevices[name] }
+func IsKnownBrowser(name string) bool { return knownBrowsers[name] }
+
+// ParseUserAgent classifies a User-Agent header. It only looks for the
+// well-known tokens needed for routing, so unknown clients fall back to
+// "other" on a desktop.
+func ParseUserAgent(ua string) UserAgent {
+	result := UserAgent{OS: "other", Device: "desktop", Browser: "other"}
+
+	switch {
+	case strings.Contains(ua, "iPad"):
+		result.OS, result.Device = "ios", "tablet"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPod"):
+		result.OS, result.Device = "ios", "mobile"
+	case strings.Contains(ua, "Android"):
+		// Android tablets omit the "Mobile" token
+		result.OS, result.Device = "android", "tablet"
+		if strings.Contains(ua, "Mobile") {
+			result.Device = "mobile"
+		}
+	case strings.Contains(ua, "Windows"):
+		result.OS = "windows"
+	case strings.Contains(ua, "CrOS"):
+		result.OS = "chromeos"
+	case strings.Contains(ua, "Macintosh"), strings.Contains(ua, "Mac OS X"):
+		result.OS = "macos"
+	case strings.Contains(ua, "Linux"):
+		result.OS = "linux"
+	}
+
+	// Order matters: most browsers also claim to be Chrome and/or Safari
+	switch {
+	case strings.Contains(ua, "Edg/"), strings.Contains(ua, "EdgiOS"), strings.Contains(ua, "EdgA/"):
+		result.Browser = "edge"
+	case strings.Contains(ua, "OPR/"), strings.Contains(ua, "Opera"):
+		result.Browser = "opera"
+	case strings.Contains(ua, "SamsungBrowser"):
+		result.Browser = "samsung"
+	case strings.Contains(ua, "Firefox/"), strings.Contains(ua, "FxiOS"):
+		result.Browser = "firefox"
+	case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "CriOS"):
+		result.Browser = "chrome"
+	case strings.Contains(ua, "Safari/"):
+		result.Browser = "safari"
+	}
+
+	return result
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"net/url"
 	"sync"
 	"testing"
 	"time"
@@ -98,7 +99,14 @@ func TestIsValidURL(t *testing.T) {
 		{"//example.com", false},     // missing scheme
 		{"http://", false},           // missing host
 		{"", false},
-		{"http://malware.example.com", false}, // malicious domain from list
+		{"http://malware.example.com", false},          // malicious domain from list
+		{"http://cdn.MALWARE.example.com:8080", false}, // subdomain of a malicious domain
+		{"http://notmalware.example.com", true},        // lookalike, not a subdomain
+		{"http://malware.example.com.evil", true},      // different registrable domain
+		{"myapp://product/123", false},                 // deep links are only valid where allowed
+		{"localhost:8080", false},                      // not a scheme
+		{"javascript://alert(1)", false},               // script scheme
+		{"file:///etc/passwd", false},                  // local file
 	}
 
 	for _, c := range cases {
@@ -108,3 +116,129 @@ func TestIsValidURL(t *testing.T) {
 		}
 	}
 }
+
+func TestIsValidDeepLink(t *testing.T) {
+	schemes := []string{"myapp", "com.example.app", "javascript", "https"}
+	cases := []struct {
+		url string
+		ok  bool
+	}{
+		{"myapp://product/123", true},            // allowed app scheme
+		{"MyApp://product/123", true},            // schemes are case-insensitive
+		{"com.example.app://open?id=1", true},    // reverse-DNS app scheme
+		{"otherapp://product/123", false},        // not allowed
+		{"myapp://", false},                      // deep link without a target
+		{"myapp:product", false},                 // opaque form
+		{"myapp://malware.example.com/x", false}, // malicious host
+		{"javascript://alert(1)", false},         // script scheme, even if configured
+		{"https://example.com", false},           // web URLs are not deep links
+		{"localhost:8080", false},                // not a scheme
+	}
+
+	for _, c := range cases {
+		got := IsValidDeepLink(c.url, schemes)
+		if got != c.ok {
+			t.Fatalf("IsValidDeepLink(%q) = %v; want %v", c.url, got, c.ok)
+		}
+	}
+}
+
+// --- URL composition tests ---
+func TestAppendQuery(t *testing.T) {
+	cases := []struct {
+		base   string
+		params url.Values
+		want   string
+	}{
+		{"https://example.com/p", url.Values{"utm_source": {"x"}}, "https://example.com/p?utm_source=x"},
+		{"https://example.com/p?a=1", url.Values{"b": {"2"}}, "https://example.com/p?a=1&b=2"},
+		{"https://example.com/p?a=1", url.Values{"a": {"9"}}, "https://example.com/p?a=1"}, // destination wins
+		{"https://example.com/p#top", url.Values{"q": {"a b"}}, "https://example.com/p?q=a+b#top"},
+		{"https://example.com/p?a=1", nil, "https://example.com/p?a=1"},
+	}
+
+	for _, c := range cases {
+		got, err := AppendQuery(c.base, c.params)
+		if err != nil {
+			t.Fatalf("AppendQuery(%q) error: %v", c.base, err)
+		}
+		if got != c.want {
+			t.Fatalf("AppendQuery(%q, %v) = %q; want %q", c.base, c.params, got, c.want)
+		}
+	}
+
+	got, err := SetQuery("https://example.com/p?a=1", url.Values{"a": {"9"}})
+	if err != nil {
+		t.Fatalf("SetQuery error: %v", err)
+	}
+	if got != "https://example.com/p?a=9" {
+		t.Fatalf("SetQuery overwrite = %q", got)
+	}
+}
+
+func TestAppendPath(t *testing.T) {
+	cases := []struct {
+		base  string
+		extra string
+		want  string
+	}{
+		{"https://example.com", "/docs/intro", "https://example.com/docs/intro"},
+		{"https://example.com/base/", "/a/b", "https://example.com/base/a/b"},
+		{"https://example.com/base?x=1", "/a", "https://example.com/base/a?x=1"},
+		{"https://example.com/base", "/../../etc", "https://example.com/base/etc"},
+		{"https://example.com/base", "/", "https://example.com/base"},
+	}
+
+	for _, c := range cases {
+		got, err := AppendPath(c.base, c.extra)
+		if err != nil {
+			t.Fatalf("AppendPath(%q) error: %v", c.base, err)
+		}
+		if got != c.want {
+			t.Fatalf("AppendPath(%q, %q) = %q; want %q", c.base, c.extra, got, c.want)
+		}
+	}
+}
+
+// --- User agent tests ---
+func TestParseUserAgent(t *testing.T) {
+	cases := []struct {
+		ua   string
+		want UserAgent
+	}{
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			UserAgent{OS: "ios", Device: "mobile", Browser: "safari"},
+		},
+		{
+			"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/118.0 Mobile/15E148 Safari/604.1",
+			UserAgent{OS: "ios", Device: "tablet", Browser: "chrome"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36",
+			UserAgent{OS: "android", Device: "mobile", Browser: "chrome"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0 Safari/537.36",
+			UserAgent{OS: "android", Device: "tablet", Browser: "samsung"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0",
+			UserAgent{OS: "windows", Device: "desktop", Browser: "edge"},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.1; rv:120.0) Gecko/20100101 Firefox/120.0",
+			UserAgent{OS: "macos", Device: "desktop", Browser: "firefox"},
+		},
+		{
+			"curl/8.4.0",
+			UserAgent{OS: "other", Device: "desktop", Browser: "other"},
+		},
+	}
+
+	for _, c := range cases {
+		if got := ParseUserAgent(c.ua); got != c.want {
+			t.Fatalf("ParseUserAgent(%q) = %+v; want %+v", c.ua, got, c.want)
+		}
+	}
+}
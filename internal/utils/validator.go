@@ -2,6 +2,7 @@ package utils
 
 import (
 	"net/url"
+	"slices"
 	"strings"
 )
 
@@ -11,6 +12,21 @@ var maliciousDomains = []string{
 	// Add more malicious domains
 }
 
+// blockedSchemes run code or reach content we never want to redirect to,
+// even if configured as app schemes
+var blockedSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+	"data":       true,
+	"blob":       true,
+	"file":       true,
+	"about":      true,
+	"ftp":        true,
+	"http":       true, // Web URLs go through IsValidURL
+	"https":      true,
+}
+
+// IsValidURL accepts http(s) URLs with a host that is not blocklisted
 func IsValidURL(rawURL string) bool {
 	// Parse URL
 	u, err := url.Parse(rawURL)
@@ -29,11 +45,45 @@ func IsValidURL(rawURL string) bool {
 	}
 
 	// Check against malicious domains
+	return !isBlockedHost(u.Hostname())
+}
+
+// IsWebURL reports whether rawURL is an http(s) URL with a host rather
+// than an app deep link, so it can be fetched
+func IsWebURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return (scheme == "http" || scheme == "https") && u.Host != ""
+}
+
+// IsValidDeepLink accepts app deep links such as myapp://product/42 whose
+// scheme is one of schemes. They must be written as scheme://..., so that
+// inputs such as "localhost:8080" are not mistaken for a scheme and an
+// opaque path, and a host part, if any, must not be blocklisted.
+func IsValidDeepLink(rawURL string, schemes []string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || blockedSchemes[strings.ToLower(u.Scheme)] {
+		return false
+	}
+	if !slices.ContainsFunc(schemes, func(scheme string) bool { return strings.EqualFold(scheme, u.Scheme) }) {
+		return false
+	}
+	rest, ok := strings.CutPrefix(rawURL[len(u.Scheme):], "://")
+	return ok && rest != "" && !isBlockedHost(u.Hostname())
+}
+
+// isBlockedHost matches a host against the malicious domains and their
+// subdomains by whole labels, so lookalikes such as notmalware.example.com
+// are not caught while cdn.malware.example.com is
+func isBlockedHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
 	for _, domain := range maliciousDomains {
-		if strings.Contains(u.Host, domain) {
-			return false
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
 		}
 	}
-
-	return true
+	return false
 }
@@ -0,0 +1,3 @@
+-- Migration: 002_activation_window.sql
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS activates_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS coming_soon_url TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Migration: 003_redirect_status.sql
+-- 0 means the service-wide default configured under redirect.default_status
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_status SMALLINT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Migration: 005_geo_rules.sql
+-- Ordered [{"country": "DE", "url": "https://example.de"}] rules, first match wins
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS geo_rules JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,3 @@
+-- Migration: 006_device_rules.sql
+-- Ordered [{"os": "ios", "device": "mobile", "browser": "", "url": "myapp://..."}] rules
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS device_rules JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,7 @@
+-- Migration: 007_variants.sql
+-- Weighted A/B destinations [{"name": "a", "url": "https://...", "weight": 1}] and how visitors are assigned
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS variant_mode VARCHAR(16) NOT NULL DEFAULT '';
+
+-- Variant served on each click, empty for links without a split
+ALTER TABLE url_analytics ADD COLUMN IF NOT EXISTS variant VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Migration: 008_interstitial.sql
+-- Show a "You are leaving" page with a countdown before redirecting
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT FALSE;
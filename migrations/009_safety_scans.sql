@@ -0,0 +1,7 @@
+-- Migration: 009_safety_scans.sql
+-- Latest destination scan verdict ('' until a scan completes, then 'safe' or 'unsafe')
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS safety_status VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS safety_checked_at TIMESTAMP WITH TIME ZONE;
+
+-- The periodic rescan walks links from never-checked to least recently checked
+CREATE INDEX IF NOT EXISTS idx_urls_safety_checked_at ON urls(safety_checked_at NULLS FIRST);
@@ -0,0 +1,14 @@
+-- Migration: 010_link_health.sql
+-- Latest destination health check (status 0 means the destination was unreachable)
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_status SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_latency_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_final_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_checked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_failures INTEGER NOT NULL DEFAULT 0;
+
+-- Links dead for too many consecutive checks stop redirecting
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The health checker walks enabled links from never-checked to least recently checked
+CREATE INDEX IF NOT EXISTS idx_urls_health_checked_at ON urls(health_checked_at NULLS FIRST) WHERE NOT disabled;
@@ -0,0 +1,6 @@
+-- Migration: 011_open_graph.sql
+-- Social preview served to link-unfurling crawlers: fetched from the destination
+-- when the link is created, with per-link overrides taking precedence
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS og_title TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS og_description TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS og_image TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,15 @@
+-- Migration: 012_branded_domains.sql
+-- Branded domains, each with its own namespace of short codes
+CREATE TABLE IF NOT EXISTS domains (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(253) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Short codes are unique per domain rather than globally; '' is the default domain
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_short_code_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_domain_short_code ON urls(domain, short_code);
+
+ALTER TABLE url_analytics ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_analytics_domain_short_code ON url_analytics(domain, short_code);
@@ -0,0 +1,6 @@
+-- Migration: 014_timestamptz.sql
+-- 002, 009 and 010 first created these columns without a time zone; store
+-- them like every other timestamp. A no-op where they already have one.
+ALTER TABLE urls ALTER COLUMN activates_at TYPE TIMESTAMP WITH TIME ZONE;
+ALTER TABLE urls ALTER COLUMN safety_checked_at TYPE TIMESTAMP WITH TIME ZONE;
+ALTER TABLE urls ALTER COLUMN health_checked_at TYPE TIMESTAMP WITH TIME ZONE;